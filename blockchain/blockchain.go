@@ -2,10 +2,13 @@ package blockchain
 
 import (
 	"blockchain1/bloks"
+	"blockchain1/chaincfg"
 	"blockchain1/lib/utils"
+	"blockchain1/script"
 	"blockchain1/transaction"
 	wal "blockchain1/wallet"
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
@@ -13,25 +16,24 @@ import (
 	"github.com/boltdb/bolt"
 	"log"
 	"os"
+	"time"
 )
 
-const (
-	dbFile              = "blockchain_%s.db"
-	blocksBucket        = "blocks"
-	genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
-)
+const blocksBucket = "blocks"
 
 // Blockchain структура,
 // tip - зберігає хеш останнього блоку в ланцюгу.
 // Db - вказівник на базу даних.
+// invalidBlocks - хеші блоків, які не пройшли перевірку ( див. invalidateBlock ).
 type Blockchain struct {
-	tip []byte
-	Db  *bolt.DB
+	tip           []byte
+	Db            *bolt.DB
+	invalidBlocks map[string]bool
 }
 
 // CreateBlockchain створює нову базу даних Blockchain з genesis блоком.
 func CreateBlockchain(address string, nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+	dbFile := dbFileName(nodeID)
 	if dbExists(dbFile) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
@@ -45,7 +47,7 @@ func CreateBlockchain(address string, nodeID string) *Blockchain {
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		cbTx := transaction.NewCoinbaseTX(address, genesisCoinbaseData)
+		cbTx := transaction.NewCoinbaseTX(address, chaincfg.ActiveNetParams.GenesisCoinbaseData, 0, 0)
 		genesis := bloks.NewGenesisBlock(cbTx)
 
 		b, err := tx.CreateBucket([]byte(blocksBucket))
@@ -53,7 +55,12 @@ func CreateBlockchain(address string, nodeID string) *Blockchain {
 			log.Panic(err)
 		}
 
-		err = b.Put(genesis.Hash, genesis.Serialize())
+		_, err = tx.CreateBucket([]byte(headersBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = writeBlock(tx, genesis)
 		if err != nil {
 			log.Panic(err)
 		}
@@ -64,7 +71,42 @@ func CreateBlockchain(address string, nodeID string) *Blockchain {
 		}
 		tip = genesis.Hash
 
-		return nil
+		err = tx.Bucket([]byte(headersBucket)).Put([]byte(bestHeaderKey), genesis.Hash)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = indexMainChain(tx, genesis.Hash)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucket([]byte(chainWorkBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		_, err = chainWork(tx, genesis.Hash)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucket([]byte(utxoBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		_, err = tx.CreateBucket([]byte(undoBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = setEncodingVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		return UTXOSet{}.connectBlock(tx, genesis)
 	})
 
 	if err != nil {
@@ -72,8 +114,9 @@ func CreateBlockchain(address string, nodeID string) *Blockchain {
 	}
 
 	bc := Blockchain{
-		tip: tip,
-		Db:  db,
+		tip:           tip,
+		Db:            db,
+		invalidBlocks: make(map[string]bool),
 	}
 
 	return &bc
@@ -83,7 +126,7 @@ func CreateBlockchain(address string, nodeID string) *Blockchain {
 NewBlockchain створює та повертає новий екземпляр Blockchain.
 */
 func NewBlockchain(nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+	dbFile := dbFileName(nodeID)
 	if dbExists(dbFile) == false {
 		fmt.Println("Blockchain databases are not known. Create one! or check the dbFile path.")
 		os.Exit(1)
@@ -96,8 +139,47 @@ func NewBlockchain(nodeID string) *Blockchain {
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
+		// бази даних, створені до появи канонічного кодування, спочатку перекодовуємо
+		err := migrateDatabase(tx)
+		if err != nil {
+			return err
+		}
+
 		b := tx.Bucket([]byte(blocksBucket))
-		tip = b.Get([]byte("l"))
+		tip = append([]byte{}, b.Get([]byte("l"))...)
+
+		// бази даних, створені до появи вибору ланцюга за роботою, не мають бакету chainwork
+		_, err = tx.CreateBucketIfNotExists([]byte(chainWorkBucket))
+		if err != nil {
+			return err
+		}
+
+		_, err = chainWork(tx, tip)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucketIfNotExists([]byte(utxoBucket))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucketIfNotExists([]byte(undoBucket))
+		if err != nil {
+			return err
+		}
+
+		// бази даних, створені до завантаження заголовків наперед, не мають індексу основного ланцюга
+		// і найкращого заголовка
+		err = indexMainChain(tx, tip)
+		if err != nil {
+			return err
+		}
+
+		headers := tx.Bucket([]byte(headersBucket))
+		if headers.Get([]byte(bestHeaderKey)) == nil {
+			return headers.Put([]byte(bestHeaderKey), tip)
+		}
 
 		return nil
 	})
@@ -106,8 +188,9 @@ func NewBlockchain(nodeID string) *Blockchain {
 	}
 
 	bc := Blockchain{
-		tip,
-		db,
+		tip:           tip,
+		Db:            db,
+		invalidBlocks: make(map[string]bool),
 	}
 
 	return &bc
@@ -116,112 +199,204 @@ func NewBlockchain(nodeID string) *Blockchain {
 /*
 NewUTXOTransaction створює нову транзакцію UTXO,
 фактично відправляємо монети з одного гаманця на інший.
+Входи беруться з view, тому транзакція може витрачати і виходи непідтверджених транзакцій пулу.
+Комісія fee не записується в транзакцію явно: це різниця між сумою входів і сумою виходів,
+яку отримує майнер блоку. Якщо lockTime не нульовий, транзакцію можна включити лише в блок
+після цієї висоти або медіанного часу ( див. transaction.Transaction.IsFinal ).
 */
-func NewUTXOTransaction(wallet *wal.Wallet, to string, amount int, UTXOSet *UTXOSet) *transaction.Transaction {
-	var inputs []transaction.TXInput
-	var outputs []transaction.TXOutput
-
-	pubKeyHash := wal.HashPubKey(wallet.PublicKey)
+func NewUTXOTransaction(wallet *wal.Wallet, to string, amount int, fee int, lockTime uint32, view UTXOView) *transaction.Transaction {
+	lockingScript := script.PayToPubKeyHash(wal.HashPubKey(wallet.PublicKey))
 
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
-	if acc < amount {
+	tx, err := BuildTransaction(wallet, to, amount, fee, lockTime, view.FindUnspentOutputs(lockingScript))
+	if errors.Is(err, ErrInsufficientFunds) {
 		log.Print("Error: Недостатньо коштів")
 		os.Exit(0)
 	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return tx
+}
+
+/*
+BuildTransaction створює і підписує транзакцію з виходів unspent, які належать гаманцю.
+Виходи беруться по черзі, поки їх сума не покриє amount і fee, решта повертається на адресу гаманця.
+На відміну від NewUTXOTransaction, не звертається до бази даних, тому виходи можна отримати
+від вузла через RPC.
+*/
+func BuildTransaction(wallet *wal.Wallet, to string, amount int, fee int, lockTime uint32, unspent []UnspentOutput) (*transaction.Transaction, error) {
+	from := fmt.Sprintf("%s", wallet.GetAddress())
+
+	tx, prevOutputs, err := buildUnsignedTransaction(from, to, amount, fee, lockTime, unspent)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := utils.PrivateKeyFromBytes(wallet.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.Sing(*privateKey, prevOutputs) // передаємо створену транзакцію у процес підпису
+
+	return tx, nil
+}
+
+/*
+BuildPSBT створює непідписану транзакцію з виходів unspent адреси from у вигляді PSBT,
+який можна підписати на іншій машині без бази даних ( див. transaction.PSBT ). Решта повертається на адресу from.
+*/
+func BuildPSBT(from string, to string, amount int, fee int, unspent []UnspentOutput) (*transaction.PSBT, error) {
+	tx, prevOutputs, err := buildUnsignedTransaction(from, to, amount, fee, 0, unspent)
+	if err != nil {
+		return nil, err
+	}
+
+	var spentOutputs []transaction.TXOutput
+	for _, vin := range tx.VIn {
+		spentOutputs = append(spentOutputs, prevOutputs[vin.OutPoint()])
+	}
+
+	return transaction.NewPSBT(*tx, spentOutputs)
+}
+
+/*
+buildUnsignedTransaction створює непідписану транзакцію з виходів unspent, які належать адресі from,
+і повертає її разом з виходами, які витрачають її входи.
+Входи не мають відносних блокувань, а LockTime діє, лише якщо lockTime не нульовий.
+*/
+func buildUnsignedTransaction(from string, to string, amount int, fee int, lockTime uint32, unspent []UnspentOutput) (*transaction.Transaction, map[transaction.OutPoint]transaction.TXOutput, error) {
+	var inputs []transaction.TXInput
+	var outputs []transaction.TXOutput
+
+	// LockTime діє, лише якщо хоча б один вхід має Sequence, меншу за MaxSequence
+	sequence := uint32(transaction.MaxSequence)
+	if lockTime != 0 {
+		sequence = transaction.MaxSequence - 1
+	}
+
+	prevOutputs := make(map[transaction.OutPoint]transaction.TXOutput)
+	acc := 0
 
 	//складаємо список inputs
-	for txId, outs := range validOutputs {
-		txID, err := hex.DecodeString(txId)
+	for _, utxo := range unspent {
+		if acc >= amount+fee {
+			break
+		}
+
+		txID, err := hex.DecodeString(utxo.TxId)
 		if err != nil {
-			log.Panic(err)
+			return nil, nil, err
 		}
 
-		for _, out := range outs {
-			input := transaction.TXInput{
-				TxId:      txID,
-				VOut:      out,
-				Signature: nil,
-				PubKey:    wallet.PublicKey,
-			}
-			inputs = append(inputs, input)
+		input := transaction.TXInput{
+			TxId:     txID,
+			VOut:     utxo.VOut,
+			Sequence: sequence,
 		}
+		inputs = append(inputs, input)
+		prevOutputs[input.OutPoint()] = utxo.Output
+		acc += utxo.Output.Value
+	}
+
+	if acc < amount+fee {
+		return nil, nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientFunds, acc, amount+fee)
 	}
 
 	// складаємо список outputs
-	from := fmt.Sprintf("%s", wallet.GetAddress())
 	outputs = append(outputs, *transaction.NewTXOutput(amount, to))
-	if acc > amount {
-		outputs = append(outputs, *transaction.NewTXOutput(acc-amount, from))
+	if acc > amount+fee {
+		outputs = append(outputs, *transaction.NewTXOutput(acc-amount-fee, from))
 	}
 
 	tx := transaction.Transaction{
-		ID:   nil,
-		VIn:  inputs,
-		VOut: outputs,
+		Version:  transaction.TxVersion,
+		ID:       nil,
+		VIn:      inputs,
+		VOut:     outputs,
+		LockTime: lockTime,
 	}
 	tx.ID = tx.Hash()
 
-	privateKey, err := utils.PrivateKeyFromBytes(wallet.PrivateKey)
+	return &tx, prevOutputs, nil
+}
+
+/*
+MineBlock додає новий блок до ланцюга Blockchain.
+*/
+func (bc *Blockchain) MineBlock(transactions []*transaction.Transaction) *bloks.Block {
+	newBlock, err := bc.NewBlockTemplate(transactions)
+	if err != nil {
+		log.Fatal("ERROR: Invalid transaction")
+	}
+
+	_, err = newBlock.Mine(context.Background())
 	if err != nil {
 		log.Panic(err)
 	}
 
-	UTXOSet.Blockchain.SignTransaction(&tx, *privateKey) // передаємо створену транзакцію у процес підпису
+	// додавання блоку до бази даних Blockchain, блок продовжує поточний ланцюг і стає його вершиною
+	_, err = bc.AddBlock(newBlock)
+	if err != nil {
+		log.Panic(err)
+	}
 
-	return &tx
+	return newBlock
 }
 
 /*
-MineBlock додає новий блок до ланцюга Blockchain.
+NewBlockTemplate створює блок з транзакціями transactions поверх поточної вершини ланцюга,
+але без доказу роботи: його потрібно добути через Mine і додати до ланцюга через AddBlock.
+Транзакції перевіряються відносно UTXO set, транзакція може витрачати виходи
+попередніх транзакцій цього ж блоку.
 */
-func (bc *Blockchain) MineBlock(transactions []*transaction.Transaction) *bloks.Block {
+func (bc *Blockchain) NewBlockTemplate(transactions []*transaction.Transaction) (*bloks.Block, error) {
 	var lastHash []byte
 	var lastHeight int
-
-	// перевірка чи транзакції валідні перед додаванням нового блоку
-	for _, tx := range transactions {
-		if bc.VerifyTransaction(tx) != true {
-			log.Fatal("ERROR: Invalid transaction")
-		}
-	}
+	var bits uint32
+	var ctx transaction.SpendContext
 
 	// отримання хеша останнього блоку з бази даних Blockchain
 	err := bc.Db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
-
-		blockData := b.Get(lastHash)
-		block := bloks.DeserializeBlock(blockData)
-		lastHeight = block.Height
+		// значення з bolt дійсні лише всередині транзакції, тому копіюємо хеш
+		lastHash = append([]byte{}, b.Get([]byte("l"))...)
 
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
-	}
-
-	// створення нового блоку
-	newBlock := bloks.NewBlock(transactions, lastHash, lastHeight+1)
-
-	// оновлення бази даних Blockchain з новим блоком
-	err = bc.Db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		err := b.Put(newBlock.Hash, newBlock.Serialize())
+		header, err := readHeader(tx, lastHash)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
-		err = b.Put([]byte("l"), newBlock.Hash)
+		lastHeight = header.Height
+
+		bits, err = calcNextBits(tx, header)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
-		bc.tip = newBlock.Hash
-		return nil
+
+		ctx, err = spendContext(tx, header)
+
+		return err
 	})
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
 
-	return newBlock
+	view := newBlockView(UTXOSet{bc}, ctx)
+	for _, tx := range transactions {
+		if _, err := ValidateTransaction(tx, view, ctx); err != nil {
+			return nil, err
+		}
+		view.connect(tx)
+	}
+
+	// час блоку має бути більшим за медіанний час попередніх блоків
+	timestamp := time.Now().Unix()
+	if timestamp <= ctx.MedianTime {
+		timestamp = ctx.MedianTime + 1
+	}
+
+	return bloks.NewBlockTemplate(transactions, lastHash, lastHeight+1, bits, timestamp), nil
 }
 
 /*
@@ -246,6 +421,7 @@ func (bc *Blockchain) FindUTXO() map[string]transaction.TXOutputs {
 
 	for {
 		block := bci.Next()
+		ctx := bc.blockSpendContext(block)
 
 		for _, tx := range block.Transactions {
 			txID := hex.EncodeToString(tx.ID)
@@ -262,6 +438,8 @@ func (bc *Blockchain) FindUTXO() map[string]transaction.TXOutputs {
 				}
 				outs := UTXO[txID]
 				outs.Outputs = append(outs.Outputs, out)
+				outs.Indexes = append(outs.Indexes, outIdx)
+				outs.Block = ctx
 				UTXO[txID] = outs
 			}
 
@@ -283,21 +461,42 @@ func (bc *Blockchain) FindUTXO() map[string]transaction.TXOutputs {
 }
 
 /*
-SignTransaction отримує одну транзакцію потім знаходить транзакції на які вона посилається
-і передає у логіку підписування транзакції
+blockSpendContext повертає висоту блоку і медіанний час перед ним, від яких відраховуються
+відносні блокування його виходів.
 */
-func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, privetKey ecdsa.PrivateKey) {
-	prevTXs := make(map[string]transaction.Transaction)
+func (bc *Blockchain) blockSpendContext(block *bloks.Block) transaction.SpendContext {
+	ctx := transaction.SpendContext{Height: block.Height}
+	if len(block.PrevBlockHash) == 0 {
+		return ctx
+	}
 
-	for _, vin := range tx.VIn {
-		prevTX, err := bc.FindTransaction(vin.TxId)
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		parent, err := readHeader(tx, block.PrevBlockHash)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+		ctx, err = spendContext(tx, parent)
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
 	}
 
-	tx.Sing(privetKey, prevTXs)
+	return ctx
+}
+
+/*
+SignTransaction отримує одну транзакцію потім знаходить в UTXO set виходи, які вона витрачає,
+і передає у логіку підписування транзакції
+*/
+func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, privetKey ecdsa.PrivateKey) {
+	prevOutputs, err := UTXOSet{bc}.FindPrevOutputs(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	tx.Sing(privetKey, prevOutputs)
 }
 
 /*
@@ -321,34 +520,59 @@ func (bc *Blockchain) FindTransaction(ID []byte) (transaction.Transaction, error
 }
 
 /*
-VerifyTransaction перевіряє чи транзакція є дійсною
+VerifyTransaction перевіряє чи транзакція є дійсною відносно поточного UTXO set:
+виходи, які вона витрачає, ще не витрачені, підписи правильні і сума виходів не перевищує суму входів
 */
 func (bc *Blockchain) VerifyTransaction(tx *transaction.Transaction) bool {
-	if tx.IsCoinbase() {
-		return true
-	}
-	prevTXs := make(map[string]transaction.Transaction)
-	for _, vin := range tx.VIn {
-		prevTX, err := bc.FindTransaction(vin.TxId)
+	return bc.CheckTransaction(tx) == nil
+}
+
+/*
+CheckTransaction виконує перевірки VerifyTransaction і повертає RuleError з причиною,
+з якої транзакцію відхилено.
+*/
+func (bc *Blockchain) CheckTransaction(tx *transaction.Transaction) error {
+	_, err := ValidateTransaction(tx, UTXOSet{bc}, bc.SpendContext())
+
+	return err
+}
+
+/*
+SpendContext повертає контекст витрати виходів для наступного блоку після вершини ланцюга:
+саме до нього увійдуть нові транзакції.
+*/
+func (bc *Blockchain) SpendContext() transaction.SpendContext {
+	var ctx transaction.SpendContext
+
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		tip, err := readHeader(tx, tx.Bucket([]byte(blocksBucket)).Get([]byte("l")))
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+		ctx, err = spendContext(tx, tip)
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
 	}
-	return tx.Verify(prevTXs)
+
+	return ctx
 }
 
 /*
 GetBestHeight повертає висоту останнього блоку в ланцюгу.
 */
 func (bc *Blockchain) GetBestHeight() int {
-	var lastBlock bloks.Block
+	var height int
 
 	err := bc.Db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		lastHash := b.Get([]byte("l"))
-		blockData := b.Get(lastHash)
-		lastBlock = *bloks.DeserializeBlock(blockData)
+		header, err := readHeader(tx, b.Get([]byte("l")))
+		if err != nil {
+			return err
+		}
+		height = header.Height
 
 		return nil
 	})
@@ -356,28 +580,46 @@ func (bc *Blockchain) GetBestHeight() int {
 		log.Panic(err)
 	}
 
-	return lastBlock.Height
+	return height
 
 }
 
 /*
-GetBlockHashes повертає список хешів всіх блоків у ланцюгу.
+GetBestHash повертає хеш останнього блоку в ланцюгу.
 */
-func (bc *Blockchain) GetBlockHashes() [][]byte {
-	var blocks [][]byte
-	bci := bc.Iterator()
+func (bc *Blockchain) GetBestHash() []byte {
+	var lastHash []byte
 
-	for {
-		block := bci.Next()
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		// значення з bolt дійсні лише всередині транзакції, тому копіюємо хеш
+		lastHash = append([]byte{}, b.Get([]byte("l"))...)
 
-		blocks = append(blocks, block.Hash)
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
 
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
+	return lastHash
+}
+
+/*
+HasBlock перевіряє, чи є в базі даних тіло блоку з хешем blockHash.
+*/
+func (bc *Blockchain) HasBlock(blockHash []byte) bool {
+	found := false
+
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		found = hasBlock(tx, blockHash)
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
 	}
 
-	return blocks
+	return found
 }
 
 /*
@@ -387,15 +629,15 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (bloks.Block, error) {
 	var block bloks.Block
 
 	err := bc.Db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-
-		blockData := b.Get(blockHash)
-
-		if blockData == nil {
+		if !hasBlock(tx, blockHash) {
 			return errors.New("block is not found")
 		}
 
-		block = *bloks.DeserializeBlock(blockData)
+		found, err := readBlock(tx, blockHash)
+		if err != nil {
+			return err
+		}
+		block = *found
 
 		return nil
 	})
@@ -407,40 +649,150 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (bloks.Block, error) {
 }
 
 /*
-AddBlock зберігає блок у базі даних якщо такого не існує.
+AddBlock зберігає блок у базі даних якщо такого не існує
+і обирає основний ланцюг за сумарною роботою ( proof-of-work ).
+Якщо новий блок робить свою гілку важчою за поточну, виконується реорганізація:
+блоки старої гілки відключаються від UTXO set, блоки нової гілки приєднуються до нього,
+а вершина "l" переноситься на новий блок. Все це відбувається в одній транзакції бази даних,
+тому якщо блок нової гілки не вдається приєднати, база даних залишається без змін.
+В результаті повертаються відключені та приєднані блоки. Якщо основний ланцюг не змінився, повертається nil.
+Блок, попередник якого невідомий, не зберігається. Недійсний блок запам'ятовується ( див. invalidateBlock ),
+і його нащадки теж відхиляються.
 */
-func (bc *Blockchain) AddBlock(block *bloks.Block) {
+func (bc *Blockchain) AddBlock(block *bloks.Block) (*Reorganization, error) {
+	var reorg *Reorganization
+
 	err := bc.Db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		blockInDb := b.Get(block.Hash)
-
-		if blockInDb != nil {
+		if hasBlock(tx, block.Hash) {
 			return nil
 		}
 
-		blockData := block.Serialize()
-		err := b.Put(block.Hash, blockData)
+		err := bc.checkInvalidAncestor(block.Hash, block.PrevBlockHash)
 		if err != nil {
-			log.Panic(err)
+			return err
+		}
+
+		// блок повністю перевіряється до того, як буде щось збережено
+		err = validateBlock(tx, block)
+		if err != nil {
+			return err
+		}
+
+		err = writeBlock(tx, block)
+		if err != nil {
+			return err
+		}
+
+		err = updateBestHeader(tx, block.Hash)
+		if err != nil {
+			return err
 		}
 
 		lastHash := b.Get([]byte("l"))
-		lastBlockData := b.Get(lastHash)
-		lastBlock := bloks.DeserializeBlock(lastBlockData)
 
-		if block.Height > lastBlock.Height {
-			err = b.Put([]byte("l"), block.Hash)
-			if err != nil {
-				log.Panic(err)
+		tipWork, err := chainWork(tx, lastHash)
+		if err != nil {
+			return err
+		}
+		blockWork, err := chainWork(tx, block.Hash)
+		if err != nil {
+			return err
+		}
+
+		// при однаковій роботі залишаємо гілку, яку побачили першою
+		if blockWork.Cmp(tipWork) <= 0 {
+			return nil
+		}
+
+		reorg, err = findReorganization(tx, lastHash, block)
+		if err != nil {
+			return err
+		}
+
+		err = applyReorganization(tx, reorg)
+		if err != nil {
+			return err
+		}
+
+		err = updateMainChain(tx, reorg)
+		if err != nil {
+			return err
+		}
+
+		err = b.Put([]byte("l"), block.Hash)
+		if err != nil {
+			return err
+		}
+		bc.tip = block.Hash
+
+		return nil
+	})
+	if err != nil {
+		if blockIsInvalid(block, err) {
+			if invalidateErr := bc.invalidateBlock(block); invalidateErr != nil {
+				log.Panic(invalidateErr)
 			}
-			bc.tip = block.Hash
 		}
 
+		return nil, err
+	}
+
+	return reorg, nil
+}
+
+/*
+DisconnectTip відключає останній блок основного ланцюга за даними відключення
+і переносить вершину та найкращий заголовок на його попередника. Блок залишається в базі даних як блок бічної гілки.
+Використовується для відкату помилкового блоку без повного перебудування UTXO set.
+*/
+func (bc *Blockchain) DisconnectTip() (*bloks.Block, error) {
+	var block *bloks.Block
+
+	err := bc.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		block = mustReadBlock(tx, b.Get([]byte("l")))
+
+		if len(block.PrevBlockHash) == 0 {
+			return errors.New("genesis block can't be disconnected")
+		}
+
+		err := UTXOSet{bc}.disconnectBlock(tx, block)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket([]byte(mainChainBucket)).Delete(heightKey(block.Height))
+		if err != nil {
+			return err
+		}
+
+		// відключений блок не повинен знову завантажуватися як частина найкращого ланцюга заголовків
+		err = tx.Bucket([]byte(headersBucket)).Put([]byte(bestHeaderKey), block.PrevBlockHash)
+		if err != nil {
+			return err
+		}
+
+		err = b.Put([]byte("l"), block.PrevBlockHash)
+		if err != nil {
+			return err
+		}
+		bc.tip = block.PrevBlockHash
+
 		return nil
 	})
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
+
+	return block, nil
+}
+
+/*
+dbFileName повертає назву файлу бази даних вузла nodeID. Ланцюг кожної мережі зберігається в окремому файлі.
+*/
+func dbFileName(nodeID string) string {
+	return fmt.Sprintf(chaincfg.ActiveNetParams.DbFile, nodeID)
 }
 
 /*
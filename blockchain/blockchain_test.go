@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/chaincfg"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	"fmt"
+	"os"
+	"testing"
+)
+
+/*
+newTestChain створює ланцюг мережі regtest у тимчасовому каталозі. Ціль regtest - 2^255,
+тому блоки тестів добуваються за кілька спроб.
+*/
+func newTestChain(t *testing.T) (*Blockchain, string) {
+	t.Helper()
+
+	useTestNetwork(t, &chaincfg.RegTestParams)
+
+	address := fmt.Sprintf("%s", wal.NewWallet().GetAddress())
+	bc := CreateBlockchain(address, "test")
+	t.Cleanup(func() { _ = bc.Db.Close() })
+
+	return bc, address
+}
+
+/*
+useTestNetwork робить активною мережу params і переходить у тимчасовий каталог, де вузол тесту
+зберігає базу даних. Після тесту все повертається як було.
+*/
+func useTestNetwork(t *testing.T, params *chaincfg.Params) {
+	t.Helper()
+
+	active := chaincfg.ActiveNetParams
+	chaincfg.ActiveNetParams = params
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		chaincfg.ActiveNetParams = active
+	})
+}
+
+/*
+testBlock добуває блок над parent з coinbase транзакцією на address і транзакціями txs.
+*/
+func testBlock(parent *bloks.Block, address string, txs ...*transaction.Transaction) *bloks.Block {
+	coinbase := transaction.NewCoinbaseTX(address, "", parent.Height+1, 0)
+
+	return mineTestBlock(parent, append([]*transaction.Transaction{coinbase}, txs...)...)
+}
+
+/*
+mineTestBlock добуває блок над parent з транзакціями txs без жодних перевірок.
+*/
+func mineTestBlock(parent *bloks.Block, txs ...*transaction.Transaction) *bloks.Block {
+	return bloks.NewBlock(txs, parent.Hash, parent.Height+1, parent.Bits, parent.Timestamp+1)
+}
+
+func tipBlock(t *testing.T, bc *Blockchain) *bloks.Block {
+	t.Helper()
+
+	block, err := bc.GetBlock(bc.GetBestHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &block
+}
+
+func addTestBlock(t *testing.T, bc *Blockchain, block *bloks.Block) {
+	t.Helper()
+
+	_, err := bc.AddBlock(block)
+	if err != nil {
+		t.Fatalf("block %d: %v", block.Height, err)
+	}
+}
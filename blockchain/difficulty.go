@@ -0,0 +1,38 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/chaincfg"
+	"github.com/boltdb/bolt"
+)
+
+/*
+calcNextBits обчислює складність блоку, який буде додано після parent.
+Складність змінюється лише на блоках, висота яких кратна RetargetInterval активної мережі:
+час добування останніх RetargetInterval блоків порівнюється з очікуваним.
+Для решти блоків, а в мережах з NoRetargeting для всіх, складність успадковується від попередника.
+Блоки, добуті до появи Bits, мали сталу складність bloks.LegacyBits, тому після такого блоку
+складність не перераховується: так legacy ланцюг перевіряється тими самими правилами.
+*/
+func calcNextBits(tx *bolt.Tx, parent *IndexedHeader) (uint32, error) {
+	params := chaincfg.ActiveNetParams
+	bits := parent.TargetBits()
+	if params.NoRetargeting || parent.Bits != bits || (parent.Height+1)%params.RetargetInterval != 0 {
+		return bits, nil
+	}
+
+	// шукаємо перший блок інтервалу, спускаючись від parent
+	first := parent
+	for first.Height > parent.Height-params.RetargetInterval && len(first.PrevBlockHash) > 0 {
+		var err error
+		first, err = readHeader(tx, first.PrevBlockHash)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	actualTimespan := parent.Timestamp - first.Timestamp
+	expectedTimespan := int64(parent.Height-first.Height) * params.TargetBlockSpacing
+
+	return bloks.CalcNextBits(bits, actualTimespan, expectedTimespan), nil
+}
@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/transaction"
+	"math/big"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestMineOnBlocksWithoutBits(t *testing.T) {
+	bc := openFixture(t, "baseline.db")
+	blocks := mainChainBlocks(t, bc)
+	legacyWork := bloks.NewProofOfWork(&bloks.BlockHeader{Version: bloks.BlockVersion, Bits: bloks.LegacyBits}).Work()
+
+	var work *big.Int
+	err := bc.Db.Update(func(tx *bolt.Tx) error {
+		// блоки без Bits проходять ті самі перевірки заголовка, що й нові
+		for _, block := range blocks[1:] {
+			err := validateHeader(tx, &block.BlockHeader, block.Height)
+			if err != nil {
+				t.Errorf("block %d: %v", block.Height, err)
+			}
+		}
+
+		var err error
+		work, err = chainWork(tx, blocks[len(blocks)-1].Hash)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := new(big.Int).Mul(legacyWork, big.NewInt(int64(len(blocks)))); work.Cmp(expected) != 0 {
+		t.Errorf("chain work is %v, want %v", work, expected)
+	}
+
+	block := bc.MineBlock([]*transaction.Transaction{transaction.NewCoinbaseTX(baselineMiner, "", len(blocks), 0)})
+	if block.Bits != bloks.LegacyBits {
+		t.Errorf("block is mined with bits %08x, want %08x", block.Bits, bloks.LegacyBits)
+	}
+	if best := bc.GetBestHash(); string(best) != string(block.Hash) {
+		t.Errorf("best block is %x, want %x", best, block.Hash)
+	}
+
+	err = bc.Db.Update(func(tx *bolt.Tx) error {
+		tipWork, err := chainWork(tx, block.Hash)
+		if err != nil {
+			return err
+		}
+		if tipWork.Sub(tipWork, work).Cmp(legacyWork) != 0 {
+			t.Errorf("new block adds work %v, want %v", tipWork, legacyWork)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,325 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/lib/serialize"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+/*
+headersBucket - бакет із заголовками блоків за їх хешем. Тіла блоків ( транзакції ) зберігаються
+окремо в бакеті blocks, тому ланцюг заголовків можна обійти, не читаючи транзакцій.
+Заголовок може зберігатися без тіла: під час завантаження ланцюга спочатку перевіряються заголовки,
+а тіла блоків завантажуються після них.
+bestHeaderKey - ключ бакету headers з хешем найкращого заголовка, тобто заголовка з найбільшою сумарною роботою,
+навіть якщо тіла його блоків ще не завантажено.
+*/
+const (
+	headersBucket = "headers"
+	bestHeaderKey = "l"
+)
+
+/*
+IndexedHeader - заголовок блоку разом з його хешем і висотою в ланцюгу.
+*/
+type IndexedHeader struct {
+	bloks.BlockHeader
+	Hash   []byte
+	Height int
+}
+
+/*
+serializeIndexedHeader кодує запис бакету headers: висоту і закодований заголовок.
+*/
+func serializeIndexedHeader(header *bloks.BlockHeader, height int) []byte {
+	var w serialize.Writer
+
+	w.WriteVarint(int64(height))
+	w.WriteBytes(header.Serialize())
+
+	return w.Bytes()
+}
+
+func deserializeIndexedHeader(hash []byte, data []byte) (*IndexedHeader, error) {
+	r := serialize.NewReader(data)
+	height := r.ReadInt()
+	header, err := bloks.DecodeBlockHeader(r.ReadBytes())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Finish(); err != nil {
+		return nil, err
+	}
+
+	return &IndexedHeader{
+		BlockHeader: *header,
+		Hash:        append([]byte{}, hash...),
+		Height:      height,
+	}, nil
+}
+
+/*
+readHeader читає заголовок блоку з хешем hash.
+*/
+func readHeader(tx *bolt.Tx, hash []byte) (*IndexedHeader, error) {
+	data := tx.Bucket([]byte(headersBucket)).Get(hash)
+	if data == nil {
+		return nil, fmt.Errorf("block %x is not found", hash)
+	}
+
+	header, err := deserializeIndexedHeader(hash, data)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode header of block %x: %w", hash, err)
+	}
+
+	return header, nil
+}
+
+/*
+hasBlock перевіряє, чи збережено тіло блоку з хешем hash.
+*/
+func hasBlock(tx *bolt.Tx, hash []byte) bool {
+	return tx.Bucket([]byte(blocksBucket)).Get(hash) != nil
+}
+
+/*
+readBlock читає заголовок і тіло блоку з хешем hash.
+*/
+func readBlock(tx *bolt.Tx, hash []byte) (*bloks.Block, error) {
+	header, err := readHeader(tx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	body := tx.Bucket([]byte(blocksBucket)).Get(hash)
+	if body == nil {
+		return nil, fmt.Errorf("block %x is not found", hash)
+	}
+	txs, err := bloks.DecodeBlockBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode block %x: %w", hash, err)
+	}
+
+	return &bloks.Block{
+		BlockHeader:  header.BlockHeader,
+		Transactions: txs,
+		Hash:         header.Hash,
+		Height:       header.Height,
+	}, nil
+}
+
+/*
+mustReadBlock читає блок, який точно має бути в базі даних, наприклад вершину ланцюга.
+*/
+func mustReadBlock(tx *bolt.Tx, hash []byte) *bloks.Block {
+	block, err := readBlock(tx, hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return block
+}
+
+/*
+hasHeader перевіряє, чи збережено заголовок блоку з хешем hash.
+*/
+func hasHeader(tx *bolt.Tx, hash []byte) bool {
+	return tx.Bucket([]byte(headersBucket)).Get(hash) != nil
+}
+
+/*
+writeHeader зберігає заголовок блоку з хешем hash на висоті height в бакеті headers.
+*/
+func writeHeader(tx *bolt.Tx, hash []byte, header *bloks.BlockHeader, height int) error {
+	return tx.Bucket([]byte(headersBucket)).Put(hash, serializeIndexedHeader(header, height))
+}
+
+/*
+writeBlock зберігає заголовок блоку в бакеті headers, а тіло - в бакеті blocks.
+*/
+func writeBlock(tx *bolt.Tx, block *bloks.Block) error {
+	err := writeHeader(tx, block.Hash, &block.BlockHeader, block.Height)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(blocksBucket)).Put(block.Hash, block.SerializeBody())
+}
+
+/*
+readBestHeader читає найкращий заголовок.
+*/
+func readBestHeader(tx *bolt.Tx) (*IndexedHeader, error) {
+	return readHeader(tx, tx.Bucket([]byte(headersBucket)).Get([]byte(bestHeaderKey)))
+}
+
+/*
+updateBestHeader робить заголовок з хешем hash найкращим, якщо його ланцюг має більшу сумарну роботу.
+При однаковій роботі залишається заголовок, який побачили першим.
+*/
+func updateBestHeader(tx *bolt.Tx, hash []byte) error {
+	b := tx.Bucket([]byte(headersBucket))
+
+	if best := b.Get([]byte(bestHeaderKey)); best != nil {
+		bestWork, err := chainWork(tx, append([]byte{}, best...))
+		if err != nil {
+			return err
+		}
+		work, err := chainWork(tx, hash)
+		if err != nil {
+			return err
+		}
+
+		if work.Cmp(bestWork) <= 0 {
+			return nil
+		}
+	}
+
+	return b.Put([]byte(bestHeaderKey), hash)
+}
+
+/*
+AddHeaders перевіряє заголовки ( див. validateHeader ) і зберігає нові без тіл блоків,
+щоб ланцюг заголовків було перевірено до завантаження блоків. Кожен заголовок має посилатися
+на вже відомий заголовок або на один з попередніх заголовків списку.
+Заголовки до першого недійсного зберігаються, навіть якщо повертається помилка.
+Повертає прийняті заголовки разом з тими, які вже були відомі.
+*/
+func (bc *Blockchain) AddHeaders(headers []*bloks.BlockHeader) ([]*IndexedHeader, error) {
+	var accepted []*IndexedHeader
+	var headerErr error
+
+	err := bc.Db.Update(func(tx *bolt.Tx) error {
+		for _, header := range headers {
+			indexed, err := bc.addHeader(tx, header)
+
+			var ruleErr RuleError
+			if errors.As(err, &ruleErr) {
+				headerErr = err
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			accepted = append(accepted, indexed)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return accepted, headerErr
+}
+
+func (bc *Blockchain) addHeader(tx *bolt.Tx, header *bloks.BlockHeader) (*IndexedHeader, error) {
+	hash := header.BlockHash()
+
+	err := bc.checkInvalidAncestor(hash, header.PrevBlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored, err := readHeader(tx, hash); err == nil {
+		// відомий заголовок міг стати найкращим, якщо раніше найкращим був заголовок недійсного ланцюга
+		return stored, updateBestHeader(tx, hash)
+	}
+
+	// якщо попередник невідомий, validateHeader поверне ErrOrphanBlock
+	height := 0
+	if parent, err := readHeader(tx, header.PrevBlockHash); err == nil {
+		height = parent.Height + 1
+	}
+
+	err = validateHeader(tx, header, height)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeHeader(tx, hash, header, height)
+	if err != nil {
+		return nil, err
+	}
+
+	err = updateBestHeader(tx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexedHeader{
+		BlockHeader: *header,
+		Hash:        hash,
+		Height:      height,
+	}, nil
+}
+
+/*
+BestHeader повертає найкращий заголовок. Його висота може бути більшою за висоту основного ланцюга,
+поки тіла блоків ще завантажуються.
+*/
+func (bc *Blockchain) BestHeader() *IndexedHeader {
+	var best *IndexedHeader
+
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		var err error
+		best, err = readBestHeader(tx)
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return best
+}
+
+/*
+HasHeader перевіряє, чи відомий заголовок блоку з хешем hash.
+*/
+func (bc *Blockchain) HasHeader(hash []byte) bool {
+	found := false
+
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		found = hasHeader(tx, hash)
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return found
+}
+
+/*
+PendingHeaders повертає заголовки ланцюга найкращого заголовка, блоки яких ще не приєднано до основного ланцюга,
+від точки розгалуження з основним ланцюгом до найкращого заголовка. Саме ці блоки потрібно завантажити
+( якщо їх тіл ще немає в базі даних ) і приєднати по черзі.
+*/
+func (bc *Blockchain) PendingHeaders() []*IndexedHeader {
+	var pending []*IndexedHeader
+
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		header, err := readBestHeader(tx)
+		for err == nil && !isMainChain(tx, header) {
+			pending = append(pending, header)
+			header, err = readHeader(tx, header.PrevBlockHash)
+		}
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for i, j := 0, len(pending)-1; i < j; i, j = i+1, j-1 {
+		pending[i], pending[j] = pending[j], pending[i]
+	}
+
+	return pending
+}
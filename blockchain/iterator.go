@@ -18,11 +18,10 @@ func (i *Iterator) Next() *bloks.Block {
 	var block *bloks.Block
 
 	err := i.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		encodedBlock := b.Get(i.currentHash)
-		block = bloks.DeserializeBlock(encodedBlock)
+		var err error
+		block, err = readBlock(tx, i.currentHash)
 
-		return nil
+		return err
 	})
 	if err != nil {
 		log.Panic(err)
@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/lib/utils"
+	"bytes"
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+/*
+mainChainBucket - індекс основного ланцюга: висота -> хеш блоку основного ланцюга на цій висоті.
+З ним можна за сталий час перевірити, чи належить блок основному ланцюгу, і знайти блок за висотою.
+*/
+const mainChainBucket = "mainchain"
+
+/*
+locatorDenseBlocks - скільки останніх блоків основного ланцюга локатор містить поспіль,
+далі відстань між блоками локатора подвоюється.
+*/
+const locatorDenseBlocks = 10
+
+func heightKey(height int) []byte {
+	return utils.IntToHex(int64(height))
+}
+
+/*
+mainChainHash повертає хеш блоку основного ланцюга на висоті height або nil, якщо ланцюг нижчий.
+*/
+func mainChainHash(tx *bolt.Tx, height int) []byte {
+	return tx.Bucket([]byte(mainChainBucket)).Get(heightKey(height))
+}
+
+func isMainChain(tx *bolt.Tx, header *IndexedHeader) bool {
+	return bytes.Equal(mainChainHash(tx, header.Height), header.Hash)
+}
+
+/*
+updateMainChain оновлює індекс основного ланцюга після реорганізації reorg.
+*/
+func updateMainChain(tx *bolt.Tx, reorg *Reorganization) error {
+	b := tx.Bucket([]byte(mainChainBucket))
+
+	for _, block := range reorg.Disconnected {
+		err := b.Delete(heightKey(block.Height))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, block := range reorg.Connected {
+		err := b.Put(heightKey(block.Height), block.Hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+indexMainChain будує індекс основного ланцюга з вершиною tip, якщо його ще немає.
+*/
+func indexMainChain(tx *bolt.Tx, tip []byte) error {
+	if tx.Bucket([]byte(mainChainBucket)) != nil {
+		return nil
+	}
+
+	b, err := tx.CreateBucket([]byte(mainChainBucket))
+	if err != nil {
+		return err
+	}
+
+	for hash := tip; len(hash) > 0; {
+		header, err := readHeader(tx, hash)
+		if err != nil {
+			return err
+		}
+
+		err = b.Put(heightKey(header.Height), header.Hash)
+		if err != nil {
+			return err
+		}
+		hash = header.PrevBlockHash
+	}
+
+	return nil
+}
+
+/*
+BlockLocator повертає локатор - хеші блоків від новіших до старіших, за якими інший вузол знаходить
+останній спільний блок і відповідає заголовками, що йдуть після нього ( див. LocateHeaders ).
+Локатор починається з заголовка from ( або найкращого заголовка, якщо from порожній ), якщо він не в основному ланцюгу,
+далі йдуть останні locatorDenseBlocks блоків основного ланцюга, а потім відстань між блоками подвоюється
+аж до genesis блоку, тому навіть для довгого ланцюга локатор короткий.
+*/
+func (bc *Blockchain) BlockLocator(from []byte) [][]byte {
+	var locator [][]byte
+
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		var start *IndexedHeader
+		var err error
+		if len(from) == 0 {
+			start, err = readBestHeader(tx)
+		} else {
+			start, err = readHeader(tx, from)
+		}
+		if err != nil {
+			return err
+		}
+		if !isMainChain(tx, start) {
+			locator = append(locator, start.Hash)
+		}
+
+		tip, err := readHeader(tx, tx.Bucket([]byte(blocksBucket)).Get([]byte("l")))
+		if err != nil {
+			return err
+		}
+
+		step := 1
+		height := tip.Height
+		for {
+			locator = append(locator, append([]byte{}, mainChainHash(tx, height)...))
+			if height == 0 {
+				break
+			}
+
+			if len(locator) >= locatorDenseBlocks {
+				step *= 2
+			}
+			height -= step
+			if height < 0 {
+				height = 0
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return locator
+}
+
+/*
+LocateHeaders знаходить перший хеш локатора, який належить основному ланцюгу, і повертає до max заголовків
+основного ланцюга, що йдуть після нього. Заголовки закінчуються заголовком hashStop, якщо він трапиться раніше.
+Якщо жоден хеш локатора не належить основному ланцюгу, заголовки повертаються від блоку, наступного за genesis.
+*/
+func (bc *Blockchain) LocateHeaders(locator [][]byte, hashStop []byte, max int) []*bloks.BlockHeader {
+	var headers []*bloks.BlockHeader
+
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		start := 1
+		for _, hash := range locator {
+			header, err := readHeader(tx, hash)
+			if err == nil && isMainChain(tx, header) {
+				start = header.Height + 1
+				break
+			}
+		}
+
+		for height := start; len(headers) < max; height++ {
+			hash := mainChainHash(tx, height)
+			if hash == nil {
+				break
+			}
+
+			header, err := readHeader(tx, hash)
+			if err != nil {
+				return err
+			}
+			headers = append(headers, &header.BlockHeader)
+
+			if bytes.Equal(header.Hash, hashStop) {
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return headers
+}
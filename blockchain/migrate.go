@@ -0,0 +1,328 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/merkleTree"
+	"blockchain1/transaction"
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"github.com/boltdb/bolt"
+)
+
+/*
+metaBucket - бакет зі службовими даними бази даних.
+encodingVersionKey - ключ, під яким зберігається версія кодування записів бази даних.
+dbEncodingVersion - поточна версія: заголовки і тіла блоків зберігаються окремо, а всі записи -
+в канонічному кодуванні, записи UTXO set і дані відключення блоків містять блок, який створив виходи.
+У версії 2 цього блоку не було, у версії 1 блоки також зберігалися цілком у бакеті blocks.
+Бази даних без версії створено до появи канонічного кодування, їх записи закодовано gob.
+*/
+const (
+	metaBucket         = "meta"
+	encodingVersionKey = "encoding"
+	dbEncodingVersion  = 3
+)
+
+/*
+gobBlock - блок у тому вигляді, в якому його зберігали gob до появи BlockHeader.
+*/
+type gobBlock struct {
+	Timestamp     int64
+	Transactions  []*transaction.Transaction
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Hash          []byte
+	Nonce         int
+	Height        int
+	Bits          uint32
+}
+
+/*
+setEncodingVersion позначає, що записи бази даних збережено в поточному кодуванні.
+*/
+func setEncodingVersion(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+
+	return meta.Put([]byte(encodingVersionKey), []byte{dbEncodingVersion})
+}
+
+/*
+migrateDatabase перекодовує записи бази даних старих версій у поточне кодування.
+Хеші блоків і транзакцій при цьому не змінюються: блоки і транзакції з бази даних отримують
+версії bloks.LegacyBlockVersion і transaction.LegacyTxVersion, для яких хеші і підписи
+обчислюються так само, як і раніше.
+Виконується в тій самій транзакції bolt, що й відкриття бази даних, тому перервана міграція не залишає
+базу даних наполовину перекодованою.
+*/
+func migrateDatabase(tx *bolt.Tx) error {
+	version := 0
+	if meta := tx.Bucket([]byte(metaBucket)); meta != nil {
+		stored := meta.Get([]byte(encodingVersionKey))
+		if len(stored) != 1 {
+			return fmt.Errorf("database encoding version %x is malformed", stored)
+		}
+		version = int(stored[0])
+	}
+
+	if version == dbEncodingVersion {
+		return nil
+	}
+	if version > dbEncodingVersion {
+		return fmt.Errorf("database encoding version %d is not supported", version)
+	}
+
+	fmt.Printf("Migrating database from encoding version %d to %d...\n", version, dbEncodingVersion)
+
+	if version < 2 {
+		decodeBlock := bloks.DecodeBlock
+		if version == 0 {
+			err := migrateGobRecords(tx)
+			if err != nil {
+				return err
+			}
+			decodeBlock = decodeGobBlock
+		}
+
+		err := splitBlocks(tx, decodeBlock)
+		if err != nil {
+			return fmt.Errorf("can't migrate blocks: %w", err)
+		}
+	}
+
+	err := addCoinBlocks(tx)
+	if err != nil {
+		return fmt.Errorf("can't migrate coin blocks: %w", err)
+	}
+
+	return setEncodingVersion(tx)
+}
+
+/*
+decodeGobBlock розбирає блок, збережений gob.
+Блоки, добуті до появи MerkleRoot і Bits, не мають цих полів. Їх доказ роботи хешував корінь дерева Меркла
+з gob кодування транзакцій, тому корінь відновлюється так, щоб хеш заголовка збігся зі збереженим,
+а Bits залишається нульовим ( див. bloks.BlockHeader.TargetBits ).
+*/
+func decodeGobBlock(data []byte) (*bloks.Block, error) {
+	var stored gobBlock
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored)
+	if err != nil {
+		return nil, err
+	}
+
+	block := &bloks.Block{
+		BlockHeader: bloks.BlockHeader{
+			Version:       bloks.LegacyBlockVersion,
+			PrevBlockHash: stored.PrevBlockHash,
+			MerkleRoot:    stored.MerkleRoot,
+			Timestamp:     stored.Timestamp,
+			Bits:          stored.Bits,
+			Nonce:         stored.Nonce,
+		},
+		Transactions: stored.Transactions,
+		Hash:         stored.Hash,
+		Height:       stored.Height,
+	}
+
+	if len(block.MerkleRoot) == 0 {
+		transaction.LegacyFingerprints(block.Transactions, func(fingerprints [][]byte) bool {
+			block.MerkleRoot = merkleTree.NewMerkleTree(fingerprints).RootNode.Data
+			return bytes.Equal(block.BlockHash(), block.Hash)
+		})
+	}
+
+	return block, nil
+}
+
+/*
+splitBlocks переносить заголовки блоків з бакету blocks у бакет headers, залишаючи в blocks лише тіла.
+*/
+func splitBlocks(tx *bolt.Tx, decodeBlock func(data []byte) (*bloks.Block, error)) error {
+	_, err := tx.CreateBucketIfNotExists([]byte(headersBucket))
+	if err != nil {
+		return err
+	}
+
+	var blocks []*bloks.Block
+	err = tx.Bucket([]byte(blocksBucket)).ForEach(func(k, v []byte) error {
+		// "l" зберігає хеш вершини ланцюга, а не блок
+		if bytes.Equal(k, []byte("l")) {
+			return nil
+		}
+
+		block, err := decodeBlock(v)
+		if err != nil {
+			return fmt.Errorf("record %x: %w", k, err)
+		}
+		if !bytes.Equal(block.Hash, block.BlockHash()) {
+			return fmt.Errorf("block %x has hash %x computed from its header", block.Hash, block.BlockHash())
+		}
+		blocks = append(blocks, block)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		err = writeBlock(tx, block)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+migrateGobRecords перекодовує записи UTXO set і дані відключення блоків, збережені gob.
+*/
+func migrateGobRecords(tx *bolt.Tx) error {
+	err := recodeBucket(tx.Bucket([]byte(utxoBucket)), func(key []byte, value []byte) ([]byte, error) {
+		var outputs transaction.TXOutputs
+		err := gob.NewDecoder(bytes.NewReader(value)).Decode(&outputs)
+		if err != nil {
+			return nil, err
+		}
+
+		// записи, збережені без індексів, містять виходи на своїх початкових позиціях
+		if outputs.Indexes == nil {
+			for outIdx := range outputs.Outputs {
+				outputs.Indexes = append(outputs.Indexes, outIdx)
+			}
+		}
+
+		return outputs.Serialize(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't migrate UTXO set: %w", err)
+	}
+
+	err = recodeBucket(tx.Bucket([]byte(undoBucket)), func(key []byte, value []byte) ([]byte, error) {
+		var undo BlockUndo
+		err := gob.NewDecoder(bytes.NewReader(value)).Decode(&undo)
+		if err != nil {
+			return nil, err
+		}
+
+		return undo.Serialize(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't migrate undo data: %w", err)
+	}
+
+	return nil
+}
+
+/*
+addCoinBlocks додає до записів UTXO set і даних відключення блоків висоту і медіанний час блоку,
+який створив виходи. Усі такі виходи належать транзакціям основного ланцюга,
+тому достатньо пройти його від вершини до першого блоку.
+*/
+func addCoinBlocks(tx *bolt.Tx) error {
+	blocks := make(map[string]transaction.SpendContext)
+
+	hash := tx.Bucket([]byte(blocksBucket)).Get([]byte("l"))
+	for len(hash) > 0 {
+		block, err := readBlock(tx, hash)
+		if err != nil {
+			return err
+		}
+
+		ctx := transaction.SpendContext{Height: block.Height}
+		if len(block.PrevBlockHash) > 0 {
+			parent, err := readHeader(tx, block.PrevBlockHash)
+			if err != nil {
+				return err
+			}
+			ctx, err = spendContext(tx, parent)
+			if err != nil {
+				return err
+			}
+		}
+
+		// при повторі ідентифікатора UTXO set містить виходи пізнішої транзакції
+		for _, blockTx := range block.Transactions {
+			txID := hex.EncodeToString(blockTx.ID)
+			if _, ok := blocks[txID]; !ok {
+				blocks[txID] = ctx
+			}
+		}
+
+		hash = block.PrevBlockHash
+	}
+
+	err := recodeBucket(tx.Bucket([]byte(utxoBucket)), func(key []byte, value []byte) ([]byte, error) {
+		block, ok := blocks[hex.EncodeToString(key)]
+		if !ok {
+			return nil, fmt.Errorf("transaction %x is not found in main chain", key)
+		}
+
+		outputs := transaction.DeserializeOutputs(value)
+		outputs.Block = block
+
+		return outputs.Serialize(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't migrate UTXO set: %w", err)
+	}
+
+	err = recodeBucket(tx.Bucket([]byte(undoBucket)), func(key []byte, value []byte) ([]byte, error) {
+		undo := DeserializeBlockUndo(value)
+		for i, spent := range undo.SpentOutputs {
+			block, ok := blocks[hex.EncodeToString(spent.TxId)]
+			if !ok {
+				return nil, fmt.Errorf("transaction %x is not found in main chain", spent.TxId)
+			}
+			undo.SpentOutputs[i].Block = block
+		}
+
+		return undo.Serialize(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't migrate undo data: %w", err)
+	}
+
+	return nil
+}
+
+/*
+recodeBucket замінює кожне значення бакету результатом recode.
+Бакет змінюється лише після обходу, бо bolt не дозволяє змінювати бакет під час ForEach.
+Бази даних старих версій можуть не мати бакету, тоді b дорівнює nil.
+*/
+func recodeBucket(b *bolt.Bucket, recode func(key []byte, value []byte) ([]byte, error)) error {
+	if b == nil {
+		return nil
+	}
+
+	var keys, values [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		value, err := recode(k, v)
+		if err != nil {
+			return fmt.Errorf("record %x: %w", k, err)
+		}
+		keys = append(keys, append([]byte{}, k...))
+		values = append(values, value)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		err = b.Put(key, values[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
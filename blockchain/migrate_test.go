@@ -0,0 +1,202 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/chaincfg"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+Бази даних testdata/encoding_v1.db і testdata/encoding_v2.db створено вузлом з версіями кодування
+бази даних 1 і 2 в мережі mainnet: genesis і два блоки на адресу fixtureMiner, потім блок з транзакцією,
+яка переказує 3 монети на fixtureRecipient з комісією 1.
+*/
+const (
+	fixtureMiner     = "1MiU2morkBG6HyjwqFipgE39dUXYjAAE7p"
+	fixtureRecipient = "1623ZjC8AjYa3cU22FZNAhgnPrdKYvBqHf"
+)
+
+/*
+testdata/baseline.db створено вузлом, який зберігав блоки gob без MerkleRoot і Bits, у мережі mainnet:
+genesis на адресу baselineMiner, два блоки, добуті командою send -mine з переказами 30 монет на baselineRecipient
+і 5 назад, і блок, який вузол-майнер добув з переказів 7 і 3, отриманих мережею, з coinbase транзакцією в кінці.
+Кожна coinbase транзакція дає 100 монет, комісій ще не було.
+*/
+const (
+	baselineMiner     = "1A64nKirzNZECeLzPji7QA8XH7eEcSub2E"
+	baselineRecipient = "17kmEgjeN8qAeX19jBMxRX8MMA2K4AXFmd"
+)
+
+func openFixture(t *testing.T, file string) *Blockchain {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useTestNetwork(t, &chaincfg.MainNetParams)
+	err = os.WriteFile(dbFileName("fixture"), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc := NewBlockchain("fixture")
+	t.Cleanup(func() { _ = bc.Db.Close() })
+
+	return bc
+}
+
+func balance(t *testing.T, bc *Blockchain, address string) int {
+	t.Helper()
+
+	lockingScript, err := wal.PayToAddrScript(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, unspent := range (UTXOSet{bc}).FindUnspentOutputs(lockingScript) {
+		total += unspent.Output.Value
+	}
+
+	return total
+}
+
+func TestMigrateDatabase(t *testing.T) {
+	for _, file := range []string{"encoding_v1.db", "encoding_v2.db"} {
+		t.Run(file, func(t *testing.T) {
+			bc := openFixture(t, file)
+
+			err := bc.Db.View(func(tx *bolt.Tx) error {
+				version := tx.Bucket([]byte(metaBucket)).Get([]byte(encodingVersionKey))
+				if !reflect.DeepEqual(version, []byte{dbEncodingVersion}) {
+					t.Errorf("encoding version is %v", version)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if height := bc.GetBestHeight(); height != 3 {
+				t.Fatalf("best height is %d, want 3", height)
+			}
+			if got := balance(t, bc, fixtureMiner); got != 397 {
+				t.Errorf("miner balance is %d, want 397", got)
+			}
+			if got := balance(t, bc, fixtureRecipient); got != 3 {
+				t.Errorf("recipient balance is %d, want 3", got)
+			}
+
+			// UTXO set після міграції має збігатися з побудованим заново з блоків, разом з блоками виходів
+			expected := bc.FindUTXO()
+			err = bc.Db.View(func(tx *bolt.Tx) error {
+				return tx.Bucket([]byte(utxoBucket)).ForEach(func(k, v []byte) error {
+					txID := hex.EncodeToString(k)
+					if got := transaction.DeserializeOutputs(v); !reflect.DeepEqual(got, expected[txID]) {
+						t.Errorf("outputs of %s are %+v, want %+v", txID, got, expected[txID])
+					}
+					delete(expected, txID)
+					return nil
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(expected) > 0 {
+				t.Errorf("%d transactions are missing from UTXO set", len(expected))
+			}
+
+			// дані відключення перекодовано: блок з переказом відкочується, повертаючи витрачені виходи
+			_, err = bc.DisconnectTip()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := balance(t, bc, fixtureRecipient); got != 0 {
+				t.Errorf("recipient balance after disconnect is %d, want 0", got)
+			}
+			if got := balance(t, bc, fixtureMiner); got != 300 {
+				t.Errorf("miner balance after disconnect is %d, want 300", got)
+			}
+		})
+	}
+}
+
+/*
+mainChainBlocks повертає блоки основного ланцюга від genesis до вершини.
+*/
+func mainChainBlocks(t *testing.T, bc *Blockchain) []*bloks.Block {
+	t.Helper()
+
+	var blocks []*bloks.Block
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		for height := 0; height <= bc.GetBestHeight(); height++ {
+			block, err := readBlock(tx, mainChainHash(tx, height))
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, block)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return blocks
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	bc := openFixture(t, "baseline.db")
+
+	if height := bc.GetBestHeight(); height != 3 {
+		t.Fatalf("best height is %d, want 3", height)
+	}
+	if got := balance(t, bc, baselineMiner); got != 271 {
+		t.Errorf("miner balance is %d, want 271", got)
+	}
+	if got := balance(t, bc, baselineRecipient); got != 129 {
+		t.Errorf("recipient balance is %d, want 129", got)
+	}
+
+	// заголовки відновлено так, що хеші блоків не змінилися
+	for _, block := range mainChainBlocks(t, bc) {
+		if block.Version != bloks.LegacyBlockVersion || block.Bits != 0 {
+			t.Errorf("block %d has version %d and bits %08x", block.Height, block.Version, block.Bits)
+		}
+		if !bytes.Equal(block.Hash, block.BlockHash()) {
+			t.Errorf("block %d has hash %x computed from its header, want %x", block.Height, block.BlockHash(), block.Hash)
+		}
+		if !bloks.NewProofOfWork(&block.BlockHeader).Validate() {
+			t.Errorf("block %d has invalid proof of work", block.Height)
+		}
+
+		// вузли отримують блок у канонічному кодуванні і перевіряють ідентифікатори і корінь дерева Меркла
+		received, err := bloks.DecodeBlock(block.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(received.Hash, block.Hash) {
+			t.Errorf("block %d is received with hash %x, want %x", block.Height, received.Hash, block.Hash)
+		}
+		for _, tx := range received.Transactions {
+			err := checkTransactionSanity(tx)
+			if err != nil {
+				t.Errorf("block %d: %v", block.Height, err)
+			}
+		}
+		if !bytes.Equal(received.MerkleRoot, received.HashTransactions()) {
+			t.Errorf("block %d merkle root doesn't match its transactions", block.Height)
+		}
+	}
+}
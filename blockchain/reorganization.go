@@ -0,0 +1,181 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"math/big"
+)
+
+const chainWorkBucket = "chainwork"
+
+/*
+Reorganization описує зміну основного ланцюга після додавання блоку.
+- Disconnected - блоки, які були відключені від основного ланцюга (від старої вершини до точки розгалуження).
+- Connected - блоки, які були приєднані до основного ланцюга (від точки розгалуження до нової вершини).
+Якщо новий блок просто продовжує поточний ланцюг, Disconnected порожній, а Connected містить лише цей блок.
+*/
+type Reorganization struct {
+	Disconnected []*bloks.Block
+	Connected    []*bloks.Block
+}
+
+/*
+chainWork повертає сумарну роботу ланцюга від genesis блоку до блоку з вказаним хешем.
+Якщо для блоку (або його предків) робота ще не збережена, вона обчислюється і записується
+в бакет chainwork, тому функцію потрібно викликати всередині транзакції на запис.
+*/
+func chainWork(tx *bolt.Tx, hash []byte) (*big.Int, error) {
+	works := tx.Bucket([]byte(chainWorkBucket))
+
+	// спускаємося вниз по ланцюгу заголовків, поки не знайдемо блок з уже відомою роботою
+	var pending []*IndexedHeader
+	work := big.NewInt(0)
+	for len(hash) > 0 {
+		if stored := works.Get(hash); stored != nil {
+			work.SetBytes(stored)
+			break
+		}
+
+		header, err := readHeader(tx, hash)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, header)
+		hash = header.PrevBlockHash
+	}
+
+	// піднімаємося назад і зберігаємо роботу для кожного блоку
+	for i := len(pending) - 1; i >= 0; i-- {
+		header := pending[i]
+		work.Add(work, bloks.NewProofOfWork(&header.BlockHeader).Work())
+
+		err := works.Put(header.Hash, work.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return work, nil
+}
+
+/*
+findReorganization знаходить точку розгалуження між поточною вершиною ланцюга і новим блоком
+та повертає блоки, які потрібно відключити і приєднати, щоб новий блок став вершиною.
+*/
+func findReorganization(tx *bolt.Tx, oldTip []byte, newTip *bloks.Block) (*Reorganization, error) {
+	reorg := &Reorganization{}
+
+	parent := func(block *bloks.Block) (*bloks.Block, error) {
+		return readBlock(tx, block.PrevBlockHash)
+	}
+
+	oldBlock, err := readBlock(tx, oldTip)
+	if err != nil {
+		return nil, err
+	}
+	newBlock := newTip
+
+	for oldBlock.Height > newBlock.Height {
+		reorg.Disconnected = append(reorg.Disconnected, oldBlock)
+		if oldBlock, err = parent(oldBlock); err != nil {
+			return nil, err
+		}
+	}
+	for newBlock.Height > oldBlock.Height {
+		reorg.Connected = append(reorg.Connected, newBlock)
+		if newBlock, err = parent(newBlock); err != nil {
+			return nil, err
+		}
+	}
+
+	// обидві гілки на однаковій висоті, спускаємося разом до спільного предка
+	for !bytes.Equal(oldBlock.Hash, newBlock.Hash) {
+		reorg.Disconnected = append(reorg.Disconnected, oldBlock)
+		reorg.Connected = append(reorg.Connected, newBlock)
+
+		if oldBlock, err = parent(oldBlock); err != nil {
+			return nil, err
+		}
+		if newBlock, err = parent(newBlock); err != nil {
+			return nil, err
+		}
+	}
+
+	// блоки для приєднання зібрані від вершини вниз, а приєднувати їх потрібно від точки розгалуження
+	for i, j := 0, len(reorg.Connected)-1; i < j; i, j = i+1, j-1 {
+		reorg.Connected[i], reorg.Connected[j] = reorg.Connected[j], reorg.Connected[i]
+	}
+
+	return reorg, nil
+}
+
+/*
+applyReorganization відключає від UTXO set блоки старої гілки та приєднує блоки нової.
+Якщо для якогось із відключених блоків немає даних відключення ( блок додано до їх появи ),
+UTXO set неможливо відкотити поблочно, тоді він будується заново до точки розгалуження ( див. rebuildUTXOSet ).
+Блоки нової гілки в обох випадках приєднуються через connectBlock, тобто з повною перевіркою транзакцій.
+*/
+func applyReorganization(tx *bolt.Tx, reorg *Reorganization) error {
+	utxo := UTXOSet{}
+
+	for _, block := range reorg.Disconnected {
+		err := utxo.disconnectBlock(tx, block)
+		if errors.Is(err, errNoUndoData) {
+			err = rebuildUTXOSet(tx, reorg.Connected[0].Height-1)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, block := range reorg.Connected {
+		err := utxo.connectBlock(tx, block)
+		if err != nil {
+			return fmt.Errorf("can't connect block %x: %w", block.Hash, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+rebuildUTXOSet будує UTXO set заново з блоків основного ланцюга від genesis до висоти height включно
+в межах транзакції бази даних. Блоки приєднуються через connectBlock, тому для них також
+зберігаються дані відключення, яких бракувало.
+*/
+func rebuildUTXOSet(tx *bolt.Tx, height int) error {
+	err := tx.DeleteBucket([]byte(utxoBucket))
+	if err != nil {
+		return err
+	}
+	_, err = tx.CreateBucket([]byte(utxoBucket))
+	if err != nil {
+		return err
+	}
+
+	for h := 0; h <= height; h++ {
+		hash := mainChainHash(tx, h)
+		if hash == nil {
+			return fmt.Errorf("main chain has no block at height %d", h)
+		}
+		block, err := readBlock(tx, hash)
+		if err != nil {
+			return err
+		}
+
+		// блоки основного ланцюга вже прийнято, тому їх помилка не робить недійсним новий блок ( див. blockIsInvalid )
+		err = UTXOSet{}.connectBlock(tx, block)
+		if err != nil {
+			return fmt.Errorf("can't rebuild UTXO set at block %x: %v", block.Hash, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"blockchain1/transaction"
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+removeUndoData видаляє дані відключення блоків, як у базі даних, створеній до їх появи.
+*/
+func removeUndoData(t *testing.T, bc *Blockchain) {
+	t.Helper()
+
+	err := bc.Db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(undoBucket))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket([]byte(undoBucket))
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hasUTXO(t *testing.T, bc *Blockchain, txID []byte) bool {
+	t.Helper()
+
+	found := false
+	err := bc.Db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket([]byte(utxoBucket)).Get(txID) != nil
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return found
+}
+
+func TestReorganizationWithoutUndoData(t *testing.T) {
+	bc, address := newTestChain(t)
+	genesis := tipBlock(t, bc)
+
+	a1 := testBlock(genesis, address)
+	addTestBlock(t, bc, a1)
+	a2 := testBlock(a1, address)
+	addTestBlock(t, bc, a2)
+	removeUndoData(t, bc)
+
+	b1 := testBlock(genesis, address)
+	addTestBlock(t, bc, b1)
+	b2 := testBlock(b1, address)
+	addTestBlock(t, bc, b2)
+	b3 := testBlock(b2, address)
+
+	reorg, err := bc.AddBlock(b3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reorg == nil || len(reorg.Disconnected) != 2 || len(reorg.Connected) != 3 {
+		t.Fatalf("unexpected reorganization %+v", reorg)
+	}
+	if !bytes.Equal(bc.GetBestHash(), b3.Hash) {
+		t.Fatal("tip is not moved to the new branch")
+	}
+
+	for _, coinbase := range []*transaction.Transaction{a1.Transactions[0], a2.Transactions[0]} {
+		if hasUTXO(t, bc, coinbase.ID) {
+			t.Errorf("coinbase %x of the old branch is still unspent", coinbase.ID)
+		}
+	}
+	for _, coinbase := range []*transaction.Transaction{genesis.Transactions[0], b1.Transactions[0], b3.Transactions[0]} {
+		if !hasUTXO(t, bc, coinbase.ID) {
+			t.Errorf("coinbase %x is not in UTXO set", coinbase.ID)
+		}
+	}
+}
+
+func TestInvalidBranchWithoutUndoDataIsRejected(t *testing.T) {
+	bc, address := newTestChain(t)
+	genesis := tipBlock(t, bc)
+
+	a1 := testBlock(genesis, address)
+	addTestBlock(t, bc, a1)
+	removeUndoData(t, bc)
+
+	b1 := testBlock(genesis, address)
+	addTestBlock(t, bc, b1)
+
+	// coinbase бере більше, ніж дозволяє винагорода, це виявляє лише connectBlock
+	b2 := mineTestBlock(b1, transaction.NewCoinbaseTX(address, "", b1.Height+1, 1))
+
+	_, err := bc.AddBlock(b2)
+	if !errors.Is(err, ErrBadCoinbaseValue) {
+		t.Fatalf("got %v, want %v", err, ErrBadCoinbaseValue)
+	}
+	if !bytes.Equal(bc.GetBestHash(), a1.Hash) {
+		t.Fatal("tip is moved to the invalid branch")
+	}
+	if !hasUTXO(t, bc, a1.Transactions[0].ID) || hasUTXO(t, bc, b1.Transactions[0].ID) {
+		t.Fatal("UTXO set is changed by the rejected reorganization")
+	}
+}
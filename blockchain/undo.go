@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"blockchain1/lib/serialize"
+	"blockchain1/transaction"
+	"errors"
+	"fmt"
+	"log"
+)
+
+/*
+undoBucket - бакет з даними відключення блоків за їх хешем.
+undoEncodingVersion - версія кодування даних відключення блоку. У версії noBlockUndoEncodingVersion
+не було блоку, який створив витрачений вихід, у версії legacyUndoEncodingVersion виходи також кодувалися без Script.
+*/
+const (
+	undoBucket                 = "undo"
+	undoEncodingVersion        = 3
+	noBlockUndoEncodingVersion = 2
+	legacyUndoEncodingVersion  = 1
+)
+
+// errNoUndoData повертається, якщо для блоку не збережено даних для відключення.
+var errNoUndoData = errors.New("undo data for block is not found")
+
+/*
+SpentOutput описує вихід, витрачений одним із входів блоку.
+- TxId - ідентифікатор транзакції, якій належить вихід.
+- Index - індекс виходу в цій транзакції.
+- Output - сам вихід, який потрібно повернути в UTXO set при відключенні блоку.
+- Block - блок, який створив вихід ( див. transaction.TXOutputs ).
+*/
+type SpentOutput struct {
+	TxId   []byte
+	Index  int
+	Output transaction.TXOutput
+	Block  transaction.SpendContext
+}
+
+/*
+BlockUndo зберігає всі виходи, витрачені блоком, у порядку входів його транзакцій.
+Цих даних достатньо, щоб відключити блок від UTXO set без повного перебудування.
+*/
+type BlockUndo struct {
+	SpentOutputs []SpentOutput
+}
+
+/*
+Serialize використовується для серіалізації даних відключення блоку:
+версія кодування, кількість витрачених виходів і самі виходи.
+*/
+func (u BlockUndo) Serialize() []byte {
+	var w serialize.Writer
+
+	w.WriteUvarint(undoEncodingVersion)
+	w.WriteUvarint(uint64(len(u.SpentOutputs)))
+	for _, spent := range u.SpentOutputs {
+		w.WriteBytes(spent.TxId)
+		w.WriteVarint(int64(spent.Index))
+		spent.Output.Encode(&w)
+		spent.Block.Encode(&w)
+	}
+
+	return w.Bytes()
+}
+
+/*
+DeserializeBlockUndo використовується для десеріалізації даних відключення блоку.
+*/
+func DeserializeBlockUndo(data []byte) BlockUndo {
+	var undo BlockUndo
+
+	r := serialize.NewReader(data)
+	version := r.ReadUvarint()
+	if version != undoEncodingVersion && version != noBlockUndoEncodingVersion && version != legacyUndoEncodingVersion {
+		r.Fail(fmt.Errorf("unsupported undo data encoding version %d", version))
+	}
+
+	for i, count := 0, r.ReadCount(); i < count; i++ {
+		var spent SpentOutput
+		spent.TxId = r.ReadBytes()
+		spent.Index = r.ReadInt()
+		if version == legacyUndoEncodingVersion {
+			spent.Output.DecodeLegacy(r)
+		} else {
+			spent.Output.Decode(r)
+		}
+		// дані старих версій отримують блок під час міграції бази даних
+		if version == undoEncodingVersion {
+			spent.Block.Decode(r)
+		}
+		undo.SpentOutputs = append(undo.SpentOutputs, spent)
+	}
+
+	if err := r.Finish(); err != nil {
+		log.Panic(fmt.Errorf("can't decode undo data: %w", err))
+	}
+
+	return undo
+}
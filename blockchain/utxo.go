@@ -5,6 +5,7 @@ import (
 	"blockchain1/transaction"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/boltdb/bolt"
 	"log"
 )
@@ -16,6 +17,23 @@ type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
+/*
+UnspentOutput - непотрачений вихід разом з посиланням на нього.
+- TxId - ідентифікатор транзакції у вигляді hex рядка.
+- VOut - індекс виходу в транзакції.
+- Output - сам вихід.
+*/
+type UnspentOutput struct {
+	TxId   string
+	VOut   int
+	Output transaction.TXOutput
+}
+
+/*
+ErrInsufficientFunds - непотрачених виходів гаманця недостатньо для транзакції.
+*/
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
 /*
 Reindex перебудовує UTXOset.
 */
@@ -63,10 +81,10 @@ func (u UTXOSet) Reindex() {
 }
 
 /*
-FindSpendableOutputs знаходить та повертає доступну кількість виходів, які можуть бути витрачені за допомогою публічного ключа,
+FindSpendableOutputs знаходить та повертає доступну кількість виходів, заблокованих скриптом lockingScript,
 використовується для створення нової транзакції.
 */
-func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
+func (u UTXOSet) FindSpendableOutputs(lockingScript []byte, amount int) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
 	accumulated := 0
 	db := u.Blockchain.Db
@@ -79,10 +97,10 @@ func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[s
 			txID := hex.EncodeToString(k)
 			outs := transaction.DeserializeOutputs(v)
 
-			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
+			for i, out := range outs.Outputs {
+				if out.IsLockedWithScript(lockingScript) && accumulated < amount {
 					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+					unspentOutputs[txID] = append(unspentOutputs[txID], outs.Indexes[i])
 				}
 			}
 		}
@@ -97,10 +115,46 @@ func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[s
 }
 
 /*
-FindUTXO знаходить та повертає всі непотрачені виходи транзакцій, які можуть бути розблоковані за допомогою публічного ключа.
+FindUnspentOutputs знаходить усі непотрачені виходи, заблоковані скриптом lockingScript ( наприклад скриптом адреси ),
+разом з посиланнями на них, тому їх можна витратити.
+*/
+func (u UTXOSet) FindUnspentOutputs(lockingScript []byte) []UnspentOutput {
+	var unspent []UnspentOutput
+	db := u.Blockchain.Db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			txID := hex.EncodeToString(k)
+			outs := transaction.DeserializeOutputs(v)
+
+			for i, out := range outs.Outputs {
+				if out.IsLockedWithScript(lockingScript) {
+					unspent = append(unspent, UnspentOutput{
+						TxId:   txID,
+						VOut:   outs.Indexes[i],
+						Output: out,
+					})
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return unspent
+}
+
+/*
+FindUTXO знаходить та повертає всі непотрачені виходи транзакцій, заблоковані скриптом lockingScript.
 використовується для підрахунку балансу гаманця.
 */
-func (u UTXOSet) FindUTXO(pubKeyHash []byte) []transaction.TXOutput {
+func (u UTXOSet) FindUTXO(lockingScript []byte) []transaction.TXOutput {
 	var UTXOs []transaction.TXOutput
 	db := u.Blockchain.Db
 
@@ -112,7 +166,7 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []transaction.TXOutput {
 			outs := transaction.DeserializeOutputs(v)
 
 			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) {
+				if out.IsLockedWithScript(lockingScript) {
 					UTXOs = append(UTXOs, out)
 				}
 			}
@@ -128,50 +182,294 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []transaction.TXOutput {
 }
 
 /*
-Update оновлює набір UTXOset транзакціями з нового блоку.
+Update оновлює набір UTXOset транзакціями з нового блоку
+та зберігає дані для його відключення.
 */
 func (u UTXOSet) Update(block *bloks.Block) {
 	db := u.Blockchain.Db
 
 	err := db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
+		return u.connectBlock(tx, block)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
 
-		for _, tx := range block.Transactions {
-			if tx.IsCoinbase() == false {
-				for _, vin := range tx.VIn {
-					updatedOuts := transaction.TXOutputs{}
-					outsBytes := b.Get(vin.TxId)
-					outs := transaction.DeserializeOutputs(outsBytes)
-
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.VOut {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-						}
-					}
+/*
+Disconnect відкочує зміни блоку в наборі UTXOset за збереженими даними відключення:
+видаляє виходи, створені блоком, і повертає виходи, які він витратив.
+Блок має бути останнім приєднаним до UTXOset.
+*/
+func (u UTXOSet) Disconnect(block *bloks.Block) error {
+	db := u.Blockchain.Db
+
+	return db.Update(func(tx *bolt.Tx) error {
+		return u.disconnectBlock(tx, block)
+	})
+}
+
+/*
+FindPrevOutputs знаходить у UTXOset виходи, які витрачають входи транзакції.
+Якщо якогось виходу немає ( він не існує або вже витрачений ), повертається помилка.
+*/
+func (u UTXOSet) FindPrevOutputs(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.TXOutput, error) {
+	prevOutputs := make(map[transaction.OutPoint]transaction.TXOutput)
+	db := u.Blockchain.Db
+
+	err := db.View(func(dbTx *bolt.Tx) error {
+		b := dbTx.Bucket([]byte(utxoBucket))
+
+		for _, vin := range tx.VIn {
+			out, ok := findOutput(b, vin.TxId, vin.VOut)
+			if !ok {
+				return ruleError(ErrMissingInput, "output %x:%d is not found in UTXO set", vin.TxId, vin.VOut)
+			}
+			prevOutputs[vin.OutPoint()] = out
+		}
+
+		return nil
+	})
+
+	return prevOutputs, err
+}
+
+/*
+FindPrevBlocks знаходить у UTXOset блоки, які створили виходи, що витрачають входи транзакції.
+*/
+func (u UTXOSet) FindPrevBlocks(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.SpendContext, error) {
+	prevBlocks := make(map[transaction.OutPoint]transaction.SpendContext)
+	db := u.Blockchain.Db
+
+	err := db.View(func(dbTx *bolt.Tx) error {
+		b := dbTx.Bucket([]byte(utxoBucket))
+
+		for _, vin := range tx.VIn {
+			outsBytes := b.Get(vin.TxId)
+			if outsBytes == nil {
+				return ruleError(ErrMissingInput, "output %x:%d is not found in UTXO set", vin.TxId, vin.VOut)
+			}
+			prevBlocks[vin.OutPoint()] = transaction.DeserializeOutputs(outsBytes).Block
+		}
+
+		return nil
+	})
+
+	return prevBlocks, err
+}
+
+/*
+CalcFee повертає комісію транзакції: різницю між сумою виходів з UTXOset, які вона витрачає,
+та сумою її власних виходів.
+*/
+func (u UTXOSet) CalcFee(tx *transaction.Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	prevOutputs, err := u.FindPrevOutputs(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	return calcFee(tx, prevOutputs)
+}
+
+/*
+findOutput знаходить невитрачений вихід у бакеті UTXOset за ідентифікатором транзакції та індексом.
+*/
+func findOutput(b *bolt.Bucket, txID []byte, outIdx int) (transaction.TXOutput, bool) {
+	outsBytes := b.Get(txID)
+	if outsBytes == nil {
+		return transaction.TXOutput{}, false
+	}
+	outs := transaction.DeserializeOutputs(outsBytes)
+
+	for i, out := range outs.Outputs {
+		if outs.Indexes[i] == outIdx {
+			return out, true
+		}
+	}
+
+	return transaction.TXOutput{}, false
+}
+
+/*
+connectBlock застосовує транзакції блоку до UTXOset в межах транзакції бази даних.
+Кожна транзакція перевіряється відносно виходів, які вона витрачає, і блоку,
+а витрачені виходи записуються в дані відключення блоку.
+Нові виходи запам'ятовують блок ( його висоту і медіанний час перед ним ), від якого відраховуються відносні блокування.
+*/
+func (u UTXOSet) connectBlock(tx *bolt.Tx, block *bloks.Block) error {
+	b := tx.Bucket([]byte(utxoBucket))
+	undo := BlockUndo{}
+	fees := 0
+
+	// genesis блок містить лише coinbase транзакцію, яка нічого не витрачає
+	ctx := transaction.SpendContext{Height: block.Height}
+	if len(block.PrevBlockHash) > 0 {
+		parent, err := readHeader(tx, block.PrevBlockHash)
+		if err != nil {
+			return err
+		}
+		ctx, err = spendContext(tx, parent)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() == false {
+			prevOutputs := make(map[transaction.OutPoint]transaction.TXOutput)
+			prevBlocks := make(map[transaction.OutPoint]transaction.SpendContext)
 
-					if len(updatedOuts.Outputs) == 0 {
-						err := b.Delete(vin.TxId)
-						if err != nil {
-							log.Panic(err)
-						}
-					} else {
-						err := b.Put(vin.TxId, updatedOuts.Serialize())
-						if err != nil {
-							log.Panic(err)
-						}
+			for _, vin := range tx.VIn {
+				outsBytes := b.Get(vin.TxId)
+				if outsBytes == nil {
+					return ruleError(ErrMissingInput, "output %x:%d is not found in UTXO set", vin.TxId, vin.VOut)
+				}
+				outs := transaction.DeserializeOutputs(outsBytes)
+
+				spent := false
+				updatedOuts := transaction.TXOutputs{Block: outs.Block}
+				for i, out := range outs.Outputs {
+					if outs.Indexes[i] == vin.VOut {
+						undo.SpentOutputs = append(undo.SpentOutputs, SpentOutput{
+							TxId:   vin.TxId,
+							Index:  vin.VOut,
+							Output: out,
+							Block:  outs.Block,
+						})
+						prevOutputs[vin.OutPoint()] = out
+						prevBlocks[vin.OutPoint()] = outs.Block
+						spent = true
+						continue
 					}
+					updatedOuts.Outputs = append(updatedOuts.Outputs, out)
+					updatedOuts.Indexes = append(updatedOuts.Indexes, outs.Indexes[i])
+				}
+				if !spent {
+					return ruleError(ErrMissingInput, "output %x:%d is not found in UTXO set", vin.TxId, vin.VOut)
+				}
 
+				if len(updatedOuts.Outputs) == 0 {
+					err := b.Delete(vin.TxId)
+					if err != nil {
+						return err
+					}
+				} else {
+					err := b.Put(vin.TxId, updatedOuts.Serialize())
+					if err != nil {
+						return err
+					}
 				}
 			}
 
-			newOutputs := transaction.TXOutputs{}
-			for _, out := range tx.VOut {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
+			err := checkTransactionLocks(tx, prevBlocks, ctx)
+			if err != nil {
+				return err
 			}
 
-			err := b.Put(tx.ID, newOutputs.Serialize())
+			fee, err := checkTransactionInputs(tx, prevOutputs, ctx)
 			if err != nil {
-				log.Panic(err)
+				return err
+			}
+			fees += fee
+		} else if !tx.IsFinal(ctx) {
+			return ruleError(ErrUnfinalizedTx, "coinbase %x is locked until %d", tx.ID, tx.LockTime)
+		}
+
+		newOutputs := transaction.TXOutputs{Block: ctx}
+		for outIdx, out := range tx.VOut {
+			newOutputs.Outputs = append(newOutputs.Outputs, out)
+			newOutputs.Indexes = append(newOutputs.Indexes, outIdx)
+		}
+
+		err := b.Put(tx.ID, newOutputs.Serialize())
+		if err != nil {
+			return err
+		}
+	}
+
+	err := checkCoinbaseValue(block, fees)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(undoBucket)).Put(block.Hash, undo.Serialize())
+}
+
+/*
+disconnectBlock відкочує транзакції блоку в UTXOset в межах транзакції бази даних.
+Транзакції та їх входи обробляються у зворотному порядку, тому виходи,
+створені й витрачені в межах одного блоку, також відновлюються коректно.
+*/
+func (u UTXOSet) disconnectBlock(tx *bolt.Tx, block *bloks.Block) error {
+	b := tx.Bucket([]byte(utxoBucket))
+	undoBytes := tx.Bucket([]byte(undoBucket)).Get(block.Hash)
+	if undoBytes == nil {
+		return errNoUndoData
+	}
+	undo := DeserializeBlockUndo(undoBytes)
+	spentOutputs := undo.SpentOutputs
+
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		tx := block.Transactions[i]
+
+		err := b.Delete(tx.ID)
+		if err != nil {
+			return err
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		for range tx.VIn {
+			if len(spentOutputs) == 0 {
+				return fmt.Errorf("undo data for block %x is corrupted", block.Hash)
+			}
+			spent := spentOutputs[len(spentOutputs)-1]
+			spentOutputs = spentOutputs[:len(spentOutputs)-1]
+
+			outs := transaction.TXOutputs{Block: spent.Block}
+			if outsBytes := b.Get(spent.TxId); outsBytes != nil {
+				outs = transaction.DeserializeOutputs(outsBytes)
+			}
+
+			// вставляємо вихід на його місце, щоб індекси залишались впорядкованими
+			pos := 0
+			for pos < len(outs.Indexes) && outs.Indexes[pos] < spent.Index {
+				pos++
+			}
+			outs.Outputs = append(outs.Outputs[:pos], append([]transaction.TXOutput{spent.Output}, outs.Outputs[pos:]...)...)
+			outs.Indexes = append(outs.Indexes[:pos], append([]int{spent.Index}, outs.Indexes[pos:]...)...)
+
+			err := b.Put(spent.TxId, outs.Serialize())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Bucket([]byte(undoBucket)).Delete(block.Hash)
+}
+
+/*
+Supply повертає кількість монет в обігу - суму всіх невитрачених виходів у наборі UTXOset.
+*/
+func (u UTXOSet) Supply() int {
+	db := u.Blockchain.Db
+	supply := 0
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for _, v := c.First(); v != nil; _, v = c.Next() {
+			outs := transaction.DeserializeOutputs(v)
+			for _, out := range outs.Outputs {
+				supply += out.Value
 			}
 		}
 
@@ -180,6 +478,8 @@ func (u UTXOSet) Update(block *bloks.Block) {
 	if err != nil {
 		log.Panic(err)
 	}
+
+	return supply
 }
 
 /*
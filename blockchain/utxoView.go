@@ -0,0 +1,172 @@
+package blockchain
+
+import (
+	"blockchain1/transaction"
+	"encoding/hex"
+)
+
+/*
+UTXOView - джерело непотрачених виходів, відносно якого перевіряються та створюються транзакції.
+UTXOSet містить лише підтверджені виходи, а пул транзакцій може накладати на нього
+виходи непідтверджених транзакцій та виключати виходи, які вони вже витратили.
+- FindPrevOutputs повертає виходи, які витрачають входи транзакції,
+або помилку, якщо якогось виходу немає чи він уже витрачений.
+- FindPrevBlocks повертає блоки, які створили виходи, що витрачають входи транзакції,
+для непідтверджених виходів - блок, до якого увійде транзакція.
+- FindUnspentOutputs повертає всі непотрачені виходи, заблоковані скриптом lockingScript.
+*/
+type UTXOView interface {
+	FindPrevOutputs(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.TXOutput, error)
+	FindPrevBlocks(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.SpendContext, error)
+	FindUnspentOutputs(lockingScript []byte) []UnspentOutput
+}
+
+/*
+ValidateTransaction перевіряє транзакцію відносно view: структуру транзакції, часові блокування,
+наявність виходів, які вона витрачає, скрипти входів та суми.
+ctx - блок, до якого увійде транзакція, відносно нього перевіряються часові блокування.
+Повертає комісію транзакції або RuleError з причиною, з якої транзакцію відхилено.
+*/
+func ValidateTransaction(tx *transaction.Transaction, view UTXOView, ctx transaction.SpendContext) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	err := checkTransactionSanity(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	prevOutputs, err := view.FindPrevOutputs(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	// блоки виходів потрібні лише для відносних блокувань, тому їх шукаємо, тільки якщо вони є
+	var prevBlocks map[transaction.OutPoint]transaction.SpendContext
+	if tx.HasRelativeLocks() {
+		prevBlocks, err = view.FindPrevBlocks(tx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = checkTransactionLocks(tx, prevBlocks, ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkTransactionInputs(tx, prevOutputs, ctx)
+}
+
+/*
+blockView накладає на UTXO set транзакції блоку ctx, які вже перевірено,
+тому наступні транзакції блоку можуть витрачати їх виходи.
+*/
+type blockView struct {
+	utxoSet UTXOSet
+	ctx     transaction.SpendContext
+	outputs map[transaction.OutPoint]transaction.TXOutput
+	spent   map[transaction.OutPoint]bool
+}
+
+func newBlockView(utxoSet UTXOSet, ctx transaction.SpendContext) *blockView {
+	return &blockView{
+		utxoSet: utxoSet,
+		ctx:     ctx,
+		outputs: make(map[transaction.OutPoint]transaction.TXOutput),
+		spent:   make(map[transaction.OutPoint]bool),
+	}
+}
+
+/*
+connect позначає виходи, які витрачає tx, потраченими і додає її виходи до view.
+*/
+func (v *blockView) connect(tx *transaction.Transaction) {
+	if !tx.IsCoinbase() {
+		for _, vin := range tx.VIn {
+			v.spent[vin.OutPoint()] = true
+		}
+	}
+
+	txID := hex.EncodeToString(tx.ID)
+	for vout, out := range tx.VOut {
+		v.outputs[transaction.OutPoint{TxId: txID, VOut: vout}] = out
+	}
+}
+
+func (v *blockView) FindPrevOutputs(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.TXOutput, error) {
+	prevOutputs := make(map[transaction.OutPoint]transaction.TXOutput)
+	confirmed := transaction.Transaction{ID: tx.ID}
+
+	for _, vin := range tx.VIn {
+		outPoint := vin.OutPoint()
+
+		if v.spent[outPoint] {
+			return nil, ruleError(ErrDoubleSpend, "output %x:%d is already spent in block", vin.TxId, vin.VOut)
+		}
+		if out, ok := v.outputs[outPoint]; ok {
+			prevOutputs[outPoint] = out
+			continue
+		}
+		confirmed.VIn = append(confirmed.VIn, vin)
+	}
+
+	if len(confirmed.VIn) > 0 {
+		confirmedOutputs, err := v.utxoSet.FindPrevOutputs(&confirmed)
+		if err != nil {
+			return nil, err
+		}
+		for outPoint, out := range confirmedOutputs {
+			prevOutputs[outPoint] = out
+		}
+	}
+
+	return prevOutputs, nil
+}
+
+/*
+FindPrevBlocks повертає для виходів транзакцій цього ж блоку сам блок, а для решти - блоки з UTXO set.
+*/
+func (v *blockView) FindPrevBlocks(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.SpendContext, error) {
+	prevBlocks := make(map[transaction.OutPoint]transaction.SpendContext)
+	confirmed := transaction.Transaction{ID: tx.ID}
+
+	for _, vin := range tx.VIn {
+		if _, ok := v.outputs[vin.OutPoint()]; ok {
+			prevBlocks[vin.OutPoint()] = v.ctx
+			continue
+		}
+		confirmed.VIn = append(confirmed.VIn, vin)
+	}
+
+	if len(confirmed.VIn) > 0 {
+		confirmedBlocks, err := v.utxoSet.FindPrevBlocks(&confirmed)
+		if err != nil {
+			return nil, err
+		}
+		for outPoint, block := range confirmedBlocks {
+			prevBlocks[outPoint] = block
+		}
+	}
+
+	return prevBlocks, nil
+}
+
+func (v *blockView) FindUnspentOutputs(lockingScript []byte) []UnspentOutput {
+	var unspent []UnspentOutput
+
+	for _, utxo := range v.utxoSet.FindUnspentOutputs(lockingScript) {
+		if !v.spent[transaction.OutPoint{TxId: utxo.TxId, VOut: utxo.VOut}] {
+			unspent = append(unspent, utxo)
+		}
+	}
+
+	for outPoint, out := range v.outputs {
+		if out.IsLockedWithScript(lockingScript) && !v.spent[outPoint] {
+			unspent = append(unspent, UnspentOutput{TxId: outPoint.TxId, VOut: outPoint.VOut, Output: out})
+		}
+	}
+
+	return unspent
+}
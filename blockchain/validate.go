@@ -0,0 +1,502 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/chaincfg"
+	"blockchain1/script"
+	"blockchain1/transaction"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+/*
+medianTimeBlocks - кількість останніх блоків, з яких обчислюється медіанний час.
+maxTimeOffset - на скільки секунд час блоку може випереджати час вузла.
+*/
+const (
+	medianTimeBlocks = 11
+	maxTimeOffset    = 2 * 60 * 60
+)
+
+/*
+Помилки перевірки блоків і транзакцій. Кожна RuleError містить одну з них,
+тому причину відхилення можна визначити через errors.Is.
+*/
+var (
+	ErrOrphanBlock        = errors.New("previous block is not found")
+	ErrInvalidAncestor    = errors.New("block descends from invalid block")
+	ErrBadHeight          = errors.New("block height doesn't follow previous block")
+	ErrBadDifficulty      = errors.New("block difficulty bits are wrong")
+	ErrBadBlockHash       = errors.New("block hash doesn't match block data")
+	ErrBadBlockVersion    = errors.New("block version is not allowed")
+	ErrHighHash           = errors.New("block hash is higher than target")
+	ErrBadMerkleRoot      = errors.New("merkle root doesn't match transactions")
+	ErrTimeTooOld         = errors.New("block timestamp is not after median time of previous blocks")
+	ErrTimeTooNew         = errors.New("block timestamp is too far in the future")
+	ErrNoTransactions     = errors.New("block has no transactions")
+	ErrFirstTxNotCoinbase = errors.New("first transaction of block is not coinbase")
+	ErrMultipleCoinbases  = errors.New("block has more than one coinbase transaction")
+	ErrDuplicateTx        = errors.New("block contains duplicate transactions")
+	ErrBadTxID            = errors.New("transaction ID doesn't match transaction data")
+	ErrBadTxStructure     = errors.New("transaction has malformed inputs or outputs")
+	ErrBadTxVersion       = errors.New("transaction version is unknown")
+	ErrDoubleSpend        = errors.New("output is spent more than once")
+	ErrMissingInput       = errors.New("transaction spends unknown or already spent output")
+	ErrBadScript          = errors.New("transaction input doesn't satisfy locking script of spent output")
+	ErrSpendTooHigh       = errors.New("transaction spends more than its inputs")
+	ErrUnfinalizedTx      = errors.New("transaction lock time is not reached")
+	ErrSequenceLocked     = errors.New("transaction input relative lock time is not reached")
+	ErrBadCoinbaseValue   = errors.New("coinbase pays more than block subsidy and fees")
+)
+
+/*
+RuleError описує порушення правил консенсусу.
+- Err - одна з помилок Err*, яка визначає причину.
+- Description - опис конкретного порушення.
+*/
+type RuleError struct {
+	Err         error
+	Description string
+}
+
+func (e RuleError) Error() string {
+	return e.Description
+}
+
+func (e RuleError) Unwrap() error {
+	return e.Err
+}
+
+func ruleError(err error, format string, args ...interface{}) error {
+	return RuleError{
+		Err:         err,
+		Description: fmt.Sprintf(format, args...),
+	}
+}
+
+/*
+ValidateBlock перевіряє блок перед додаванням до бази даних:
+заголовок ( див. validateHeader ), корінь дерева Меркла та структуру транзакцій.
+Входи транзакцій перевіряються відносно UTXO set під час приєднання блоку до основного ланцюга.
+*/
+func (bc *Blockchain) ValidateBlock(block *bloks.Block) error {
+	return bc.Db.View(func(tx *bolt.Tx) error {
+		return validateBlock(tx, block)
+	})
+}
+
+/*
+validateBlock виконує перевірки ValidateBlock в межах транзакції бази даних.
+Крім заголовка попереднього блоку, в базі даних має бути і його тіло:
+блок, попередник якого ще завантажується, не можна буде приєднати до ланцюга.
+*/
+func validateBlock(tx *bolt.Tx, block *bloks.Block) error {
+	err := validateHeader(tx, &block.BlockHeader, block.Height)
+	if err != nil {
+		return err
+	}
+
+	if !hasBlock(tx, block.PrevBlockHash) {
+		return ruleError(ErrOrphanBlock, "previous block %x of block %x is not downloaded yet", block.PrevBlockHash, block.Hash)
+	}
+
+	return checkBlockTransactions(block)
+}
+
+/*
+CheckOrphanBlock перевіряє блок, попередника якого ще немає в базі даних, тим, що не залежить від попередника:
+ціль з Bits не більша за PowLimit мережі, хеш заголовка менший за цю ціль і структуру транзакцій.
+Складність і час блоку перевіряються, коли надійде попередник, але підробити блок без доказу роботи не вийде.
+*/
+func CheckOrphanBlock(block *bloks.Block) error {
+	hash := block.BlockHash()
+
+	target := bloks.CompactToBig(block.TargetBits())
+	if target.Sign() <= 0 || target.Cmp(chaincfg.ActiveNetParams.PowLimit) > 0 {
+		return ruleError(ErrBadDifficulty, "block %x has difficulty bits %08x out of range", hash, block.TargetBits())
+	}
+
+	if new(big.Int).SetBytes(hash).Cmp(target) >= 0 {
+		return ruleError(ErrHighHash, "block %x hash is higher than target", hash)
+	}
+
+	return checkBlockTransactions(block)
+}
+
+/*
+validateHeader перевіряє заголовок блоку на висоті height без його транзакцій:
+зв'язок з попереднім блоком, висоту, версію, складність, доказ роботи і час.
+Попередній заголовок має бути в базі даних.
+*/
+func validateHeader(tx *bolt.Tx, header *bloks.BlockHeader, height int) error {
+	hash := header.BlockHash()
+
+	parent, err := readHeader(tx, header.PrevBlockHash)
+	if len(header.PrevBlockHash) == 0 || err != nil {
+		return ruleError(ErrOrphanBlock, "previous block %x of block %x is not found", header.PrevBlockHash, hash)
+	}
+
+	if height != parent.Height+1 {
+		return ruleError(ErrBadHeight, "block %x has height %d, expected %d", hash, height, parent.Height+1)
+	}
+
+	// legacy заголовки дозволені лише в ланцюгах, добутих до появи BlockHeader
+	if header.Version < parent.Version || header.Version > bloks.BlockVersion {
+		return ruleError(ErrBadBlockVersion, "block %x has version %d", hash, header.Version)
+	}
+
+	err = checkProofOfWork(tx, header, hash, parent)
+	if err != nil {
+		return err
+	}
+
+	medianTime, err := medianTimePast(tx, parent)
+	if err != nil {
+		return err
+	}
+	if header.Timestamp <= medianTime {
+		return ruleError(ErrTimeTooOld, "block %x timestamp %d is not after median time %d", hash, header.Timestamp, medianTime)
+	}
+	if header.Timestamp > time.Now().Unix()+maxTimeOffset {
+		return ruleError(ErrTimeTooNew, "block %x timestamp %d is too far in the future", hash, header.Timestamp)
+	}
+
+	return nil
+}
+
+/*
+checkProofOfWork перевіряє, що заголовок з хешем hash добуто з правильною складністю
+і що хеш менший за ціль доказу роботи.
+*/
+func checkProofOfWork(tx *bolt.Tx, header *bloks.BlockHeader, hash []byte, parent *IndexedHeader) error {
+	expectedBits, err := calcNextBits(tx, parent)
+	if err != nil {
+		return err
+	}
+
+	if header.TargetBits() != expectedBits {
+		return ruleError(ErrBadDifficulty, "block %x has difficulty bits %08x, expected %08x", hash, header.TargetBits(), expectedBits)
+	}
+
+	if new(big.Int).SetBytes(hash).Cmp(bloks.CompactToBig(header.TargetBits())) >= 0 {
+		return ruleError(ErrHighHash, "block %x hash is higher than target", hash)
+	}
+
+	return nil
+}
+
+/*
+medianTimePast повертає медіанний час останніх medianTimeBlocks блоків, закінчуючи блоком parent.
+*/
+func medianTimePast(tx *bolt.Tx, parent *IndexedHeader) (int64, error) {
+	var timestamps []int64
+
+	header := parent
+	for {
+		timestamps = append(timestamps, header.Timestamp)
+		if len(timestamps) == medianTimeBlocks || len(header.PrevBlockHash) == 0 {
+			break
+		}
+
+		var err error
+		header, err = readHeader(tx, header.PrevBlockHash)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	return timestamps[len(timestamps)/2], nil
+}
+
+/*
+spendContext повертає контекст витрати виходів транзакціями блоку, наступного за parent.
+*/
+func spendContext(tx *bolt.Tx, parent *IndexedHeader) (transaction.SpendContext, error) {
+	medianTime, err := medianTimePast(tx, parent)
+	if err != nil {
+		return transaction.SpendContext{}, err
+	}
+
+	return transaction.SpendContext{Height: parent.Height + 1, MedianTime: medianTime}, nil
+}
+
+/*
+checkBlockTransactions перевіряє, що хеш блоку відповідає заголовку, і транзакції блоку без звернення до UTXO set:
+перша і лише перша транзакція є coinbase, транзакції не повторюються,
+їх ідентифікатори відповідають вмісту, жоден вихід не витрачається двічі
+і корінь дерева Меркла збігається з транзакціями.
+*/
+func checkBlockTransactions(block *bloks.Block) error {
+	if !bytes.Equal(block.Hash, block.BlockHash()) {
+		return ruleError(ErrBadBlockHash, "block %x has hash %x computed from its header", block.Hash, block.BlockHash())
+	}
+
+	if len(block.Transactions) == 0 {
+		return ruleError(ErrNoTransactions, "block %x has no transactions", block.Hash)
+	}
+
+	if !block.Transactions[0].IsCoinbase() {
+		return ruleError(ErrFirstTxNotCoinbase, "first transaction of block %x is not coinbase", block.Hash)
+	}
+
+	txIDs := make(map[string]bool)
+	spent := make(map[transaction.OutPoint]bool)
+
+	for i, tx := range block.Transactions {
+		if i > 0 && tx.IsCoinbase() {
+			return ruleError(ErrMultipleCoinbases, "block %x has coinbase transaction at index %d", block.Hash, i)
+		}
+
+		err := checkTransactionSanity(tx)
+		if err != nil {
+			return err
+		}
+
+		txID := string(tx.ID)
+		if txIDs[txID] {
+			return ruleError(ErrDuplicateTx, "transaction %x is included in block %x more than once", tx.ID, block.Hash)
+		}
+		txIDs[txID] = true
+
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		for _, vin := range tx.VIn {
+			outPoint := vin.OutPoint()
+			if spent[outPoint] {
+				return ruleError(ErrDoubleSpend, "output %s:%d is spent more than once in block %x", outPoint.TxId, outPoint.VOut, block.Hash)
+			}
+			spent[outPoint] = true
+		}
+	}
+
+	if !bytes.Equal(block.MerkleRoot, block.HashTransactions()) {
+		return ruleError(ErrBadMerkleRoot, "block %x merkle root doesn't match its transactions", block.Hash)
+	}
+
+	return nil
+}
+
+/*
+checkTransactionSanity перевіряє транзакцію без звернення до UTXO set:
+відому версію, наявність входів і виходів, невід'ємні суми, поля блокування її версії
+та відповідність ідентифікатора вмісту.
+*/
+func checkTransactionSanity(tx *transaction.Transaction) error {
+	if tx.Version < transaction.LegacyTxVersion || tx.Version > transaction.TxVersion {
+		return ruleError(ErrBadTxVersion, "transaction %x has unknown version %d", tx.ID, tx.Version)
+	}
+
+	if len(tx.VIn) == 0 || len(tx.VOut) == 0 {
+		return ruleError(ErrBadTxStructure, "transaction %x has no inputs or outputs", tx.ID)
+	}
+
+	for _, out := range tx.VOut {
+		if out.Value < 0 {
+			return ruleError(ErrBadTxStructure, "transaction %x has output with negative value", tx.ID)
+		}
+	}
+
+	if !tx.IsCoinbase() {
+		for _, vin := range tx.VIn {
+			if vin.VOut < 0 {
+				return ruleError(ErrBadTxStructure, "transaction %x has input with negative output index", tx.ID)
+			}
+		}
+	}
+
+	err := checkScriptFields(tx)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		return ruleError(ErrBadTxID, "transaction %x ID doesn't match its data", tx.ID)
+	}
+
+	return nil
+}
+
+/*
+checkScriptFields перевіряє, що транзакція блокує виходи і розблоковує входи полями своєї версії:
+Script і ScriptSig, починаючи з transaction.ScriptTxVersion, а PubKeyHash, Signature і PubKey - до неї.
+Скрипти не можуть перевищувати script.MaxScriptSize.
+*/
+func checkScriptFields(tx *transaction.Transaction) error {
+	scripts := tx.Version >= transaction.ScriptTxVersion
+
+	for _, out := range tx.VOut {
+		if scripts && len(out.PubKeyHash) > 0 || !scripts && len(out.Script) > 0 {
+			return ruleError(ErrBadTxStructure, "transaction %x version %d has output with wrong locking fields", tx.ID, tx.Version)
+		}
+		if len(out.Script) > script.MaxScriptSize {
+			return ruleError(ErrBadTxStructure, "transaction %x has too big output script", tx.ID)
+		}
+	}
+
+	for _, vin := range tx.VIn {
+		if scripts && (len(vin.Signature) > 0 || len(vin.PubKey) > 0) || !scripts && len(vin.ScriptSig) > 0 {
+			return ruleError(ErrBadTxStructure, "transaction %x version %d has input with wrong unlocking fields", tx.ID, tx.Version)
+		}
+		if len(vin.ScriptSig) > script.MaxScriptSize {
+			return ruleError(ErrBadTxStructure, "transaction %x has too big input script", tx.ID)
+		}
+	}
+
+	return nil
+}
+
+/*
+checkTransactionInputs перевіряє транзакцію відносно виходів, які вона витрачає:
+скрипти входів ( підписи та часові блокування відносно блоку ctx ) та те, що сума виходів не перевищує суму входів.
+Повертає комісію транзакції - різницю між сумою входів і сумою виходів.
+*/
+func checkTransactionInputs(tx *transaction.Transaction, prevOutputs map[transaction.OutPoint]transaction.TXOutput, ctx transaction.SpendContext) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	fee, err := calcFee(tx, prevOutputs)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Verify(prevOutputs, ctx)
+	if err != nil {
+		return 0, ruleError(ErrBadScript, "transaction %x %v", tx.ID, err)
+	}
+
+	return fee, nil
+}
+
+/*
+checkTransactionLocks перевіряє часові блокування транзакції відносно блоку ctx: LockTime транзакції
+і відносні блокування входів, які відраховуються від блоків prevBlocks, що створили витрачені виходи.
+*/
+func checkTransactionLocks(tx *transaction.Transaction, prevBlocks map[transaction.OutPoint]transaction.SpendContext, ctx transaction.SpendContext) error {
+	if !tx.IsFinal(ctx) {
+		return ruleError(ErrUnfinalizedTx, "transaction %x lock time %d is not reached", tx.ID, tx.LockTime)
+	}
+
+	err := tx.CheckSequenceLocks(prevBlocks, ctx)
+	if err != nil {
+		return ruleError(ErrSequenceLocked, "transaction %x %v", tx.ID, err)
+	}
+
+	return nil
+}
+
+/*
+calcFee обчислює комісію транзакції за виходами, які вона витрачає.
+*/
+func calcFee(tx *transaction.Transaction, prevOutputs map[transaction.OutPoint]transaction.TXOutput) (int, error) {
+	inputValue := 0
+	for _, vin := range tx.VIn {
+		prevOut, ok := prevOutputs[vin.OutPoint()]
+		if !ok {
+			return 0, ruleError(ErrMissingInput, "transaction %x spends unknown output %x:%d", tx.ID, vin.TxId, vin.VOut)
+		}
+		inputValue += prevOut.Value
+	}
+
+	outputValue := 0
+	for _, out := range tx.VOut {
+		outputValue += out.Value
+	}
+
+	if outputValue > inputValue {
+		return 0, ruleError(ErrSpendTooHigh, "transaction %x spends %d, but its inputs have only %d", tx.ID, outputValue, inputValue)
+	}
+
+	return inputValue - outputValue, nil
+}
+
+/*
+checkCoinbaseValue перевіряє, що coinbase транзакція блоку не виплачує більше,
+ніж винагорода за блок разом з комісіями його транзакцій.
+*/
+func checkCoinbaseValue(block *bloks.Block, fees int) error {
+	coinbaseValue := 0
+	for _, out := range block.Transactions[0].VOut {
+		coinbaseValue += out.Value
+	}
+
+	allowed := transaction.CalcBlockSubsidy(block.Height) + fees
+	if coinbaseValue > allowed {
+		return ruleError(ErrBadCoinbaseValue, "coinbase of block %x pays %d, but only %d is allowed", block.Hash, coinbaseValue, allowed)
+	}
+
+	return nil
+}
+
+/*
+checkInvalidAncestor відхиляє блок з хешем hash, якщо він або його попередник раніше не пройшов перевірку.
+Нащадки недійсного блоку теж запам'ятовуються як недійсні.
+*/
+func (bc *Blockchain) checkInvalidAncestor(hash []byte, prevHash []byte) error {
+	if !bc.invalidBlocks[hex.EncodeToString(hash)] && !bc.invalidBlocks[hex.EncodeToString(prevHash)] {
+		return nil
+	}
+
+	bc.invalidBlocks[hex.EncodeToString(hash)] = true
+
+	return ruleError(ErrInvalidAncestor, "block %x is invalid or descends from invalid block", hash)
+}
+
+/*
+blockIsInvalid повідомляє, чи означає помилка err, що блок недійсний незалежно від того, хто і коли його передасть.
+Помилки в транзакціях, які не відповідають кореню дерева Меркла, такими не є:
+заголовок може бути дійсним, а транзакції - підміненими іншим вузлом.
+Блок з часом з майбутнього і блок, попередник якого ще не завантажено, можуть стати дійсними пізніше.
+*/
+func blockIsInvalid(block *bloks.Block, err error) bool {
+	var ruleErr RuleError
+	if !errors.As(err, &ruleErr) {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, ErrBadDifficulty), errors.Is(err, ErrHighHash), errors.Is(err, ErrBadBlockVersion),
+		errors.Is(err, ErrTimeTooOld), errors.Is(err, ErrInvalidAncestor):
+		return true
+	case errors.Is(err, ErrOrphanBlock), errors.Is(err, ErrTimeTooNew), errors.Is(err, ErrBadHeight),
+		errors.Is(err, ErrBadBlockHash), errors.Is(err, ErrNoTransactions), errors.Is(err, ErrDuplicateTx):
+		return false
+	}
+
+	return bytes.Equal(block.MerkleRoot, block.HashTransactions())
+}
+
+/*
+invalidateBlock запам'ятовує недійсний блок, щоб не приймати ні його, ні його нащадків.
+Якщо найкращий заголовок походить від цього блоку, найкращим знову стає вершина основного ланцюга,
+інакше вузол без кінця завантажував би недійсний ланцюг. Недійсні блоки запам'ятовуються лише в пам'яті.
+*/
+func (bc *Blockchain) invalidateBlock(block *bloks.Block) error {
+	bc.invalidBlocks[hex.EncodeToString(block.Hash)] = true
+
+	return bc.Db.Update(func(tx *bolt.Tx) error {
+		if !hasHeader(tx, block.Hash) {
+			return nil
+		}
+
+		best, err := readBestHeader(tx)
+		for err == nil && best.Height > block.Height {
+			best, err = readHeader(tx, best.PrevBlockHash)
+		}
+		if err != nil || !bytes.Equal(best.Hash, block.Hash) {
+			return err
+		}
+
+		return tx.Bucket([]byte(headersBucket)).Put([]byte(bestHeaderKey), bc.tip)
+	})
+}
@@ -0,0 +1,205 @@
+package blockchain
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/script"
+	"blockchain1/transaction"
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+/*
+headerBlock добуває блок над parent з coinbase транзакцією на address, заголовок якого перед добуванням
+змінює change. Так будуються блоки з дійсним доказом роботи, але з порушенням правил заголовка.
+*/
+func headerBlock(t *testing.T, parent *bloks.Block, address string, change func(block *bloks.Block)) *bloks.Block {
+	t.Helper()
+
+	coinbase := transaction.NewCoinbaseTX(address, "", parent.Height+1, 0)
+	block := bloks.NewBlockTemplate([]*transaction.Transaction{coinbase}, parent.Hash, parent.Height+1, parent.Bits, parent.Timestamp+1)
+	change(block)
+
+	_, err := block.Mine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return block
+}
+
+/*
+bodyBlock повертає добутий над parent блок, транзакції якого після добування замінено на txs.
+Хеш і доказ роботи залишаються дійсними, тому блок порушує лише правила транзакцій.
+*/
+func bodyBlock(parent *bloks.Block, address string, txs ...*transaction.Transaction) *bloks.Block {
+	block := testBlock(parent, address)
+	block.Transactions = txs
+
+	return block
+}
+
+/*
+spendTx будує транзакцію, яка витрачає вихід vout транзакції prev і переказує value на address.
+Підпис не потрібен: правила, які перевіряє тест, не звертаються до UTXO set.
+*/
+func spendTx(prev *transaction.Transaction, vout int, value int, address string, change func(tx *transaction.Transaction)) *transaction.Transaction {
+	tx := &transaction.Transaction{
+		Version: transaction.TxVersion,
+		VIn:     []transaction.TXInput{{TxId: prev.ID, VOut: vout, ScriptSig: []byte{script.Op1}, Sequence: transaction.MaxSequence}},
+		VOut:    []transaction.TXOutput{*transaction.NewTXOutput(value, address)},
+	}
+	if change != nil {
+		change(tx)
+	}
+	tx.ID = tx.Hash()
+
+	return tx
+}
+
+func TestValidateBlock(t *testing.T) {
+	bc, address := newTestChain(t)
+	tip := tipBlock(t, bc)
+	coinbase := tip.Transactions[0]
+
+	// заголовок відомий, але тіло блоку ще не завантажено
+	headerOnly := testBlock(tip, address)
+	_, err := bc.AddHeaders([]*bloks.BlockHeader{&headerOnly.BlockHeader})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCoinbase := func() *transaction.Transaction {
+		return transaction.NewCoinbaseTX(address, "", tip.Height+1, 0)
+	}
+	spend := spendTx(coinbase, 0, 10, address, nil)
+
+	tests := []struct {
+		name  string
+		block func() *bloks.Block
+		err   error
+	}{
+		{"valid", func() *bloks.Block {
+			return testBlock(tip, address, spend)
+		}, nil},
+		{"unknown parent", func() *bloks.Block {
+			return headerBlock(t, tip, address, func(block *bloks.Block) {
+				block.PrevBlockHash = bytes.Repeat([]byte{1}, 32)
+			})
+		}, ErrOrphanBlock},
+		{"parent body is missing", func() *bloks.Block {
+			return testBlock(headerOnly, address)
+		}, ErrOrphanBlock},
+		{"wrong height", func() *bloks.Block {
+			block := testBlock(tip, address)
+			block.Height++
+			return block
+		}, ErrBadHeight},
+		{"legacy version after new header", func() *bloks.Block {
+			return headerBlock(t, tip, address, func(block *bloks.Block) {
+				block.Version = bloks.LegacyBlockVersion
+			})
+		}, ErrBadBlockVersion},
+		{"unknown version", func() *bloks.Block {
+			return headerBlock(t, tip, address, func(block *bloks.Block) {
+				block.Version = bloks.BlockVersion + 1
+			})
+		}, ErrBadBlockVersion},
+		{"wrong difficulty", func() *bloks.Block {
+			return headerBlock(t, tip, address, func(block *bloks.Block) {
+				block.Bits = bloks.BigToCompact(new(big.Int).Rsh(bloks.CompactToBig(tip.Bits), 1))
+			})
+		}, ErrBadDifficulty},
+		{"hash above target", func() *bloks.Block {
+			block := bloks.NewBlockTemplate([]*transaction.Transaction{newCoinbase()}, tip.Hash, tip.Height+1, tip.Bits, tip.Timestamp+1)
+			for new(big.Int).SetBytes(block.BlockHash()).Cmp(bloks.CompactToBig(block.Bits)) < 0 {
+				block.Nonce++
+			}
+			block.Hash = block.BlockHash()
+			return block
+		}, ErrHighHash},
+		{"timestamp not after median time", func() *bloks.Block {
+			return headerBlock(t, tip, address, func(block *bloks.Block) {
+				block.Timestamp = tip.Timestamp
+			})
+		}, ErrTimeTooOld},
+		{"timestamp in future", func() *bloks.Block {
+			return headerBlock(t, tip, address, func(block *bloks.Block) {
+				block.Timestamp = time.Now().Unix() + maxTimeOffset + 60
+			})
+		}, ErrTimeTooNew},
+		{"hash doesn't match header", func() *bloks.Block {
+			block := testBlock(tip, address)
+			block.Hash = tip.Hash
+			return block
+		}, ErrBadBlockHash},
+		{"invalid transaction", func() *bloks.Block {
+			return bodyBlock(tip, address, newCoinbase(), newCoinbase())
+		}, ErrMultipleCoinbases},
+	}
+
+	for _, test := range tests {
+		err := bc.ValidateBlock(test.block())
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestCheckBlockTransactions(t *testing.T) {
+	bc, address := newTestChain(t)
+	tip := tipBlock(t, bc)
+	coinbase := tip.Transactions[0]
+	newCoinbase := func() *transaction.Transaction {
+		return transaction.NewCoinbaseTX(address, "", tip.Height+1, 0)
+	}
+	spend := spendTx(coinbase, 0, 10, address, nil)
+
+	tests := []struct {
+		name  string
+		block *bloks.Block
+		err   error
+	}{
+		{"valid", testBlock(tip, address, spend), nil},
+		{"hash doesn't match header", func() *bloks.Block {
+			block := testBlock(tip, address)
+			block.Nonce++
+			return block
+		}(), ErrBadBlockHash},
+		{"no transactions", bodyBlock(tip, address), ErrNoTransactions},
+		{"first transaction is not coinbase", bodyBlock(tip, address, spend), ErrFirstTxNotCoinbase},
+		{"second coinbase", bodyBlock(tip, address, newCoinbase(), spend, newCoinbase()), ErrMultipleCoinbases},
+		{"duplicate transaction", bodyBlock(tip, address, newCoinbase(), spend, spend), ErrDuplicateTx},
+		{"transaction ID doesn't match data", bodyBlock(tip, address, newCoinbase(), func() *transaction.Transaction {
+			tx := spendTx(coinbase, 0, 10, address, nil)
+			tx.VOut[0].Value++
+			return tx
+		}()), ErrBadTxID},
+		{"unknown transaction version", bodyBlock(tip, address, newCoinbase(), spendTx(coinbase, 0, 10, address, func(tx *transaction.Transaction) {
+			tx.Version = transaction.TxVersion + 1
+		})), ErrBadTxVersion},
+		{"no outputs", bodyBlock(tip, address, newCoinbase(), spendTx(coinbase, 0, 10, address, func(tx *transaction.Transaction) {
+			tx.VOut = nil
+		})), ErrBadTxStructure},
+		{"negative output value", bodyBlock(tip, address, newCoinbase(), spendTx(coinbase, 0, -1, address, nil)), ErrBadTxStructure},
+		{"negative input index", bodyBlock(tip, address, newCoinbase(), spendTx(coinbase, -2, 10, address, nil)), ErrBadTxStructure},
+		{"legacy locking field in script transaction", bodyBlock(tip, address, newCoinbase(), spendTx(coinbase, 0, 10, address, func(tx *transaction.Transaction) {
+			tx.VOut[0].PubKeyHash = make([]byte, 20)
+		})), ErrBadTxStructure},
+		{"too big input script", bodyBlock(tip, address, newCoinbase(), spendTx(coinbase, 0, 10, address, func(tx *transaction.Transaction) {
+			tx.VIn[0].ScriptSig = make([]byte, script.MaxScriptSize+1)
+		})), ErrBadTxStructure},
+		{"output spent twice", bodyBlock(tip, address, newCoinbase(), spend, spendTx(coinbase, 0, 20, address, nil)), ErrDoubleSpend},
+		{"wrong merkle root", bodyBlock(tip, address, newCoinbase(), spend), ErrBadMerkleRoot},
+	}
+
+	for _, test := range tests {
+		err := checkBlockTransactions(test.block)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
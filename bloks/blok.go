@@ -4,26 +4,26 @@ import (
 	"blockchain1/merkleTree"
 	"blockchain1/transaction"
 	"bytes"
-	"encoding/gob"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 )
 
 /*
 Block : структура, що представляє блок у блокчейні.
-- Timestamp - час створення блока.
+- BlockHeader - заголовок блоку, з якого обчислюється його хеш.
 - Transactions - масив транзакцій фактично представляє собою дані, які будуть зберігатися в блоку.
-- PrevBlockHash - зберігає хеш попереднього блоку.
 - Hash - містить хеш поточного блоку.
-- Nonce - використовується для доказу роботи.
+- Height - висота блоку в ланцюгу.
 */
 type Block struct {
-	Timestamp     int64
-	Transactions  []*transaction.Transaction
-	PrevBlockHash []byte
-	Hash          []byte
-	Nonce         int
-	Height        int
+	BlockHeader
+	Transactions []*transaction.Transaction
+	Hash         []byte
+	Height       int
 }
 
 /*
@@ -34,61 +34,112 @@ func NewGenesisBlock(coinbase *transaction.Transaction) *Block {
 		[]*transaction.Transaction{coinbase},
 		[]byte{},
 		0,
+		PowLimitBits(),
+		time.Now().Unix(),
 	)
 }
 
 /*
 NewBlock : використовується для створення нового блоку.
 */
-func NewBlock(transaction []*transaction.Transaction, prevBlockHash []byte, height int) *Block {
-	block := &Block{
-		Timestamp:     time.Now().Unix(),
-		Transactions:  transaction,
-		PrevBlockHash: prevBlockHash,
-		Hash:          []byte{},
-		Nonce:         0,
-		Height:        height,
+func NewBlock(transaction []*transaction.Transaction, prevBlockHash []byte, height int, bits uint32, timestamp int64) *Block {
+	block := NewBlockTemplate(transaction, prevBlockHash, height, bits, timestamp)
+
+	_, err := block.Mine(context.Background())
+	if err != nil {
+		log.Panic(err)
 	}
 
-	pow := NewProofOfWork(block)
-	nonce, hash := pow.Run()
+	return block
+}
 
-	block.Hash = hash[:]
-	block.Nonce = nonce
+/*
+NewBlockTemplate : створює блок без доказу роботи. Щоб блок став дійсним, для нього потрібно викликати Mine.
+*/
+func NewBlockTemplate(transaction []*transaction.Transaction, prevBlockHash []byte, height int, bits uint32, timestamp int64) *Block {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:       BlockVersion,
+			PrevBlockHash: prevBlockHash,
+			Timestamp:     timestamp,
+			Bits:          bits,
+			Nonce:         0,
+		},
+		Transactions: transaction,
+		Hash:         []byte{},
+		Height:       height,
+	}
+	block.MerkleRoot = block.HashTransactions()
 
 	return block
 }
 
 /*
-Serialize : використовується для серіалізації блоку.
+MiningStats : статистика добування блоку.
+- Hashes - скільки хешів обчислено.
+- Duration - скільки тривало добування.
+- ExtraNonce - скільки разів довелося змінити extra nonce у coinbase транзакції.
 */
-func (b *Block) Serialize() []byte {
-	var result bytes.Buffer
-
-	encoder := gob.NewEncoder(&result)
+type MiningStats struct {
+	Hashes     uint64
+	Duration   time.Duration
+	ExtraNonce uint64
+}
 
-	err := encoder.Encode(b)
-	if err != nil {
-		log.Fatal(" Can't serialize block ", err)
+/*
+HashRate : повертає кількість хешів за секунду.
+*/
+func (s MiningStats) HashRate() float64 {
+	if s.Duration <= 0 {
+		return 0
 	}
 
-	return result.Bytes()
+	return float64(s.Hashes) / s.Duration.Seconds()
 }
 
 /*
-DeserializeBlock : використовується для десеріалізації блоку.
+Mine : шукає nonce, з яким хеш блоку менший за ціль, і записує його та хеш у блок.
+Якщо всі значення nonce перебрано, до даних coinbase транзакції дописується extra nonce,
+корінь дерева Меркла перераховується і пошук починається знову.
+Якщо ctx скасовано раніше, повертається ctx.Err(), а блок не вважається добутим.
 */
-func DeserializeBlock(d []byte) *Block {
-	var block Block
-
-	decoder := gob.NewDecoder(bytes.NewReader(d))
+func (b *Block) Mine(ctx context.Context) (MiningStats, error) {
+	var stats MiningStats
+	start := time.Now()
 
-	err := decoder.Decode(&block)
-	if err != nil {
-		log.Fatal(" Can't deserialize block ", err)
+	var coinbaseData []byte
+	if len(b.Transactions) > 0 && b.Transactions[0].IsCoinbase() {
+		coinbaseData = b.Transactions[0].CoinbaseData()
 	}
 
-	return &block
+	for {
+		pow := NewProofOfWork(&b.BlockHeader)
+		nonce, hash, err := pow.RunContext(ctx)
+		stats.Hashes += pow.Hashes
+		stats.Duration = time.Since(start)
+
+		if errors.Is(err, ErrNonceSpaceExhausted) && coinbaseData != nil {
+			stats.ExtraNonce++
+
+			data := make([]byte, len(coinbaseData), len(coinbaseData)+8)
+			copy(data, coinbaseData)
+			data = binary.BigEndian.AppendUint64(data, stats.ExtraNonce)
+
+			b.Transactions[0].SetCoinbaseData(data)
+			b.MerkleRoot = b.HashTransactions()
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		b.Hash = hash[:]
+		b.Nonce = nonce
+
+		fmt.Printf("Block is mined in %s: %d hashes, %.0f hashes/s\n", stats.Duration.Round(time.Millisecond), stats.Hashes, stats.HashRate())
+
+		return stats, nil
+	}
 }
 
 /*
@@ -97,8 +148,15 @@ HashTransactions : використовується для обчислення
 func (b *Block) HashTransactions() []byte {
 	var transactions [][]byte
 
-	for _, tx := range b.Transactions {
-		transactions = append(transactions, tx.Serialize())
+	if b.Version == LegacyBlockVersion {
+		// старий вузол кодував legacy транзакції gob, результат якого залежить від процесу
+		transactions, _ = transaction.LegacyFingerprints(b.Transactions, func(fingerprints [][]byte) bool {
+			return bytes.Equal(merkleTree.NewMerkleTree(fingerprints).RootNode.Data, b.MerkleRoot)
+		})
+	} else {
+		for _, tx := range b.Transactions {
+			transactions = append(transactions, tx.Fingerprint())
+		}
 	}
 
 	mTree := merkleTree.NewMerkleTree(transactions)
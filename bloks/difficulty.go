@@ -0,0 +1,91 @@
+package bloks
+
+import (
+	"blockchain1/chaincfg"
+	"math/big"
+)
+
+/*
+maxRetargetFactor - у скільки разів складність може змінитися за один перерахунок.
+*/
+const maxRetargetFactor = 4
+
+/*
+PowLimitBits повертає найбільшу допустиму ціль активної мережі у компактному форматі,
+з нею добувається genesis блок.
+*/
+func PowLimitBits() uint32 {
+	return BigToCompact(chaincfg.ActiveNetParams.PowLimit)
+}
+
+/*
+CompactToBig : перетворює ціль з компактного формату Bits у велике число.
+Як і в Біткоїні, старший байт - це довжина числа в байтах, а три молодші - його старші байти.
+*/
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := int64(compact & 0x007fffff)
+	exponent := uint(compact >> 24)
+
+	if exponent <= 3 {
+		return big.NewInt(mantissa >> (8 * (3 - exponent)))
+	}
+
+	target := big.NewInt(mantissa)
+
+	return target.Lsh(target, 8*(exponent-3))
+}
+
+/*
+BigToCompact : перетворює ціль у компактний формат Bits, який зберігається в блоці.
+*/
+func BigToCompact(target *big.Int) uint32 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+
+	var mantissa uint32
+	exponent := uint(len(target.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(target.Uint64()) << (8 * (3 - exponent))
+	} else {
+		shifted := new(big.Int).Rsh(target, 8*(exponent-3))
+		mantissa = uint32(shifted.Uint64())
+	}
+
+	// старший біт мантиси означає від'ємне число, тому зсуваємо її на байт
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	return uint32(exponent<<24) | mantissa
+}
+
+/*
+CalcNextBits : обчислює складність наступного інтервалу.
+Ціль змінюється пропорційно відношенню фактичного часу добування останніх блоків до очікуваного,
+але не більше ніж у maxRetargetFactor разів і не вище за PowLimit активної мережі.
+*/
+func CalcNextBits(lastBits uint32, actualTimespan int64, expectedTimespan int64) uint32 {
+	if expectedTimespan <= 0 {
+		return lastBits
+	}
+
+	if actualTimespan < expectedTimespan/maxRetargetFactor {
+		actualTimespan = expectedTimespan / maxRetargetFactor
+	}
+	if actualTimespan > expectedTimespan*maxRetargetFactor {
+		actualTimespan = expectedTimespan * maxRetargetFactor
+	}
+
+	newTarget := CompactToBig(lastBits)
+	newTarget.Mul(newTarget, big.NewInt(actualTimespan))
+	newTarget.Div(newTarget, big.NewInt(expectedTimespan))
+
+	powLimit := chaincfg.ActiveNetParams.PowLimit
+	if newTarget.Cmp(powLimit) > 0 {
+		newTarget.Set(powLimit)
+	}
+
+	return BigToCompact(newTarget)
+}
@@ -0,0 +1,139 @@
+package bloks
+
+import (
+	"blockchain1/lib/serialize"
+	"blockchain1/transaction"
+	"fmt"
+	"log"
+)
+
+/*
+blockEncodingVersion - версія канонічного кодування блоку, з якої починаються закодовані дані.
+legacyEncodingVersion - попередня версія, в якій поля заголовка кодувалися окремо разом з хешем блоку,
+її блоки розбираються лише під час міграції бази даних.
+bodyEncodingVersion - версія кодування тіла блоку, тобто його транзакцій без заголовка.
+*/
+const (
+	blockEncodingVersion  = 2
+	legacyEncodingVersion = 1
+	bodyEncodingVersion   = 1
+)
+
+/*
+Serialize : повертає канонічне бінарне кодування блоку, в якому він передається між вузлами:
+версія кодування, закодований заголовок, висота, кількість транзакцій і транзакції, кожна з префіксом довжини.
+Хеш блоку не кодується, бо обчислюється з заголовка.
+*/
+func (b *Block) Serialize() []byte {
+	var w serialize.Writer
+
+	w.WriteUvarint(blockEncodingVersion)
+	w.WriteBytes(b.BlockHeader.Serialize())
+	w.WriteVarint(int64(b.Height))
+	writeTransactions(&w, b.Transactions)
+
+	return w.Bytes()
+}
+
+/*
+SerializeBody : повертає кодування тіла блоку - транзакцій, - яке зберігається окремо від заголовка.
+*/
+func (b *Block) SerializeBody() []byte {
+	var w serialize.Writer
+
+	w.WriteUvarint(bodyEncodingVersion)
+	writeTransactions(&w, b.Transactions)
+
+	return w.Bytes()
+}
+
+/*
+DecodeBlock : розбирає блок, закодований Serialize.
+Повертає помилку, якщо дані пошкоджені, мають невідому версію кодування або містять зайві байти.
+*/
+func DecodeBlock(data []byte) (*Block, error) {
+	var block Block
+
+	r := serialize.NewReader(data)
+	switch version := r.ReadUvarint(); version {
+	case blockEncodingVersion:
+		header, err := DecodeBlockHeader(r.ReadBytes())
+		if err != nil {
+			r.Fail(err)
+			break
+		}
+		block.BlockHeader = *header
+		block.Hash = header.BlockHash()
+		block.Height = r.ReadInt()
+	case legacyEncodingVersion:
+		block.Version = LegacyBlockVersion
+		block.Timestamp = r.ReadVarint()
+		block.PrevBlockHash = r.ReadBytes()
+		block.MerkleRoot = r.ReadBytes()
+		block.Hash = r.ReadBytes()
+		block.Nonce = r.ReadInt()
+		block.Height = r.ReadInt()
+		block.Bits = r.ReadUint32()
+	default:
+		r.Fail(fmt.Errorf("unsupported block encoding version %d", version))
+	}
+	block.Transactions = readTransactions(r)
+
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("can't decode block: %w", err)
+	}
+
+	return &block, nil
+}
+
+/*
+DecodeBlockBody : розбирає тіло блоку, закодоване SerializeBody.
+*/
+func DecodeBlockBody(data []byte) ([]*transaction.Transaction, error) {
+	r := serialize.NewReader(data)
+	if version := r.ReadUvarint(); version != bodyEncodingVersion {
+		r.Fail(fmt.Errorf("unsupported block body encoding version %d", version))
+	}
+	txs := readTransactions(r)
+
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("can't decode block body: %w", err)
+	}
+
+	return txs, nil
+}
+
+/*
+DeserializeBlock : розбирає блок, пошкоджені дані є фатальною помилкою.
+*/
+func DeserializeBlock(d []byte) *Block {
+	block, err := DecodeBlock(d)
+	if err != nil {
+		log.Fatal(" Can't deserialize block ", err)
+	}
+
+	return block
+}
+
+func writeTransactions(w *serialize.Writer, txs []*transaction.Transaction) {
+	w.WriteUvarint(uint64(len(txs)))
+	for _, tx := range txs {
+		w.WriteBytes(tx.Serialize())
+	}
+}
+
+func readTransactions(r *serialize.Reader) []*transaction.Transaction {
+	var txs []*transaction.Transaction
+
+	count := r.ReadCount()
+	for i := 0; i < count && r.Err() == nil; i++ {
+		tx, err := transaction.DecodeTransaction(r.ReadBytes())
+		if err != nil {
+			r.Fail(err)
+			break
+		}
+		txs = append(txs, &tx)
+	}
+
+	return txs
+}
@@ -0,0 +1,209 @@
+package bloks
+
+import (
+	"blockchain1/lib/utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+LegacyBlockVersion - версія заголовків блоків, добутих до появи BlockHeader. Їх хеш обчислюється
+з PrevBlockHash, MerkleRoot, Timestamp, Bits і Nonce, записаних по 8 байт big-endian,
+тому ці блоки залишаються дійсними.
+BlockVersion - версія нових заголовків, їх хеш обчислюється з канонічного кодування заголовка.
+HeaderSize - довжина закодованого заголовка версії BlockVersion.
+*/
+const (
+	LegacyBlockVersion = 0
+	BlockVersion       = 1
+	HeaderSize         = 4 + 32 + 32 + 8 + 4 + 4
+)
+
+/*
+legacyTargetBits - складність блоків, добутих до того, як блок почав зберігати Bits: ціль 2^(256-17).
+Такі блоки мають Bits = 0, а в доказ роботи замість Bits хешували число legacyTargetBits.
+*/
+const legacyTargetBits = 17
+
+/*
+LegacyBits - ціль блоків без Bits у компактному форматі ( див. TargetBits ).
+*/
+var LegacyBits = BigToCompact(new(big.Int).Lsh(big.NewInt(1), 256-legacyTargetBits))
+
+/*
+legacyNonceSize та nonceSize - скільки байтів займає nonce в кінці закодованого заголовка.
+*/
+const (
+	legacyNonceSize = 8
+	nonceSize       = 4
+)
+
+var ErrBadHeader = errors.New("block header is malformed")
+
+/*
+BlockHeader : заголовок блоку. Хеш блоку - це sha256 від закодованого заголовка ( див. Serialize ),
+тому заголовки можна передавати і перевіряти без транзакцій блоку,
+а транзакції прив'язані до заголовка через MerkleRoot.
+- Version - версія заголовка, від якої залежить його кодування.
+- PrevBlockHash - хеш попереднього блоку, порожній для genesis блоку.
+- MerkleRoot - корінь дерева Меркла транзакцій блоку.
+- Timestamp - час створення блоку.
+- Bits - ціль доказу роботи в компактному форматі.
+- Nonce - лічильник, який перебирається під час доказу роботи.
+*/
+type BlockHeader struct {
+	Version       int32
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Timestamp     int64
+	Bits          uint32
+	Nonce         int
+}
+
+/*
+Serialize : кодує заголовок так, як його хешує доказ роботи.
+Заголовок версії BlockVersion займає HeaderSize байтів: Version, PrevBlockHash ( 32 байти,
+нульові для genesis блоку ), MerkleRoot ( 32 байти ), Timestamp, Bits і Nonce, числа little-endian.
+*/
+func (h *BlockHeader) Serialize() []byte {
+	data := h.powPrefix()
+	data = append(data, make([]byte, h.nonceSize())...)
+	h.putNonce(data[len(data)-h.nonceSize():], h.Nonce)
+
+	return data
+}
+
+/*
+BlockHash : повертає хеш блоку з цим заголовком.
+*/
+func (h *BlockHeader) BlockHash() []byte {
+	hash := sha256.Sum256(h.Serialize())
+
+	return hash[:]
+}
+
+/*
+TargetBits : повертає ціль доказу роботи заголовка в компактному форматі.
+Legacy заголовки блоків, добутих до появи Bits, мають Bits = 0 і ціль LegacyBits.
+*/
+func (h *BlockHeader) TargetBits() uint32 {
+	if h.Version == LegacyBlockVersion && h.Bits == 0 {
+		return LegacyBits
+	}
+
+	return h.Bits
+}
+
+/*
+powPrefix : повертає закодований заголовок без nonce, він не змінюється під час пошуку nonce.
+*/
+func (h *BlockHeader) powPrefix() []byte {
+	if h.Version == LegacyBlockVersion {
+		bits := int64(h.Bits)
+		if h.Bits == 0 {
+			bits = legacyTargetBits
+		}
+
+		return bytes.Join(
+			[][]byte{
+				h.PrevBlockHash,
+				h.MerkleRoot,
+				utils.IntToHex(h.Timestamp),
+				utils.IntToHex(bits),
+			},
+			[]byte{},
+		)
+	}
+
+	data := make([]byte, 0, HeaderSize)
+	data = binary.LittleEndian.AppendUint32(data, uint32(h.Version))
+	data = append(data, fixedHash(h.PrevBlockHash)...)
+	data = append(data, fixedHash(h.MerkleRoot)...)
+	data = binary.LittleEndian.AppendUint64(data, uint64(h.Timestamp))
+	data = binary.LittleEndian.AppendUint32(data, h.Bits)
+
+	return data
+}
+
+func (h *BlockHeader) nonceSize() int {
+	if h.Version == LegacyBlockVersion {
+		return legacyNonceSize
+	}
+
+	return nonceSize
+}
+
+/*
+putNonce : записує nonce в кінець закодованого заголовка.
+*/
+func (h *BlockHeader) putNonce(data []byte, nonce int) {
+	if h.Version == LegacyBlockVersion {
+		binary.BigEndian.PutUint64(data, uint64(nonce))
+		return
+	}
+
+	binary.LittleEndian.PutUint32(data, uint32(nonce))
+}
+
+/*
+fixedHash : доповнює порожній хеш до 32 нульових байтів.
+*/
+func fixedHash(hash []byte) []byte {
+	if len(hash) == 0 {
+		return make([]byte, sha256.Size)
+	}
+
+	return hash
+}
+
+/*
+DecodeBlockHeader : розбирає заголовок, закодований Serialize.
+Legacy заголовки не містять версії, їх відрізняє довжина: 56 байтів у genesis блоку і 88 в інших.
+Записане в них число legacyTargetBits означає заголовок без Bits.
+*/
+func DecodeBlockHeader(data []byte) (*BlockHeader, error) {
+	var h BlockHeader
+	hashSize := sha256.Size
+
+	switch len(data) {
+	case HeaderSize:
+		h.Version = int32(binary.LittleEndian.Uint32(data[0:4]))
+		if h.Version == LegacyBlockVersion {
+			return nil, fmt.Errorf("%w: version %d", ErrBadHeader, h.Version)
+		}
+		data = data[4:]
+
+		h.PrevBlockHash = append([]byte{}, data[:hashSize]...)
+		if bytes.Equal(h.PrevBlockHash, make([]byte, hashSize)) {
+			h.PrevBlockHash = []byte{}
+		}
+		h.MerkleRoot = append([]byte{}, data[hashSize:2*hashSize]...)
+		data = data[2*hashSize:]
+
+		h.Timestamp = int64(binary.LittleEndian.Uint64(data[0:8]))
+		h.Bits = binary.LittleEndian.Uint32(data[8:12])
+		h.Nonce = int(binary.LittleEndian.Uint32(data[12:16]))
+	case 2*hashSize + 3*8, hashSize + 3*8:
+		h.Version = LegacyBlockVersion
+		h.PrevBlockHash = append([]byte{}, data[:len(data)-hashSize-3*8]...)
+		data = data[len(h.PrevBlockHash):]
+
+		h.MerkleRoot = append([]byte{}, data[:hashSize]...)
+		data = data[hashSize:]
+
+		h.Timestamp = int64(binary.BigEndian.Uint64(data[0:8]))
+		h.Bits = uint32(binary.BigEndian.Uint64(data[8:16]))
+		if h.Bits == legacyTargetBits {
+			h.Bits = 0
+		}
+		h.Nonce = int(binary.BigEndian.Uint64(data[16:24]))
+	default:
+		return nil, fmt.Errorf("%w: length %d", ErrBadHeader, len(data))
+	}
+
+	return &h, nil
+}
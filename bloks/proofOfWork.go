@@ -1,44 +1,65 @@
 package bloks
 
 import (
-	"blockchain1/lib/utils"
-	"bytes"
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 /*
-targetBits
 В Биткоине, «target bits» — это поле заголовка блока, которое хранит сложность,
-на которой блок был добыт. Мы не будем строить корректирующийся алгоритм,
-поэтому определим сложность, как глобальную константу.
-якщо ви хочете збільшити складність майнінгу, вам потрібно зменшити targetBits.
-Якщо хочете зменшити складність, збільшіть targetBits.
-в реальних блокчейн-системах зазвичай регулюється динамічно,
+на которой блок был добыт. Складність кожного блоку зберігається в полі Block.Bits
+і перераховується кожні RetargetInterval блоків ( див. difficulty.go ),
 щоб забезпечити бажаний час між знаходженням блоків,
 навіть якщо загальна обчислювальна потужність мережі змінюється.
+Мінімальна складність, з якою добувається genesis блок і нижче якої складність не опускається,
+задається для кожної мережі окремо ( див. chaincfg.Params.PowLimit ).
 
-maxNonce - максимальне значення для лічильника nonce.
+maxNonce - максимальне значення для лічильника nonce. Як і в Біткоїні, nonce займає 32 біти,
+а коли їх не вистачає, майнер змінює extra nonce у coinbase транзакції ( див. Block.Mine ).
+cancelCheckInterval - через скільки перебраних nonce пошук перевіряє, чи його не скасовано.
+progressInterval - через скільки перебраних nonce виводиться поточний хеш.
 */
 const (
-	targetBits = 17
-	maxNonce   = math.MaxInt64
+	maxNonce            = math.MaxUint32
+	cancelCheckInterval = 4096
+	progressInterval    = 100000
 )
 
+/*
+ErrNonceSpaceExhausted - перебрано всі значення nonce, але жоден хеш не менший за ціль.
+*/
+var ErrNonceSpaceExhausted = errors.New("all nonce values are tried")
+
+/*
+ProofOfWork : доказ роботи для заголовка блоку Header з ціллю Target.
+Хешується лише заголовок, тому доказ роботи можна перевірити без транзакцій блоку.
+- Hashes - скільки хешів обчислив останній виклик Run або RunContext.
+- prefix - закодований заголовок без nonce, він не змінюється під час пошуку,
+тому обчислюється один раз.
+*/
 type ProofOfWork struct {
-	Block  *Block
+	Header *BlockHeader
 	Target *big.Int
+	Hashes uint64
+
+	prefix []byte
 }
 
-func NewProofOfWork(b *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+func NewProofOfWork(h *BlockHeader) *ProofOfWork {
+	target := CompactToBig(h.TargetBits())
 
 	pow := &ProofOfWork{
-		Block:  b,
+		Header: h,
 		Target: target,
+		prefix: h.powPrefix(),
 	}
 	return pow
 }
@@ -48,63 +69,133 @@ Run : Цей метод виконує процес майнінгу блоку
 використовуючи алгоритм доказу роботи (Proof of Work).
 */
 func (pow *ProofOfWork) Run() (int, []byte) {
-	var hashInt big.Int
-	var hash [32]byte
-	nonce := 0
+	nonce, hash, err := pow.RunContext(context.Background())
+	if err != nil {
+		log.Panic(err)
+	}
 
-	fmt.Printf("Mining a new block")
-	for nonce < maxNonce {
-		data := pow.prepareData(nonce)
-		hash = sha256.Sum256(data)
+	return nonce, hash
+}
 
-		if math.Remainder(float64(nonce), 100000) == 0 {
-			fmt.Printf("\r%x", hash)
-		}
-		hashInt.SetBytes(hash[:])
+/*
+RunContext : Цей метод виконує той самий пошук nonce, що й Run, але припиняє його,
+щойно ctx скасовано, і повертає помилку ctx.Err(). Так майнер може покинути блок,
+який застарів, бо інший вузол уже добув блок на тій самій висоті.
+Простір nonce ділиться між GOMAXPROCS горутинами: горутина i перевіряє nonce i, i+workers, i+2*workers...
+Якщо жоден nonce не підходить, повертається ErrNonceSpaceExhausted.
+*/
+func (pow *ProofOfWork) RunContext(ctx context.Context) (int, []byte, error) {
+	workers := runtime.GOMAXPROCS(0)
 
-		if hashInt.Cmp(pow.Target) == -1 {
-			break
-		} else {
-			nonce++
-		}
+	// search скасовується і ззовні, і горутиною, яка першою знайшла nonce
+	search, cancel := context.WithCancel(ctx)
+	defer cancel()
 
+	type solution struct {
+		nonce int
+		hash  []byte
 	}
+	solutions := make(chan solution, workers)
+
+	var hashes uint64
+	var wg sync.WaitGroup
+
+	fmt.Printf("Mining a new block")
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+
+			var hashInt big.Int
+			// nonce записується в кінець закодованого заголовка
+			data := make([]byte, len(pow.prefix)+pow.Header.nonceSize())
+			copy(data, pow.prefix)
+			count := uint64(0)
+			defer func() { atomic.AddUint64(&hashes, count) }()
+
+			for nonce := start; nonce <= maxNonce; nonce += workers {
+				if count%cancelCheckInterval == 0 && search.Err() != nil {
+					return
+				}
+
+				pow.Header.putNonce(data[len(pow.prefix):], nonce)
+				hash := sha256.Sum256(data)
+				count++
+
+				if start == 0 && count%progressInterval == 0 {
+					fmt.Printf("\r%x", hash)
+				}
+				hashInt.SetBytes(hash[:])
+
+				if hashInt.Cmp(pow.Target) == -1 {
+					solutions <- solution{nonce: nonce, hash: hash[:]}
+					cancel()
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
 
 	fmt.Print("\n\n")
+	pow.Hashes = hashes
+
+	select {
+	case found := <-solutions:
+		return found.nonce, found.hash, nil
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
 
-	return nonce, hash[:]
+	return 0, nil, ErrNonceSpaceExhausted
 }
 
 /*
 prepareData : Цей метод використовується для підготовки даних для хешування.
 */
 func (pow *ProofOfWork) prepareData(nonce int) []byte {
-	data := bytes.Join(
-		[][]byte{
-			pow.Block.PrevBlockHash,
-			pow.Block.HashTransactions(),
-			utils.IntToHex(pow.Block.Timestamp),
-			utils.IntToHex(int64(targetBits)),
-			utils.IntToHex(int64(nonce)),
-		},
-		[]byte{},
-	)
+	data := make([]byte, len(pow.prefix)+pow.Header.nonceSize())
+	copy(data, pow.prefix)
+	pow.Header.putNonce(data[len(pow.prefix):], nonce)
 
 	return data
 }
 
 /*
-Validate : Цей метод використовується для перевірки правильності хешу блоку.
+Validate : Цей метод використовується для перевірки доказу роботи заголовка:
+хеш заголовка з його поточним nonce має бути меншим за ціль.
 */
 func (pow *ProofOfWork) Validate() bool {
 	var hashInt big.Int
 
-	data := pow.prepareData(pow.Block.Nonce)
-	hash := sha256.Sum256(data)
-	hashInt.SetBytes(hash[:])
+	hash := pow.Hash()
+	hashInt.SetBytes(hash)
 
 	isValid := hashInt.Cmp(pow.Target) == -1
 
 	return isValid
 
 }
+
+/*
+Hash : Цей метод обчислює хеш заголовка з його поточним nonce.
+*/
+func (pow *ProofOfWork) Hash() []byte {
+	hash := sha256.Sum256(pow.prepareData(pow.Header.Nonce))
+
+	return hash[:]
+}
+
+/*
+Work : Цей метод повертає кількість роботи, яку в середньому потрібно виконати,
+щоб знайти блок з поточною ціллю: 2^256 / (Target + 1).
+Сума роботи всіх блоків ланцюга використовується для вибору основного ланцюга.
+*/
+func (pow *ProofOfWork) Work() *big.Int {
+	denominator := new(big.Int).Add(pow.Target, big.NewInt(1))
+	numerator := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	return numerator.Div(numerator, denominator)
+}
@@ -0,0 +1,144 @@
+package chaincfg
+
+import (
+	"fmt"
+	"math/big"
+)
+
+/*
+Params - параметри мережі, які мають бути однаковими на всіх її вузлах.
+Вузли різних мереж не приймають повідомлення, блоки та адреси одне одного.
+- Name - назва мережі, за якою її обирає прапорець --network.
+- Net - ідентифікатор мережі ( magic ), з яким вузол відправляє і приймає повідомлення.
+- DefaultPort - порт центрального вузла мережі.
+- SeedNodes - адреси вузлів, до яких підключається новий вузол. Перший з них - центральний вузол.
+- GenesisCoinbaseData - дані coinbase транзакції genesis блоку.
+- PowLimit - найбільша допустима ціль, тобто мінімальна складність мережі, з нею добувається genesis блок.
+- RetargetInterval - кількість блоків, після якої перераховується складність.
+- TargetBlockSpacing - бажаний час між блоками в секундах.
+- NoRetargeting - складність ніколи не перераховується і завжди дорівнює PowLimit.
+- InitialSubsidy - кількість монет, яку майнер отримує за блок до першого зменшення винагороди.
+- SubsidyHalvingInterval - кількість блоків, після якої винагорода за блок зменшується вдвічі.
+- AddressVersion - байт версії, з якого починається адреса, тому адреси різних мереж відрізняються.
+- ScriptHashAddressVersion - байт версії адреси хешу скрипта, наприклад multisig адреси.
+- DbFile, WalletFile - шаблони назв файлів бази даних і гаманців вузла, куди підставляється NODE_ID.
+*/
+type Params struct {
+	Name                     string
+	Net                      uint32
+	DefaultPort              string
+	SeedNodes                []string
+	GenesisCoinbaseData      string
+	PowLimit                 *big.Int
+	RetargetInterval         int
+	TargetBlockSpacing       int64
+	NoRetargeting            bool
+	InitialSubsidy           int
+	SubsidyHalvingInterval   int
+	AddressVersion           byte
+	ScriptHashAddressVersion byte
+	DbFile                   string
+	WalletFile               string
+}
+
+/*
+MainNetParams - параметри основної мережі.
+*/
+var MainNetParams = Params{
+	Name:                     "mainnet",
+	Net:                      0xd9b4bef9,
+	DefaultPort:              "3000",
+	SeedNodes:                []string{"127.0.0.1:3000"},
+	GenesisCoinbaseData:      "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks",
+	PowLimit:                 new(big.Int).Lsh(big.NewInt(1), 256-17),
+	RetargetInterval:         10,
+	TargetBlockSpacing:       10,
+	InitialSubsidy:           100,
+	SubsidyHalvingInterval:   1000,
+	AddressVersion:           0x00,
+	ScriptHashAddressVersion: 0x05,
+	DbFile:                   "blockchain_%s.db",
+	WalletFile:               "wallet_%s.dat",
+}
+
+/*
+TestNetParams - параметри тестової мережі: складність нижча, ніж в основній,
+а адреси починаються з іншого байту версії.
+*/
+var TestNetParams = Params{
+	Name:                     "testnet",
+	Net:                      0x0709110b,
+	DefaultPort:              "4000",
+	SeedNodes:                []string{"127.0.0.1:4000"},
+	GenesisCoinbaseData:      "Test network genesis block",
+	PowLimit:                 new(big.Int).Lsh(big.NewInt(1), 256-12),
+	RetargetInterval:         10,
+	TargetBlockSpacing:       10,
+	InitialSubsidy:           100,
+	SubsidyHalvingInterval:   1000,
+	AddressVersion:           0x6f,
+	ScriptHashAddressVersion: 0xc4,
+	DbFile:                   "blockchain_testnet_%s.db",
+	WalletFile:               "wallet_testnet_%s.dat",
+}
+
+/*
+RegTestParams - параметри мережі регресійного тестування: ціль 2^255, тобто під неї підходить
+кожен другий хеш, а складність не перераховується, тому блоки добуваються майже миттєво.
+*/
+var RegTestParams = Params{
+	Name:                     "regtest",
+	Net:                      0xdab5bffa,
+	DefaultPort:              "5000",
+	SeedNodes:                []string{"127.0.0.1:5000"},
+	GenesisCoinbaseData:      "Regression test network genesis block",
+	PowLimit:                 new(big.Int).Lsh(big.NewInt(1), 256-1),
+	RetargetInterval:         10,
+	TargetBlockSpacing:       10,
+	NoRetargeting:            true,
+	InitialSubsidy:           100,
+	SubsidyHalvingInterval:   150,
+	AddressVersion:           0x6f,
+	ScriptHashAddressVersion: 0xc4,
+	DbFile:                   "blockchain_regtest_%s.db",
+	WalletFile:               "wallet_regtest_%s.dat",
+}
+
+/*
+ActiveNetParams - параметри мережі, з якою працює вузол. Усі пакети беруть параметри звідси,
+тому мережу обирають один раз до відкриття бази даних і гаманців ( див. SetActiveNetParams ).
+*/
+var ActiveNetParams = &MainNetParams
+
+/*
+ParamsForName повертає параметри мережі з назвою name.
+*/
+func ParamsForName(name string) (*Params, error) {
+	for _, params := range []*Params{&MainNetParams, &TestNetParams, &RegTestParams} {
+		if params.Name == name {
+			return params, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown network %q", name)
+}
+
+/*
+SetActiveNetParams робить активною мережу з назвою name.
+*/
+func SetActiveNetParams(name string) error {
+	params, err := ParamsForName(name)
+	if err != nil {
+		return err
+	}
+	ActiveNetParams = params
+
+	return nil
+}
+
+/*
+CentralNode повертає адресу центрального вузла мережі, через який нові вузли дізнаються про інших.
+*/
+func (p *Params) CentralNode() string {
+	return p.SeedNodes[0]
+}
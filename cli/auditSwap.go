@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"blockchain1/script"
+	"blockchain1/server"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+/*
+auditSwap друкує умови контракту обміну з файлу file: адресу і суму контракту, адреси отримувача
+і відправника, хеш секрету і час повернення. Учасник перевіряє їх, перш ніж блокувати свої монети.
+Якщо rpcAddress не порожній, вузол мережі контракту також повідомляє, чи вихід контракту ще не витрачено,
+а якщо його забрав отримувач, - секрет, який для цього розкрито.
+*/
+func (cli *CLI) auditSwap(file string, rpcAddress string) {
+	swap := readSwap(file)
+	htlc := swap.HTLC()
+	vout, output := swap.Output()
+
+	fmt.Printf("Contract address:     %s\n", wal.ScriptHashAddress(swap.Contract))
+	fmt.Printf("Contract output:      %x:%d\n", swap.Tx.ID, vout)
+	fmt.Printf("Contract value:       %d\n", output.Value)
+	fmt.Printf("Recipient address:    %s\n", wal.PubKeyHashAddress(htlc.RecipientHash))
+	fmt.Printf("Refund address:       %s\n", wal.PubKeyHashAddress(htlc.RefundHash))
+	fmt.Printf("Secret hash:          %x\n", htlc.SecretHash)
+
+	if htlc.LockTime < transaction.LockTimeThreshold {
+		fmt.Printf("Refund is possible after block %d\n", htlc.LockTime)
+	} else {
+		lockTime := time.Unix(htlc.LockTime, 0)
+		if left := time.Until(lockTime); left > 0 {
+			fmt.Printf("Refund is possible after %s (%s left)\n", lockTime.Format(time.RFC3339), left.Truncate(time.Second))
+		} else {
+			fmt.Printf("Refund is possible after %s (passed)\n", lockTime.Format(time.RFC3339))
+		}
+	}
+
+	if rpcAddress != "" {
+		auditSwapRPC(server.NewRPCClient(rpcAddress), swap, vout)
+	}
+}
+
+/*
+auditSwapRPC перевіряє стан виходу контракту vout на запущеному вузлі. Транзакцію, яка витратила вихід,
+шукає в блоках від вершини ланцюга до блоку з транзакцією контракту.
+*/
+func auditSwapRPC(client *server.RPCClient, swap *transaction.SwapContract, vout int) {
+	contractTxID := hex.EncodeToString(swap.Tx.ID)
+
+	var rawTx string
+	err := client.Call("getrawtransaction", []interface{}{contractTxID}, &rawTx)
+	if err != nil {
+		fmt.Println("Contract transaction is not found")
+		return
+	}
+
+	contractAddress := fmt.Sprintf("%s", wal.ScriptHashAddress(swap.Contract))
+	for _, utxo := range listUnspentRPC(client, contractAddress) {
+		if utxo.TxId == contractTxID && utxo.VOut == vout {
+			fmt.Println("Contract output is unspent")
+			return
+		}
+	}
+
+	var hash string
+	err = client.Call("getbestblockhash", []interface{}{}, &hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for hash != "" {
+		var block server.BlockInfo
+		err = client.Call("getblock", []interface{}{hash}, &block)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, txID := range block.Transactions {
+			err = client.Call("getrawtransaction", []interface{}{txID}, &rawTx)
+			if err != nil {
+				log.Fatal(err)
+			}
+			data, err := hex.DecodeString(rawTx)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tx, err := transaction.DecodeTransaction(data)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			for _, vin := range tx.VIn {
+				if !bytes.Equal(vin.TxId, swap.Tx.ID) || vin.VOut != vout {
+					continue
+				}
+
+				unlocking, _ := vin.UnlockingScript()
+				if secret := script.ExtractHTLCSecret(unlocking); secret != nil {
+					fmt.Printf("Contract is redeemed by transaction %s in block %d\n", txID, block.Height)
+					fmt.Printf("Secret: %x\n", secret)
+				} else {
+					fmt.Printf("Contract is refunded by transaction %s in block %d\n", txID, block.Height)
+				}
+				return
+			}
+
+			if txID == contractTxID {
+				hash = ""
+			}
+		}
+
+		if hash != "" {
+			hash = block.PrevBlockHash
+		}
+	}
+
+	fmt.Println("Contract output is spent by a transaction in the memory pool")
+}
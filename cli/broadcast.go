@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"blockchain1/chaincfg"
+	"blockchain1/server"
+	"blockchain1/transaction"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+/*
+broadcast передає підписану транзакцію з файлу file ( див. finalizepsbt ) центральному вузлу
+або, якщо rpcAddress не порожній, запущеному вузлу через JSON-RPC.
+*/
+func (cli *CLI) broadcast(file string, rpcAddress string) {
+	data := readHexFile(file)
+
+	tx, err := transaction.DecodeTransaction(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sendTransaction(&tx, rpcAddress)
+}
+
+/*
+sendTransaction передає транзакцію центральному вузлу або, якщо rpcAddress не порожній,
+запущеному вузлу через JSON-RPC, який одразу повідомляє, чи прийняв її.
+*/
+func sendTransaction(tx *transaction.Transaction, rpcAddress string) {
+	if rpcAddress == "" {
+		server.SendTx(chaincfg.ActiveNetParams.CentralNode(), tx)
+		fmt.Println("Success!")
+		return
+	}
+
+	var txID string
+	err := server.NewRPCClient(rpcAddress).Call("sendrawtransaction", []interface{}{hex.EncodeToString(tx.Serialize())}, &txID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Transaction %s is sent\n", txID)
+}
@@ -1,24 +1,50 @@
 package cli
 
 import (
+	"blockchain1/chaincfg"
+	"blockchain1/mempool"
+	"blockchain1/server"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"strings"
+	"time"
 )
 
 type CLI struct{}
 
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage:")
+	fmt.Println("Usage: [--network <NETWORK>] <COMMAND>")
 	fmt.Println("  printchain							# print all the blocks of the blockchain")
 	fmt.Println("  createblockchain --address <ADDRESS>				# create a blockchain and send genesis block reward to ADDRESS")
-	fmt.Println("  getbalance --address <ADDRESS>				# get balance of ADDRESS")
-	fmt.Println("  send	--from <FROM> --to <TO> --amount <AMOUNT>		# send AMOUNT of coins from FROM address to TO")
+	fmt.Println("  getbalance --address <ADDRESS> [--rpc <RPC_ADDR>]		# get balance of ADDRESS, --rpc asks a running node instead of opening the database")
+	fmt.Println("  send	--from <FROM> --to <TO> --amount <AMOUNT> --fee <FEE> [--locktime <LOCKTIME>] [--rpc <RPC_ADDR>]	# send AMOUNT of coins from FROM address to TO paying FEE to the miner, not before block height or unix time LOCKTIME")
+	fmt.Println("  rpc [--rpc <RPC_ADDR>] <METHOD> [PARAMS...]			# call JSON-RPC METHOD of a running node and print the result")
 	fmt.Println("  createwallet							# create a new wallet")
 	fmt.Println("  listaddresses							# list all addresses in the wallet")
+	fmt.Println("  getpubkey --address <ADDRESS>					# print the public key of ADDRESS to share it with multisig participants")
+	fmt.Println("  createmultisig --required <M> --keys <KEY1,KEY2,...>		# create an M-of-N multisig address from wallet addresses or hex public keys and add it to the wallet")
+	fmt.Println("  spendmultisig --from <MULTISIG> --to <TO> --amount <AMOUNT> --fee <FEE> --out <FILE> [--rpc <RPC_ADDR>]	# save an unsigned spend from the multisig address to FILE")
+	fmt.Println("  signmultisig --file <FILE> [--wallets <ID1,ID2,...>]		# sign the spend in FILE with keys from wallets of the given node IDs (NODE_ID by default)")
+	fmt.Println("  finalizemultisig --file <FILE> [--rpc <RPC_ADDR>]		# assemble the signed spend in FILE and send it to the central node or RPC_ADDR")
+	fmt.Println("  createpsbt --from <FROM> --to <TO> --amount <AMOUNT> --fee <FEE> --out <FILE> [--rpc <RPC_ADDR>]	# save an unsigned transaction from FROM (a wallet or multisig address) to FILE as PSBT")
+	fmt.Println("  signpsbt --file <FILE> [--wallets <ID1,ID2,...>]			# sign the PSBT in FILE with keys from wallets of the given node IDs (NODE_ID by default), no database needed")
+	fmt.Println("  combinepsbt --files <FILE1,FILE2,...> --out <FILE>		# merge signatures of PSBT files signed separately into FILE")
+	fmt.Println("  finalizepsbt --file <FILE> [--out <TX_FILE>]			# assemble the signed transaction from the PSBT and print it or save it to TX_FILE")
+	fmt.Println("  broadcast --file <TX_FILE> [--rpc <RPC_ADDR>]			# send the signed transaction from TX_FILE to the central node or RPC_ADDR")
+	fmt.Println("  initiateswap --from <FROM> --to <PARTICIPANT> --amount <AMOUNT> --fee <FEE> --out <FILE> [--timeout <DURATION>] [--rpc <RPC_ADDR>]	# start an atomic swap: lock AMOUNT to a contract PARTICIPANT redeems with a new secret, save it to FILE")
+	fmt.Println("  participateswap --from <FROM> --to <INITIATOR> --amount <AMOUNT> --fee <FEE> --secrethash <HASH> --out <FILE> [--timeout <DURATION>] [--rpc <RPC_ADDR>]	# answer an atomic swap with a contract on the initiator's secret hash")
+	fmt.Println("  auditswap --file <FILE> [--rpc <RPC_ADDR>]			# print the swap contract terms, --rpc also shows whether it is redeemed and the revealed secret")
+	fmt.Println("  redeemswap --file <FILE> --secret <SECRET> --fee <FEE> [--rpc <RPC_ADDR>]	# claim the swap contract coins with the secret")
+	fmt.Println("  refundswap --file <FILE> --fee <FEE> [--rpc <RPC_ADDR>]		# return the swap contract coins to the sender after its timeout")
+	fmt.Println("  generate --blocks <N> --address <ADDRESS> [--rpc <RPC_ADDR>]		# mine N blocks immediately and send their rewards to ADDRESS")
+	fmt.Println("  getsupply							# print circulating supply and the maximum supply of coins")
 	fmt.Println("  reindexutxo							# rebuild the UTXO set")
-	fmt.Println("  startnode -miner <ADDRESS>   					#Start a node with ID specified in NODE_ID env. var. -miner enables mining")
+	fmt.Println("  disconnecttip							# roll back the last block of the chain using its undo data")
+	fmt.Println("  startnode -miner <ADDRESS> -maxinbound <N> -maxoutbound <N> -rpcaddr <RPC_ADDR> -mempoolsize <BYTES> -mempoolexpiry <DURATION>	#Start a node with ID specified in NODE_ID env. var. -miner enables mining")
+	fmt.Println("--network selects mainnet (default), testnet or regtest: each network has its own chain, wallets, addresses and central node port (3000, 4000, 5000)")
 }
 
 func (cli *CLI) validateArgs() {
@@ -37,61 +63,257 @@ func (cli *CLI) Run() {
 		os.Exit(1)
 	}
 
+	// мережу обираємо до того, як команда відкриє базу даних чи гаманці
+	networkFlags := flag.NewFlagSet("network", flag.ExitOnError)
+	network := networkFlags.String("network", chaincfg.MainNetParams.Name, "Network to use: mainnet, testnet or regtest")
+	err := networkFlags.Parse(os.Args[1:])
+	if err != nil {
+		log.Panic(err)
+	}
+	err = chaincfg.SetActiveNetParams(*network)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	args := networkFlags.Args()
+	if len(args) == 0 {
+		cli.printUsage()
+		os.Exit(1)
+	}
+
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	getSupplyCmd := flag.NewFlagSet("getsupply", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	disconnectTipCmd := flag.NewFlagSet("disconnecttip", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	rpcCmd := flag.NewFlagSet("rpc", flag.ExitOnError)
+	getPubKeyCmd := flag.NewFlagSet("getpubkey", flag.ExitOnError)
+	createMultiSigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	spendMultiSigCmd := flag.NewFlagSet("spendmultisig", flag.ExitOnError)
+	signMultiSigCmd := flag.NewFlagSet("signmultisig", flag.ExitOnError)
+	finalizeMultiSigCmd := flag.NewFlagSet("finalizemultisig", flag.ExitOnError)
+	createPSBTCmd := flag.NewFlagSet("createpsbt", flag.ExitOnError)
+	signPSBTCmd := flag.NewFlagSet("signpsbt", flag.ExitOnError)
+	combinePSBTCmd := flag.NewFlagSet("combinepsbt", flag.ExitOnError)
+	finalizePSBTCmd := flag.NewFlagSet("finalizepsbt", flag.ExitOnError)
+	broadcastCmd := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	initiateSwapCmd := flag.NewFlagSet("initiateswap", flag.ExitOnError)
+	participateSwapCmd := flag.NewFlagSet("participateswap", flag.ExitOnError)
+	auditSwapCmd := flag.NewFlagSet("auditswap", flag.ExitOnError)
+	redeemSwapCmd := flag.NewFlagSet("redeemswap", flag.ExitOnError)
+	refundSwapCmd := flag.NewFlagSet("refundswap", flag.ExitOnError)
 
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	getBalanceRPC := getBalanceCmd.String("rpc", "", "JSON-RPC address of a running node")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendFee := sendCmd.Int("fee", 0, "Fee paid to the miner of the block")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendLockTime := sendCmd.Uint("locktime", 0, "Block height or unix time before which the transaction can't be mined")
+	sendRPC := sendCmd.String("rpc", "", "JSON-RPC address of a running node")
 	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	startNodeMaxInbound := startNodeCmd.Int("maxinbound", server.DefaultMaxInboundPeers, "Maximum number of inbound peer connections")
+	startNodeMaxOutbound := startNodeCmd.Int("maxoutbound", server.DefaultMaxOutboundPeers, "Maximum number of outbound peer connections")
+	startNodeRPCAddress := startNodeCmd.String("rpcaddr", server.DefaultRPCAddress(nodeID), "Address of the JSON-RPC server, empty disables it")
+	startNodeMemPoolSize := startNodeCmd.Int("mempoolsize", mempool.DefaultMaxSize, "Maximum total size of memory pool transactions in bytes")
+	startNodeMemPoolExpiry := startNodeCmd.Duration("mempoolexpiry", mempool.DefaultExpiry, "How long a transaction may stay in the memory pool")
+	generateBlocks := generateCmd.Int("blocks", 1, "Number of blocks to mine")
+	generateAddress := generateCmd.String("address", "", "The address to send block rewards to")
+	generateRPC := generateCmd.String("rpc", "", "JSON-RPC address of a running node")
+	rpcAddress := rpcCmd.String("rpc", server.DefaultRPCAddress(nodeID), "JSON-RPC address of a running node")
+	getPubKeyAddress := getPubKeyCmd.String("address", "", "The wallet address to print the public key for")
+	createMultiSigRequired := createMultiSigCmd.Int("required", 0, "Number of signatures required to spend")
+	createMultiSigKeys := createMultiSigCmd.String("keys", "", "Comma separated wallet addresses or hex public keys")
+	spendMultiSigFrom := spendMultiSigCmd.String("from", "", "Source multisig address")
+	spendMultiSigTo := spendMultiSigCmd.String("to", "", "Destination wallet address")
+	spendMultiSigAmount := spendMultiSigCmd.Int("amount", 0, "Amount to send")
+	spendMultiSigFee := spendMultiSigCmd.Int("fee", 0, "Fee paid to the miner of the block")
+	spendMultiSigOut := spendMultiSigCmd.String("out", "", "File to save the unsigned spend to")
+	spendMultiSigRPC := spendMultiSigCmd.String("rpc", "", "JSON-RPC address of a running node")
+	signMultiSigFile := signMultiSigCmd.String("file", "", "File with the spend to sign")
+	signMultiSigWallets := signMultiSigCmd.String("wallets", nodeID, "Comma separated node IDs whose wallets sign the spend")
+	finalizeMultiSigFile := finalizeMultiSigCmd.String("file", "", "File with the signed spend")
+	finalizeMultiSigRPC := finalizeMultiSigCmd.String("rpc", "", "JSON-RPC address of a running node")
+	createPSBTFrom := createPSBTCmd.String("from", "", "Source wallet or multisig address")
+	createPSBTTo := createPSBTCmd.String("to", "", "Destination wallet address")
+	createPSBTAmount := createPSBTCmd.Int("amount", 0, "Amount to send")
+	createPSBTFee := createPSBTCmd.Int("fee", 0, "Fee paid to the miner of the block")
+	createPSBTOut := createPSBTCmd.String("out", "", "File to save the PSBT to")
+	createPSBTRPC := createPSBTCmd.String("rpc", "", "JSON-RPC address of a running node")
+	signPSBTFile := signPSBTCmd.String("file", "", "File with the PSBT to sign")
+	signPSBTWallets := signPSBTCmd.String("wallets", nodeID, "Comma separated node IDs whose wallets sign the PSBT")
+	combinePSBTFiles := combinePSBTCmd.String("files", "", "Comma separated files with PSBT of the same transaction")
+	combinePSBTOut := combinePSBTCmd.String("out", "", "File to save the combined PSBT to")
+	finalizePSBTFile := finalizePSBTCmd.String("file", "", "File with the signed PSBT")
+	finalizePSBTOut := finalizePSBTCmd.String("out", "", "File to save the signed transaction to")
+	broadcastFile := broadcastCmd.String("file", "", "File with the signed transaction")
+	broadcastRPC := broadcastCmd.String("rpc", "", "JSON-RPC address of a running node")
+	initiateSwapFrom := initiateSwapCmd.String("from", "", "Source wallet address")
+	initiateSwapTo := initiateSwapCmd.String("to", "", "Wallet address of the participant")
+	initiateSwapAmount := initiateSwapCmd.Int("amount", 0, "Amount to lock in the contract")
+	initiateSwapFee := initiateSwapCmd.Int("fee", 0, "Fee paid to the miner of the block")
+	initiateSwapOut := initiateSwapCmd.String("out", "", "File to save the contract to")
+	initiateSwapTimeout := initiateSwapCmd.Duration("timeout", 48*time.Hour, "Time after which the coins can be refunded")
+	initiateSwapRPC := initiateSwapCmd.String("rpc", "", "JSON-RPC address of a running node")
+	participateSwapFrom := participateSwapCmd.String("from", "", "Source wallet address")
+	participateSwapTo := participateSwapCmd.String("to", "", "Wallet address of the initiator")
+	participateSwapAmount := participateSwapCmd.Int("amount", 0, "Amount to lock in the contract")
+	participateSwapFee := participateSwapCmd.Int("fee", 0, "Fee paid to the miner of the block")
+	participateSwapSecretHash := participateSwapCmd.String("secrethash", "", "Secret hash of the initiator's contract")
+	participateSwapOut := participateSwapCmd.String("out", "", "File to save the contract to")
+	participateSwapTimeout := participateSwapCmd.Duration("timeout", 24*time.Hour, "Time after which the coins can be refunded")
+	participateSwapRPC := participateSwapCmd.String("rpc", "", "JSON-RPC address of a running node")
+	auditSwapFile := auditSwapCmd.String("file", "", "File with the swap contract")
+	auditSwapRPC := auditSwapCmd.String("rpc", "", "JSON-RPC address of a running node of the contract's network")
+	redeemSwapFile := redeemSwapCmd.String("file", "", "File with the swap contract")
+	redeemSwapSecret := redeemSwapCmd.String("secret", "", "Secret of the swap in hex")
+	redeemSwapFee := redeemSwapCmd.Int("fee", 0, "Fee paid to the miner of the block")
+	redeemSwapRPC := redeemSwapCmd.String("rpc", "", "JSON-RPC address of a running node")
+	refundSwapFile := refundSwapCmd.String("file", "", "File with the swap contract")
+	refundSwapFee := refundSwapCmd.Int("fee", 0, "Fee paid to the miner of the block")
+	refundSwapRPC := refundSwapCmd.String("rpc", "", "JSON-RPC address of a running node")
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "createblockchain":
-		err := createBlockchainCmd.Parse(os.Args[2:])
+		err := createBlockchainCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createwallet":
-		err := createWalletCmd.Parse(os.Args[2:])
+		err := createWalletCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "listaddresses":
-		err := listAddressesCmd.Parse(os.Args[2:])
+		err := listAddressesCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "getbalance":
-		err := getBalanceCmd.Parse(os.Args[2:])
+		err := getBalanceCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "send":
-		err := sendCmd.Parse(os.Args[2:])
+		err := sendCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getsupply":
+		err := getSupplyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "generate":
+		err := generateCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "reindexutxo":
-		err := reindexUTXOCmd.Parse(os.Args[2:])
+		err := reindexUTXOCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "disconnecttip":
+		err := disconnectTipCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "printchain":
-		err := printChainCmd.Parse(os.Args[2:])
+		err := printChainCmd.Parse(args[1:])
 		if err != nil {
 			log.Print("Error parsing printchain command", err)
 		}
+	case "rpc":
+		err := rpcCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getpubkey":
+		err := getPubKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createmultisig":
+		err := createMultiSigCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "spendmultisig":
+		err := spendMultiSigCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signmultisig":
+		err := signMultiSigCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizemultisig":
+		err := finalizeMultiSigCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createpsbt":
+		err := createPSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signpsbt":
+		err := signPSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "combinepsbt":
+		err := combinePSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizepsbt":
+		err := finalizePSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "broadcast":
+		err := broadcastCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "initiateswap":
+		err := initiateSwapCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "participateswap":
+		err := participateSwapCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "auditswap":
+		err := auditSwapCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "redeemswap":
+		err := redeemSwapCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "refundswap":
+		err := refundSwapCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "startnode":
-		err := startNodeCmd.Parse(os.Args[2:])
+		err := startNodeCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
@@ -121,33 +343,200 @@ func (cli *CLI) Run() {
 			getBalanceCmd.Usage()
 			os.Exit(1)
 		}
-		cli.getBalance(*getBalanceAddress, nodeID)
+		if *getBalanceRPC != "" {
+			cli.getBalanceRPC(*getBalanceAddress, *getBalanceRPC)
+		} else {
+			cli.getBalance(*getBalanceAddress, nodeID)
+		}
 	}
 
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 || *sendFee < 0 || *sendLockTime > math.MaxUint32 {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
 
-		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
+		if *sendRPC != "" {
+			if *sendMine {
+				fmt.Println("--mine can't be used with --rpc")
+				os.Exit(1)
+			}
+			cli.sendRPC(*sendFrom, *sendTo, *sendAmount, *sendFee, uint32(*sendLockTime), nodeID, *sendRPC)
+		} else {
+			cli.send(*sendFrom, *sendTo, *sendAmount, *sendFee, uint32(*sendLockTime), nodeID, *sendMine)
+		}
+	}
+
+	if getSupplyCmd.Parsed() {
+		cli.getSupply(nodeID)
+	}
+
+	if generateCmd.Parsed() {
+		if *generateAddress == "" || *generateBlocks <= 0 {
+			generateCmd.Usage()
+			os.Exit(1)
+		}
+		if *generateRPC != "" {
+			cli.generateRPC(*generateBlocks, *generateAddress, *generateRPC)
+		} else {
+			cli.generate(*generateBlocks, *generateAddress, nodeID)
+		}
 	}
 
 	if reindexUTXOCmd.Parsed() {
 		cli.reindexUTXO(nodeID)
 	}
 
+	if disconnectTipCmd.Parsed() {
+		cli.disconnectTip(nodeID)
+	}
+
 	if printChainCmd.Parsed() {
 		cli.printChain(nodeID)
 	}
 
+	if rpcCmd.Parsed() {
+		if rpcCmd.NArg() == 0 {
+			rpcCmd.Usage()
+			os.Exit(1)
+		}
+		cli.rpcCall(*rpcAddress, rpcCmd.Arg(0), rpcCmd.Args()[1:])
+	}
+
+	if getPubKeyCmd.Parsed() {
+		if *getPubKeyAddress == "" {
+			getPubKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getPubKey(*getPubKeyAddress, nodeID)
+	}
+
+	if createMultiSigCmd.Parsed() {
+		if *createMultiSigRequired <= 0 || *createMultiSigKeys == "" {
+			createMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createMultiSig(*createMultiSigRequired, strings.Split(*createMultiSigKeys, ","), nodeID)
+	}
+
+	if spendMultiSigCmd.Parsed() {
+		if *spendMultiSigFrom == "" || *spendMultiSigTo == "" || *spendMultiSigAmount <= 0 || *spendMultiSigFee < 0 || *spendMultiSigOut == "" {
+			spendMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.spendMultiSig(*spendMultiSigFrom, *spendMultiSigTo, *spendMultiSigAmount, *spendMultiSigFee, *spendMultiSigOut, nodeID, *spendMultiSigRPC)
+	}
+
+	if signMultiSigCmd.Parsed() {
+		if *signMultiSigFile == "" || *signMultiSigWallets == "" {
+			signMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signMultiSig(*signMultiSigFile, strings.Split(*signMultiSigWallets, ","))
+	}
+
+	if finalizeMultiSigCmd.Parsed() {
+		if *finalizeMultiSigFile == "" {
+			finalizeMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.finalizeMultiSig(*finalizeMultiSigFile, *finalizeMultiSigRPC)
+	}
+
+	if createPSBTCmd.Parsed() {
+		if *createPSBTFrom == "" || *createPSBTTo == "" || *createPSBTAmount <= 0 || *createPSBTFee < 0 || *createPSBTOut == "" {
+			createPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createPSBT(*createPSBTFrom, *createPSBTTo, *createPSBTAmount, *createPSBTFee, *createPSBTOut, nodeID, *createPSBTRPC)
+	}
+
+	if signPSBTCmd.Parsed() {
+		if *signPSBTFile == "" || *signPSBTWallets == "" {
+			signPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signPSBT(*signPSBTFile, strings.Split(*signPSBTWallets, ","))
+	}
+
+	if combinePSBTCmd.Parsed() {
+		if *combinePSBTFiles == "" || *combinePSBTOut == "" {
+			combinePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.combinePSBT(strings.Split(*combinePSBTFiles, ","), *combinePSBTOut)
+	}
+
+	if finalizePSBTCmd.Parsed() {
+		if *finalizePSBTFile == "" {
+			finalizePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.finalizePSBT(*finalizePSBTFile, *finalizePSBTOut)
+	}
+
+	if broadcastCmd.Parsed() {
+		if *broadcastFile == "" {
+			broadcastCmd.Usage()
+			os.Exit(1)
+		}
+		cli.broadcast(*broadcastFile, *broadcastRPC)
+	}
+
+	if initiateSwapCmd.Parsed() {
+		if *initiateSwapFrom == "" || *initiateSwapTo == "" || *initiateSwapAmount <= 0 || *initiateSwapFee < 0 || *initiateSwapOut == "" || *initiateSwapTimeout <= 0 {
+			initiateSwapCmd.Usage()
+			os.Exit(1)
+		}
+		cli.initiateSwap(*initiateSwapFrom, *initiateSwapTo, *initiateSwapAmount, *initiateSwapFee, *initiateSwapTimeout, *initiateSwapOut, nodeID, *initiateSwapRPC)
+	}
+
+	if participateSwapCmd.Parsed() {
+		if *participateSwapFrom == "" || *participateSwapTo == "" || *participateSwapAmount <= 0 || *participateSwapFee < 0 ||
+			*participateSwapSecretHash == "" || *participateSwapOut == "" || *participateSwapTimeout <= 0 {
+			participateSwapCmd.Usage()
+			os.Exit(1)
+		}
+		cli.participateSwap(*participateSwapFrom, *participateSwapTo, *participateSwapAmount, *participateSwapFee, *participateSwapSecretHash,
+			*participateSwapTimeout, *participateSwapOut, nodeID, *participateSwapRPC)
+	}
+
+	if auditSwapCmd.Parsed() {
+		if *auditSwapFile == "" {
+			auditSwapCmd.Usage()
+			os.Exit(1)
+		}
+		cli.auditSwap(*auditSwapFile, *auditSwapRPC)
+	}
+
+	if redeemSwapCmd.Parsed() {
+		if *redeemSwapFile == "" || *redeemSwapSecret == "" || *redeemSwapFee < 0 {
+			redeemSwapCmd.Usage()
+			os.Exit(1)
+		}
+		cli.redeemSwap(*redeemSwapFile, *redeemSwapSecret, *redeemSwapFee, nodeID, *redeemSwapRPC)
+	}
+
+	if refundSwapCmd.Parsed() {
+		if *refundSwapFile == "" || *refundSwapFee < 0 {
+			refundSwapCmd.Usage()
+			os.Exit(1)
+		}
+		cli.refundSwap(*refundSwapFile, *refundSwapFee, nodeID, *refundSwapRPC)
+	}
+
 	if startNodeCmd.Parsed() {
 		nodeID := os.Getenv("NODE_ID")
 		if nodeID == "" {
 			startNodeCmd.Usage()
 			os.Exit(1)
 		}
-		cli.startNode(nodeID, *startNodeMiner)
+		if *startNodeMaxInbound < 0 || *startNodeMaxOutbound < 0 || *startNodeMemPoolSize < 0 || *startNodeMemPoolExpiry < 0 {
+			startNodeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.startNode(nodeID, *startNodeMiner, *startNodeMaxInbound, *startNodeMaxOutbound, *startNodeRPCAddress,
+			*startNodeMemPoolSize, *startNodeMemPoolExpiry)
 	}
 
 }
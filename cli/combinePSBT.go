@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+)
+
+/*
+combinePSBT об'єднує підписи з PSBT files, які учасники підписали окремо, і зберігає результат у файл out.
+*/
+func (cli *CLI) combinePSBT(files []string, out string) {
+	psbt := readPSBT(files[0])
+
+	for _, file := range files[1:] {
+		err := psbt.Combine(readPSBT(file))
+		if err != nil {
+			log.Fatalf("ERROR: %s: %v", file, err)
+		}
+	}
+
+	writePSBT(out, psbt)
+
+	fmt.Printf("Combined PSBT is saved to %s\n", out)
+	printPSBTStatus(psbt)
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	wal "blockchain1/wallet"
+	ws "blockchain1/wallets"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+/*
+createMultiSig створює multisig адресу, з якої можна витратити кошти required підписами ключів keys,
+і зберігає її в гаманці вузла. Ключ - адреса гаманця вузла або публічний ключ в hex ( див. getpubkey ).
+*/
+func (cli *CLI) createMultiSig(required int, keys []string, nodeID string) {
+	wallets, _ := ws.NewWallets(nodeID)
+
+	var pubKeys [][]byte
+	for _, key := range keys {
+		if wallet, ok := wallets.Wallets[key]; ok {
+			pubKeys = append(pubKeys, wallet.PublicKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(key)
+		if err != nil || len(pubKey) == 0 {
+			log.Fatalf("ERROR: %s is neither an address of the wallet nor a hex public key", key)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	for i, pubKey := range pubKeys {
+		if !wal.ValidatePubKey(pubKey) {
+			log.Fatalf("ERROR: key %s is not a valid P256 public key", keys[i])
+		}
+	}
+
+	ms, err := wal.NewMultiSig(required, pubKeys)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	address := wallets.AddMultiSig(ms)
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Multisig address: %s\n", address)
+	fmt.Printf("Redeem script: %s\n", hex.EncodeToString(ms.RedeemScript()))
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	ws "blockchain1/wallets"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+/*
+createPSBT створює непідписану транзакцію з виходів адреси from і зберігає її як PSBT у файл out.
+Ключі адреси не потрібні, тому команду можна виконати на машині без гаманця, а підписати файл
+командою signpsbt на іншій. Якщо rpcAddress не порожній, виходи отримуються від запущеного вузла.
+*/
+func (cli *CLI) createPSBT(from string, to string, amount int, fee int, out string, nodeID string, rpcAddress string) {
+	if !wal.ValidateAddress(from) {
+		log.Fatal("ERROR: address from is not valid")
+	}
+	if !wal.ValidateAddress(to) {
+		log.Fatal("ERROR: address to is not valid")
+	}
+
+	psbt, err := blockchain.BuildPSBT(from, to, amount, fee, findUnspent(from, nodeID, rpcAddress))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// redeem script multisig адреси з гаманця, якщо він є, інакше його додасть signpsbt
+	wallets, err := ws.NewWallets(nodeID)
+	if err == nil {
+		if ms := wallets.GetMultiSig(from); ms != nil {
+			psbt.AddRedeemScript(ms.RedeemScript())
+		}
+	}
+
+	writePSBT(out, psbt)
+
+	fmt.Printf("PSBT of transaction %x is saved to %s\n", psbt.Tx.ID, out)
+	printPSBTStatus(psbt)
+}
+
+/*
+readHexFile читає дані, записані у файл в hex.
+*/
+func readHexFile(file string) []byte {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		log.Fatalf("ERROR: %s is not a hex file: %v", file, err)
+	}
+
+	return data
+}
+
+/*
+writeHexFile записує дані у файл в hex, щоб їх можна було передати іншим учасникам.
+*/
+func writeHexFile(file string, data []byte) {
+	err := os.WriteFile(file, []byte(hex.EncodeToString(data)+"\n"), 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+/*
+readPSBT читає PSBT з файлу, в якому він записаний в hex.
+*/
+func readPSBT(file string) *transaction.PSBT {
+	psbt, err := transaction.DecodePSBT(readHexFile(file))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return psbt
+}
+
+func writePSBT(file string, psbt *transaction.PSBT) {
+	writeHexFile(file, psbt.Serialize())
+}
+
+/*
+printPSBTStatus друкує, скільки підписів зібрано для кожного входу PSBT.
+*/
+func printPSBTStatus(psbt *transaction.PSBT) {
+	for inID := range psbt.Tx.VIn {
+		signed, required := psbt.Signed(inID)
+		if required == 0 {
+			fmt.Printf("Input %d: can't be signed, redeem script is unknown or not supported\n", inID)
+			continue
+		}
+		fmt.Printf("Input %d: %d signatures, %d required\n", inID, signed, required)
+	}
+}
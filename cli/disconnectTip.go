@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"blockchain1/blockchain"
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) disconnectTip(nodeID string) {
+	bc := blockchain.NewBlockchain(nodeID)
+	defer func() { _ = bc.Db.Close() }()
+
+	block, err := bc.DisconnectTip()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Block %x at height %d is disconnected. New height: %d\n", block.Hash, block.Height, bc.GetBestHeight())
+}
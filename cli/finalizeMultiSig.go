@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"log"
+)
+
+/*
+finalizeMultiSig складає з підписів файлу file ( див. spendmultisig ) готову транзакцію і передає її
+центральному вузлу або, якщо rpcAddress не порожній, запущеному вузлу через JSON-RPC.
+Це те саме, що finalizepsbt і broadcast однією командою.
+*/
+func (cli *CLI) finalizeMultiSig(file string, rpcAddress string) {
+	psbt := readPSBT(file)
+
+	tx, err := psbt.Finalize()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sendTransaction(tx, rpcAddress)
+}
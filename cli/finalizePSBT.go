@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+/*
+finalizePSBT складає з підписів PSBT у файлі file готову транзакцію і друкує її в hex.
+Якщо out не порожній, транзакція також зберігається у файл out для команди broadcast.
+*/
+func (cli *CLI) finalizePSBT(file string, out string) {
+	psbt := readPSBT(file)
+
+	tx, err := psbt.Finalize()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if out != "" {
+		writeHexFile(out, tx.Serialize())
+		fmt.Printf("Transaction %x is saved to %s\n", tx.ID, out)
+		return
+	}
+
+	fmt.Println(hex.EncodeToString(tx.Serialize()))
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/server"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	"fmt"
+	"log"
+)
+
+/*
+generate одразу добуває count блоків з винагородою на адресу address.
+Блоки містять лише coinbase транзакцію. У режимі regtest кожен блок добувається миттєво.
+*/
+func (cli *CLI) generate(count int, address string, nodeID string) {
+	if !wal.ValidateAddress(address) {
+		log.Fatal("ERROR: Address is not valid")
+	}
+
+	bc := blockchain.NewBlockchain(nodeID)
+	defer func() { _ = bc.Db.Close() }()
+
+	for i := 0; i < count; i++ {
+		cbTx := transaction.NewCoinbaseTX(address, "", bc.GetBestHeight()+1, 0)
+		block := bc.MineBlock([]*transaction.Transaction{cbTx})
+
+		fmt.Printf("%x\n", block.Hash)
+	}
+}
+
+/*
+generateRPC просить запущений вузол добути count блоків з транзакціями з його пулу
+та винагородою на адресу address.
+*/
+func (cli *CLI) generateRPC(count int, address string, rpcAddress string) {
+	if !wal.ValidateAddress(address) {
+		log.Fatal("ERROR: Address is not valid")
+	}
+
+	var hashes []string
+	err := server.NewRPCClient(rpcAddress).Call("generate", []interface{}{count, address}, &hashes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, hash := range hashes {
+		fmt.Println(hash)
+	}
+}
@@ -2,7 +2,7 @@ package cli
 
 import (
 	"blockchain1/blockchain"
-	"blockchain1/lib/base58"
+	"blockchain1/server"
 	wal "blockchain1/wallet"
 	"fmt"
 	"log"
@@ -21,12 +21,31 @@ func (cli *CLI) getBalance(address string, nodeID string) {
 	}
 
 	balance := 0
-	pubKeyHash := base58.Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
+	lockingScript, err := wal.PayToAddrScript(address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	UTXOs := UTXOSet.FindUTXO(lockingScript)
 	for _, out := range UTXOs {
 		balance += out.Value
 	}
 
 	fmt.Printf("Balance of '%s': %d\n", address, balance)
 }
+
+/*
+getBalanceRPC отримує баланс адреси від запущеного вузла через JSON-RPC.
+*/
+func (cli *CLI) getBalanceRPC(address string, rpcAddress string) {
+	if !wal.ValidateAddress(address) {
+		log.Fatal("ERROR: Address is not valid")
+	}
+
+	var balance int
+	err := server.NewRPCClient(rpcAddress).Call("getbalance", []interface{}{address}, &balance)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Balance of '%s': %d\n", address, balance)
+}
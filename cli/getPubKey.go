@@ -0,0 +1,25 @@
+package cli
+
+import (
+	ws "blockchain1/wallets"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+/*
+getPubKey друкує публічний ключ гаманця в hex, щоб інші учасники могли додати його до multisig адреси.
+*/
+func (cli *CLI) getPubKey(address string, nodeID string) {
+	wallets, err := ws.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		log.Fatal("ERROR: address is not in the wallet")
+	}
+
+	fmt.Println(hex.EncodeToString(wallet.PublicKey))
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/transaction"
+	"fmt"
+)
+
+func (cli *CLI) getSupply(nodeID string) {
+	bc := blockchain.NewBlockchain(nodeID)
+	defer func() { _ = bc.Db.Close() }()
+
+	UTXOSet := blockchain.UTXOSet{
+		Blockchain: bc,
+	}
+	height := bc.GetBestHeight()
+
+	fmt.Printf("Height: %d\n", height)
+	fmt.Printf("Circulating supply: %d\n", UTXOSet.Supply())
+	fmt.Printf("Current block subsidy: %d\n", transaction.CalcBlockSubsidy(height+1))
+	fmt.Printf("Maximum supply: %d\n", transaction.MaxSupply())
+}
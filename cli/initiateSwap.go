@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/script"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	ws "blockchain1/wallets"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"time"
+)
+
+/*
+initiateSwap починає атомарний обмін: створює випадковий секрет і блокує amount монет адреси from
+контрактом, який учасник to може забрати цим секретом, а from - повернути собі через timeout.
+Контракт і його транзакція зберігаються у файл out, щоб учасник перевірив їх командою auditswap.
+Секрет не можна нікому показувати, поки учасник не заблокує свої монети командою participateswap.
+*/
+func (cli *CLI) initiateSwap(from string, to string, amount int, fee int, timeout time.Duration, out string, nodeID string, rpcAddress string) {
+	secret := make([]byte, script.SecretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		log.Panic(err)
+	}
+	secretHash := sha256.Sum256(secret)
+
+	createSwap(from, to, amount, fee, secretHash[:], timeout, out, nodeID, rpcAddress)
+
+	fmt.Printf("Secret:      %x\n", secret)
+	fmt.Printf("Secret hash: %x\n", secretHash)
+}
+
+/*
+createSwap блокує amount монет адреси from контрактом script.HTLCScript з хешем секрету secretHash
+і часом повернення через timeout, передає транзакцію вузлу і зберігає контракт у файл out.
+*/
+func createSwap(from string, to string, amount int, fee int, secretHash []byte, timeout time.Duration, out string, nodeID string, rpcAddress string) {
+	if !wal.ValidateAddress(from) {
+		log.Fatal("ERROR: address from is not valid")
+	}
+	recipientScript, err := wal.PayToAddrScript(to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	recipientHash := script.ExtractPubKeyHash(recipientScript)
+	if recipientHash == nil {
+		log.Fatal("ERROR: address to must be a wallet address")
+	}
+
+	wallets, err := ws.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet, ok := wallets.Wallets[from]
+	if !ok {
+		log.Fatalf("ERROR: wallet of node %s has no key for %s", nodeID, from)
+	}
+
+	lockTime := time.Now().Add(timeout).Unix()
+	contract, err := script.HTLCScript(script.HTLC{
+		SecretHash:    secretHash,
+		RecipientHash: recipientHash,
+		RefundHash:    wal.HashPubKey(wallet.PublicKey),
+		LockTime:      lockTime,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	contractAddress := fmt.Sprintf("%s", wal.ScriptHashAddress(contract))
+
+	tx, err := blockchain.BuildTransaction(wallet, contractAddress, amount, fee, 0, findUnspent(from, nodeID, rpcAddress))
+	if err != nil {
+		log.Fatal(err)
+	}
+	swap, err := transaction.NewSwapContract(contract, *tx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// контракт потрібен для повернення монет, тому його зберігаємо до передачі транзакції
+	writeSwap(out, swap)
+	sendTransaction(tx, rpcAddress)
+
+	fmt.Printf("Contract address:     %s\n", contractAddress)
+	fmt.Printf("Contract transaction: %x\n", tx.ID)
+	fmt.Printf("Refund is possible after %s\n", time.Unix(lockTime, 0).Format(time.RFC3339))
+	fmt.Printf("Contract is saved to %s\n", out)
+}
+
+/*
+readSwap читає контракт обміну з файлу, в якому він записаний в hex.
+*/
+func readSwap(file string) *transaction.SwapContract {
+	swap, err := transaction.DecodeSwapContract(readHexFile(file))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return swap
+}
+
+func writeSwap(file string, swap *transaction.SwapContract) {
+	writeHexFile(file, swap.Serialize())
+}
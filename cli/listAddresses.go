@@ -16,4 +16,9 @@ func (cli *CLI) listAddresses(nodeID string) {
 	for _, address := range addresses {
 		fmt.Println(address)
 	}
+
+	for _, address := range wallets.GetMultiSigAddresses() {
+		ms := wallets.GetMultiSig(address)
+		fmt.Printf("%s (multisig %d of %d)\n", address, ms.M, len(ms.PubKeys))
+	}
 }
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+/*
+participateSwap відповідає на обмін, який почав ініціатор to: блокує amount монет адреси from контрактом
+з тим самим хешем секрету secretHash ( див. auditswap ). Ініціатор забирає їх, розкриваючи секрет,
+після чого учасник може забрати монети ініціатора тим самим секретом.
+timeout має бути коротшим, ніж у контракті ініціатора, щоб учасник встиг забрати монети до повернення.
+*/
+func (cli *CLI) participateSwap(from string, to string, amount int, fee int, secretHash string, timeout time.Duration, out string, nodeID string, rpcAddress string) {
+	hash, err := hex.DecodeString(secretHash)
+	if err != nil || len(hash) != sha256.Size {
+		log.Fatalf("ERROR: secret hash must be %d bytes in hex", sha256.Size)
+	}
+
+	createSwap(from, to, amount, fee, hash, timeout, out, nodeID, rpcAddress)
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"blockchain1/blockchain"
 	"blockchain1/bloks"
+	"blockchain1/script"
 	"fmt"
 	"strconv"
 )
@@ -20,7 +21,9 @@ func (cli *CLI) printChain(nodeID string) {
 		fmt.Printf("============ Block %x ============\n", block.Hash)
 		fmt.Printf("Height: %d\n", block.Height)
 		fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
-		pow := bloks.NewProofOfWork(block)
+		fmt.Printf("Version: %d\n", block.Version)
+		fmt.Printf("Bits: %08x\n", block.Bits)
+		pow := bloks.NewProofOfWork(&block.BlockHeader)
 		fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
 		for _, tx := range block.Transactions {
 			fmt.Printf("Transaction ID: %xn", tx.ID)
@@ -28,13 +31,21 @@ func (cli *CLI) printChain(nodeID string) {
 			for _, vin := range tx.VIn {
 				fmt.Printf("tTxID: %x\n", vin.TxId)
 				fmt.Printf("tVout: %d\n", vin.VOut)
-				fmt.Printf("tSignature: %x\n", vin.Signature)
-				fmt.Printf("tPubKey: %x\n", vin.PubKey)
+				if tx.IsCoinbase() {
+					fmt.Printf("tCoinbase: %x\n", tx.CoinbaseData())
+					continue
+				}
+				unlockingScript, err := vin.UnlockingScript()
+				if err != nil {
+					fmt.Printf("tScriptSig: [error: %v]\n", err)
+					continue
+				}
+				fmt.Printf("tScriptSig: %s\n", script.Disassemble(unlockingScript))
 			}
 			fmt.Println("VOut:")
 			for _, vout := range tx.VOut {
 				fmt.Printf("tValue: %d\n", vout.Value)
-				fmt.Printf("tScriptPubKey: %x\n", vout.PubKeyHash)
+				fmt.Printf("tScriptPubKey: %s\n", script.Disassemble(vout.LockingScript()))
 			}
 			fmt.Println()
 		}
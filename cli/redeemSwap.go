@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"blockchain1/lib/utils"
+	wal "blockchain1/wallet"
+	ws "blockchain1/wallets"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+/*
+redeemSwap забирає монети контракту обміну з файлу file на адресу отримувача, розкриваючи secret.
+Ключ отримувача береться з гаманця вузла. Ініціатор забирає так монети учасника своїм секретом,
+а учасник - монети ініціатора секретом, який auditswap знайшов у транзакції ініціатора.
+*/
+func (cli *CLI) redeemSwap(file string, secret string, fee int, nodeID string, rpcAddress string) {
+	swap := readSwap(file)
+
+	secretBytes, err := hex.DecodeString(secret)
+	if err != nil {
+		log.Fatal("ERROR: secret must be in hex")
+	}
+
+	address := fmt.Sprintf("%s", wal.PubKeyHashAddress(swap.HTLC().RecipientHash))
+	tx, err := swap.Redeem(secretBytes, fee, *swapKey(nodeID, address))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sendTransaction(tx, rpcAddress)
+	fmt.Printf("Contract is redeemed to %s by transaction %x\n", address, tx.ID)
+}
+
+/*
+swapKey повертає закритий ключ адреси address з гаманця вузла nodeID.
+*/
+func swapKey(nodeID string, address string) *ecdsa.PrivateKey {
+	wallets, err := ws.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		log.Fatalf("ERROR: wallet of node %s has no key for %s", nodeID, address)
+	}
+
+	privateKey, err := utils.PrivateKeyFromBytes(wallet.PrivateKey)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return privateKey
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	wal "blockchain1/wallet"
+	"fmt"
+	"log"
+)
+
+/*
+refundSwap повертає монети контракту обміну з файлу file відправнику, якщо отримувач не забрав їх
+до часу повернення. Вузли приймуть транзакцію лише після цього часу, тобто коли медіанний час
+останніх блоків його перевищить.
+*/
+func (cli *CLI) refundSwap(file string, fee int, nodeID string, rpcAddress string) {
+	swap := readSwap(file)
+
+	address := fmt.Sprintf("%s", wal.PubKeyHashAddress(swap.HTLC().RefundHash))
+	tx, err := swap.Refund(fee, *swapKey(nodeID, address))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sendTransaction(tx, rpcAddress)
+	fmt.Printf("Contract is refunded to %s by transaction %x\n", address, tx.ID)
+}
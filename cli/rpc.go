@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"blockchain1/server"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+/*
+rpcCall викликає метод method вузла за адресою address і друкує результат.
+Параметри, які є коректним JSON ( числа, true/false, масиви ), передаються як є, інші - як рядки.
+*/
+func (cli *CLI) rpcCall(address string, method string, args []string) {
+	var params []interface{}
+	for _, arg := range args {
+		var value interface{}
+		if json.Unmarshal([]byte(arg), &value) != nil {
+			value = arg
+		}
+		params = append(params, value)
+	}
+
+	var result json.RawMessage
+	err := server.NewRPCClient(address).Call(method, params, &result)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var pretty interface{}
+	_ = json.Unmarshal(result, &pretty)
+	out, err := json.MarshalIndent(pretty, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(string(out))
+}
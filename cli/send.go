@@ -2,15 +2,22 @@ package cli
 
 import (
 	"blockchain1/blockchain"
+	"blockchain1/chaincfg"
 	"blockchain1/server"
 	"blockchain1/transaction"
 	wal "blockchain1/wallet"
 	ws "blockchain1/wallets"
+	"encoding/hex"
 	"fmt"
 	"log"
 )
 
-func (cli *CLI) send(from string, to string, amount int, nodeID string, mineNow bool) {
+/*
+send створює транзакцію з UTXO set локальної бази даних і передає її центральному вузлу
+або, якщо mineNow, одразу видобуває з нею блок. Ненульовий lockTime - висота блоку або unix час,
+до якого транзакцію не можна включити в блок.
+*/
+func (cli *CLI) send(from string, to string, amount int, fee int, lockTime uint32, nodeID string, mineNow bool) {
 
 	if !wal.ValidateAddress(from) {
 		log.Fatal("ERROR: address from is not valid")
@@ -31,16 +38,98 @@ func (cli *CLI) send(from string, to string, amount int, nodeID string, mineNow
 	}
 	wallet := wallets.GetWallet(from)
 
-	tx := blockchain.NewUTXOTransaction(&wallet, to, amount, &UTXOSet)
+	tx := blockchain.NewUTXOTransaction(&wallet, to, amount, fee, lockTime, &UTXOSet)
 
 	if mineNow {
-		cbTx := transaction.NewCoinbaseTX(from, "")
+		cbTx := transaction.NewCoinbaseTX(from, "", bc.GetBestHeight()+1, fee)
 		txs := []*transaction.Transaction{cbTx, tx}
 
-		newBlock := bc.MineBlock(txs)
-		UTXOSet.Update(newBlock)
+		// MineBlock також оновлює UTXO set новим блоком
+		bc.MineBlock(txs)
 	} else {
-		server.SendTx(server.KnownNodes[0], tx)
+		server.SendTx(chaincfg.ActiveNetParams.CentralNode(), tx)
 	}
 	fmt.Println("Success!")
 }
+
+/*
+sendRPC створює транзакцію з непотрачених виходів, які повертає запущений вузол,
+підписує її ключем з локального гаманця та передає вузлу через JSON-RPC.
+*/
+func (cli *CLI) sendRPC(from string, to string, amount int, fee int, lockTime uint32, nodeID string, rpcAddress string) {
+	if !wal.ValidateAddress(from) {
+		log.Fatal("ERROR: address from is not valid")
+	}
+	if !wal.ValidateAddress(to) {
+		log.Fatal("ERROR: address to is not valid")
+	}
+
+	wallets, err := ws.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet := wallets.GetWallet(from)
+
+	client := server.NewRPCClient(rpcAddress)
+
+	unspent := listUnspentRPC(client, from)
+
+	tx, err := blockchain.BuildTransaction(&wallet, to, amount, fee, lockTime, unspent)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var txID string
+	err = client.Call("sendrawtransaction", []interface{}{hex.EncodeToString(tx.Serialize())}, &txID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Transaction %s is sent\n", txID)
+}
+
+/*
+findUnspent повертає непотрачені виходи адреси address з UTXO set локальної бази даних
+або, якщо rpcAddress не порожній, від запущеного вузла.
+*/
+func findUnspent(address string, nodeID string, rpcAddress string) []blockchain.UnspentOutput {
+	if rpcAddress != "" {
+		return listUnspentRPC(server.NewRPCClient(rpcAddress), address)
+	}
+
+	bc := blockchain.NewBlockchain(nodeID)
+	defer func() { _ = bc.Db.Close() }()
+
+	lockingScript, err := wal.PayToAddrScript(address)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return blockchain.UTXOSet{Blockchain: bc}.FindUnspentOutputs(lockingScript)
+}
+
+/*
+listUnspentRPC отримує від запущеного вузла непотрачені виходи адреси address.
+*/
+func listUnspentRPC(client *server.RPCClient, address string) []blockchain.UnspentOutput {
+	var unspentInfo []server.UnspentInfo
+	err := client.Call("listunspent", []interface{}{address}, &unspentInfo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var unspent []blockchain.UnspentOutput
+	for _, info := range unspentInfo {
+		lockingScript, err := hex.DecodeString(info.ScriptPubKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		unspent = append(unspent, blockchain.UnspentOutput{
+			TxId:   info.TxId,
+			VOut:   info.VOut,
+			Output: transaction.TXOutput{Value: info.Value, Script: lockingScript},
+		})
+	}
+
+	return unspent
+}
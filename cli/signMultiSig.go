@@ -0,0 +1,9 @@
+package cli
+
+/*
+signMultiSig підписує транзакцію з файлу file ( див. spendmultisig ) ключами гаманців вузлів walletIDs,
+які входять до multisig адреси, і записує підписи назад у файл.
+*/
+func (cli *CLI) signMultiSig(file string, walletIDs []string) {
+	cli.signPSBT(file, walletIDs)
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"blockchain1/lib/utils"
+	ws "blockchain1/wallets"
+	"fmt"
+	"log"
+)
+
+/*
+signPSBT підписує PSBT з файлу file ключами гаманців вузлів walletIDs і записує підписи назад у файл.
+База даних не потрібна, тому PSBT можна підписати на машині, яка не підключена до мережі.
+Redeem script multisig адрес гаманців додаються до входів, які їх потребують.
+*/
+func (cli *CLI) signPSBT(file string, walletIDs []string) {
+	psbt := readPSBT(file)
+
+	signed := 0
+	for _, walletID := range walletIDs {
+		wallets, err := ws.NewWallets(walletID)
+		if err != nil {
+			log.Fatalf("ERROR: can't load wallets of node %s: %v", walletID, err)
+		}
+
+		for _, address := range wallets.GetMultiSigAddresses() {
+			psbt.AddRedeemScript(wallets.GetMultiSig(address).RedeemScript())
+		}
+
+		for _, address := range wallets.GetAddresses() {
+			privateKey, err := utils.PrivateKeyFromBytes(wallets.Wallets[address].PrivateKey)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			if inputs := psbt.Sign(*privateKey); inputs > 0 {
+				fmt.Printf("Signed %d inputs with %s\n", inputs, address)
+				signed += inputs
+			}
+		}
+	}
+	if signed == 0 {
+		log.Fatal("ERROR: wallets have no keys for inputs of the PSBT")
+	}
+
+	writePSBT(file, psbt)
+	printPSBTStatus(psbt)
+}
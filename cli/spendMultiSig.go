@@ -0,0 +1,24 @@
+package cli
+
+import (
+	ws "blockchain1/wallets"
+	"log"
+)
+
+/*
+spendMultiSig створює транзакцію, яка витрачає виходи multisig адреси from з гаманця вузла,
+і зберігає її без підписів у файл file. Учасники підписують файл командою signmultisig.
+Файл - це PSBT, тому з ним працюють і команди signpsbt, combinepsbt та finalizepsbt.
+Якщо rpcAddress не порожній, виходи отримуються від запущеного вузла.
+*/
+func (cli *CLI) spendMultiSig(from string, to string, amount int, fee int, file string, nodeID string, rpcAddress string) {
+	wallets, err := ws.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.GetMultiSig(from) == nil {
+		log.Fatal("ERROR: address from is not a multisig address of the wallet, add it with createmultisig")
+	}
+
+	cli.createPSBT(from, to, amount, fee, file, nodeID, rpcAddress)
+}
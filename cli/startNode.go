@@ -5,9 +5,11 @@ import (
 	wal "blockchain1/wallet"
 	"fmt"
 	"log"
+	"time"
 )
 
-func (cli *CLI) startNode(nodeID, minerAddress string) {
+func (cli *CLI) startNode(nodeID, minerAddress string, maxInbound, maxOutbound int, rpcAddress string,
+	memPoolSize int, memPoolExpiry time.Duration) {
 	fmt.Printf("Starting node %s\n", nodeID)
 	if len(minerAddress) > 0 {
 		if wal.ValidateAddress(minerAddress) {
@@ -16,5 +18,11 @@ func (cli *CLI) startNode(nodeID, minerAddress string) {
 			log.Panic("Wrong miner address!")
 		}
 	}
-	server.StartServer(nodeID, minerAddress)
+	node := server.NewNode(nodeID, minerAddress)
+	node.MaxInboundPeers = maxInbound
+	node.MaxOutboundPeers = maxOutbound
+	node.RPCAddress = rpcAddress
+	node.MemPoolMaxSize = memPoolSize
+	node.MemPoolExpiry = memPoolExpiry
+	server.StartServer(node)
 }
@@ -0,0 +1,197 @@
+package serialize
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+Канонічне бінарне кодування: кожне значення має рівно одне представлення,
+тому закодовані дані можна хешувати і порівнювати на всіх вузлах.
+- беззнакові цілі записуються як varint мінімальної довжини,
+- знакові цілі - як zigzag varint ( -1 займає один байт ),
+- масиви байтів - як varint довжини, за яким йдуть самі байти,
+- uint32 - як 4 байти little-endian.
+*/
+
+var (
+	ErrUnexpectedEOF = errors.New("unexpected end of data")
+	ErrNonCanonical  = errors.New("non-canonical varint")
+	ErrTrailingData  = errors.New("unexpected data after the end of value")
+)
+
+/*
+Writer дописує закодовані значення до буфера.
+*/
+type Writer struct {
+	buf []byte
+}
+
+func (w *Writer) WriteUvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *Writer) WriteVarint(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *Writer) WriteUint32(v uint32) {
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, v)
+}
+
+/*
+WriteBytes записує довжину масиву, а за нею сам масив. nil і порожній масив кодуються однаково.
+*/
+func (w *Writer) WriteBytes(b []byte) {
+	w.WriteUvarint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+/*
+Reader читає закодовані значення. Перша помилка зберігається в Reader,
+після неї всі методи повертають нульові значення, тому помилку достатньо перевірити в кінці через Err.
+*/
+type Reader struct {
+	data []byte
+	err  error
+}
+
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+func (r *Reader) ReadUvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail(ErrUnexpectedEOF)
+		return 0
+	}
+	if n != len(binary.AppendUvarint(nil, v)) {
+		r.fail(ErrNonCanonical)
+		return 0
+	}
+	r.data = r.data[n:]
+
+	return v
+}
+
+func (r *Reader) ReadVarint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail(ErrUnexpectedEOF)
+		return 0
+	}
+	if n != len(binary.AppendVarint(nil, v)) {
+		r.fail(ErrNonCanonical)
+		return 0
+	}
+	r.data = r.data[n:]
+
+	return v
+}
+
+func (r *Reader) ReadUint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.fail(ErrUnexpectedEOF)
+		return 0
+	}
+
+	v := binary.LittleEndian.Uint32(r.data)
+	r.data = r.data[4:]
+
+	return v
+}
+
+/*
+ReadBytes читає масив байтів, записаний WriteBytes. Порожній масив повертається як nil.
+*/
+func (r *Reader) ReadBytes() []byte {
+	length := r.ReadCount()
+	if r.err != nil || length == 0 {
+		return nil
+	}
+
+	b := append([]byte{}, r.data[:length]...)
+	r.data = r.data[length:]
+
+	return b
+}
+
+/*
+ReadCount читає кількість елементів масиву. Кожен елемент займає хоча б один байт,
+тому кількість, більша за залишок даних, означає пошкоджені дані
+і не дає виділити під масив зайву пам'ять.
+*/
+func (r *Reader) ReadCount() int {
+	count := r.ReadUvarint()
+	if r.err != nil {
+		return 0
+	}
+	if count > uint64(len(r.data)) {
+		r.fail(ErrUnexpectedEOF)
+		return 0
+	}
+
+	return int(count)
+}
+
+/*
+ReadInt читає знакове ціле, яке має поміститися в int.
+*/
+func (r *Reader) ReadInt() int {
+	v := r.ReadVarint()
+	if int64(int(v)) != v {
+		r.fail(fmt.Errorf("value %d overflows int", v))
+		return 0
+	}
+
+	return int(v)
+}
+
+/*
+Fail зберігає помилку, яку виявив код, що читає значення, наприклад непідтримувану версію.
+*/
+func (r *Reader) Fail(err error) {
+	r.fail(err)
+}
+
+func (r *Reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+/*
+Err повертає першу помилку читання.
+*/
+func (r *Reader) Err() error {
+	return r.err
+}
+
+/*
+Finish перевіряє, що дані прочитано без помилок і повністю:
+зайві байти в кінці означають, що кодування не канонічне.
+*/
+func (r *Reader) Finish() error {
+	if r.err == nil && len(r.data) > 0 {
+		r.err = ErrTrailingData
+	}
+
+	return r.err
+}
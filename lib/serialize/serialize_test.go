@@ -0,0 +1,122 @@
+package serialize
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	uvarints := []uint64{0, 1, 127, 128, 300, math.MaxUint32, math.MaxUint64}
+	varints := []int64{0, 1, -1, 63, -64, 64, -65, math.MaxInt64, math.MinInt64}
+	uint32s := []uint32{0, 1, 0xdeadbeef, math.MaxUint32}
+	arrays := [][]byte{nil, {0}, []byte("data"), bytes.Repeat([]byte{0xff}, 300)}
+
+	w := &Writer{}
+	for _, v := range uvarints {
+		w.WriteUvarint(v)
+	}
+	for _, v := range varints {
+		w.WriteVarint(v)
+	}
+	for _, v := range uint32s {
+		w.WriteUint32(v)
+	}
+	for _, b := range arrays {
+		w.WriteBytes(b)
+	}
+
+	r := NewReader(w.Bytes())
+	for _, want := range uvarints {
+		if got := r.ReadUvarint(); got != want {
+			t.Errorf("ReadUvarint = %d, want %d", got, want)
+		}
+	}
+	for _, want := range varints {
+		if got := r.ReadVarint(); got != want {
+			t.Errorf("ReadVarint = %d, want %d", got, want)
+		}
+	}
+	for _, want := range uint32s {
+		if got := r.ReadUint32(); got != want {
+			t.Errorf("ReadUint32 = %d, want %d", got, want)
+		}
+	}
+	for _, want := range arrays {
+		if got := r.ReadBytes(); !bytes.Equal(got, want) {
+			t.Errorf("ReadBytes = %x, want %x", got, want)
+		}
+	}
+	if err := r.Finish(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanonicalEncoding(t *testing.T) {
+	// кожне значення має рівно одне представлення, тому однакові значення дають однакові байти
+	if got := (&Writer{}).Bytes(); len(got) != 0 {
+		t.Errorf("empty writer has %x", got)
+	}
+
+	w := &Writer{}
+	w.WriteVarint(-1)
+	if !bytes.Equal(w.Bytes(), []byte{0x01}) {
+		t.Errorf("-1 is encoded as %x", w.Bytes())
+	}
+
+	nilBytes, emptyBytes := &Writer{}, &Writer{}
+	nilBytes.WriteBytes(nil)
+	emptyBytes.WriteBytes([]byte{})
+	if !bytes.Equal(nilBytes.Bytes(), emptyBytes.Bytes()) {
+		t.Errorf("nil is %x, empty array is %x", nilBytes.Bytes(), emptyBytes.Bytes())
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		read func(r *Reader)
+		err  error
+	}{
+		{"uvarint padded with zero byte", []byte{0x81, 0x00}, func(r *Reader) { r.ReadUvarint() }, ErrNonCanonical},
+		{"zero padded", []byte{0x80, 0x80, 0x00}, func(r *Reader) { r.ReadUvarint() }, ErrNonCanonical},
+		{"varint padded with zero byte", []byte{0x82, 0x00}, func(r *Reader) { r.ReadVarint() }, ErrNonCanonical},
+		{"uvarint overflows uint64", bytes.Repeat([]byte{0xff}, 11), func(r *Reader) { r.ReadUvarint() }, ErrUnexpectedEOF},
+		{"empty uvarint", nil, func(r *Reader) { r.ReadUvarint() }, ErrUnexpectedEOF},
+		{"truncated uvarint", []byte{0x80}, func(r *Reader) { r.ReadUvarint() }, ErrUnexpectedEOF},
+		{"truncated varint", []byte{0xff}, func(r *Reader) { r.ReadVarint() }, ErrUnexpectedEOF},
+		{"truncated uint32", []byte{1, 2, 3}, func(r *Reader) { r.ReadUint32() }, ErrUnexpectedEOF},
+		{"truncated bytes", []byte{4, 'd', 'a', 't'}, func(r *Reader) { r.ReadBytes() }, ErrUnexpectedEOF},
+		{"count larger than data", []byte{3, 1, 2}, func(r *Reader) { r.ReadCount() }, ErrUnexpectedEOF},
+		{"huge count", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, func(r *Reader) { r.ReadCount() }, ErrUnexpectedEOF},
+		{"trailing data", []byte{1, 2}, func(r *Reader) { r.ReadUvarint() }, ErrTrailingData},
+	}
+
+	for _, test := range tests {
+		r := NewReader(test.data)
+		test.read(r)
+		if err := r.Finish(); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestReaderKeepsFirstError(t *testing.T) {
+	failure := errors.New("unsupported version")
+
+	r := NewReader([]byte{1, 2, 3})
+	r.Fail(failure)
+	if v := r.ReadUvarint(); v != 0 {
+		t.Errorf("ReadUvarint after error = %d", v)
+	}
+	if b := r.ReadBytes(); b != nil {
+		t.Errorf("ReadBytes after error = %x", b)
+	}
+	r.Fail(ErrUnexpectedEOF)
+
+	if err := r.Finish(); !errors.Is(err, failure) {
+		t.Errorf("got %v, want %v", err, failure)
+	}
+}
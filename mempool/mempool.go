@@ -0,0 +1,401 @@
+package mempool
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/bloks"
+	"blockchain1/transaction"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+DefaultMaxSize - максимальний сумарний розмір транзакцій у пулі в байтах за замовчуванням.
+DefaultExpiry - скільки транзакція може чекати в пулі на включення в блок за замовчуванням.
+*/
+const (
+	DefaultMaxSize = 5 * 1024 * 1024
+	DefaultExpiry  = 14 * 24 * time.Hour
+)
+
+/*
+Причини, з яких пул відхиляє транзакцію. Помилки перевірки транзакції відносно
+ланцюга повертаються як blockchain.RuleError.
+*/
+var (
+	ErrCoinbase      = errors.New("coinbase transaction can't be added to memory pool")
+	ErrAlreadyInPool = errors.New("transaction is already in memory pool")
+	ErrConflict      = errors.New("transaction spends output already spent by memory pool transaction")
+	ErrPoolFull      = errors.New("memory pool is full and transaction fee rate is too low")
+)
+
+/*
+TxDesc описує транзакцію в пулі.
+- Tx - сама транзакція.
+- Fee - комісія транзакції.
+- Size - розмір серіалізованої транзакції в байтах.
+- Added - час додавання в пул.
+*/
+type TxDesc struct {
+	Tx    *transaction.Transaction
+	Fee   int
+	Size  int
+	Added time.Time
+}
+
+/*
+feeRateLess повідомляє, чи комісія на байт транзакції a менша, ніж у b.
+*/
+func feeRateLess(a *TxDesc, b *TxDesc) bool {
+	return a.Fee*b.Size < b.Fee*a.Size
+}
+
+/*
+TxPool - пул транзакцій, які очікують включення в блок.
+Кожна транзакція перевіряється перед додаванням відносно UTXO set разом з виходами
+інших транзакцій пулу, а індекс spent не дозволяє двом транзакціям пулу витрачати один і той самий вихід.
+Методи TxPool безпечні для одночасного виклику з різних горутин.
+- MaxSize - максимальний сумарний розмір транзакцій. Коли його перевищено,
+з пулу витісняються транзакції з найменшою комісією на байт.
+- Expiry - через скільки часу транзакція видаляється з пулу, якщо її не включили в блок.
+*/
+type TxPool struct {
+	MaxSize int
+	Expiry  time.Duration
+
+	bc *blockchain.Blockchain
+
+	mu    sync.RWMutex
+	pool  map[string]*TxDesc
+	spent map[transaction.OutPoint]string
+	size  int
+}
+
+func New(bc *blockchain.Blockchain) *TxPool {
+	return &TxPool{
+		MaxSize: DefaultMaxSize,
+		Expiry:  DefaultExpiry,
+		bc:      bc,
+		pool:    make(map[string]*TxDesc),
+		spent:   make(map[transaction.OutPoint]string),
+	}
+}
+
+/*
+Add перевіряє транзакцію і додає її в пул.
+Транзакція має бути правильно підписана, витрачати лише існуючі непотрачені виходи
+і не конфліктувати з іншими транзакціями пулу. Витрачати можна і виходи транзакцій,
+які ще чекають у пулі, тоді транзакція стає їх нащадком.
+*/
+func (mp *TxPool) Add(tx *transaction.Transaction) (*TxDesc, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.expireLocked(time.Now())
+
+	if tx.IsCoinbase() {
+		return nil, ErrCoinbase
+	}
+
+	txID := hex.EncodeToString(tx.ID)
+	if _, ok := mp.pool[txID]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyInPool, txID)
+	}
+
+	for _, vin := range tx.VIn {
+		if conflict, ok := mp.spent[vin.OutPoint()]; ok {
+			return nil, fmt.Errorf("%w: output %s:%d is spent by %s", ErrConflict, vin.OutPoint().TxId, vin.VOut, conflict)
+		}
+	}
+
+	// входи можуть витрачати як підтверджені виходи, так і виходи інших транзакцій пулу
+	fee, err := blockchain.ValidateTransaction(tx, poolView{mp}, mp.bc.SpendContext())
+	if err != nil {
+		return nil, err
+	}
+
+	desc := &TxDesc{
+		Tx:    tx,
+		Fee:   fee,
+		Size:  len(tx.Serialize()),
+		Added: time.Now(),
+	}
+
+	err = mp.makeRoomLocked(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	mp.pool[txID] = desc
+	for _, vin := range tx.VIn {
+		mp.spent[vin.OutPoint()] = txID
+	}
+	mp.size += desc.Size
+
+	return desc, nil
+}
+
+/*
+Remove видаляє транзакцію з пулу разом з її нащадками, якщо вона там є.
+*/
+func (mp *TxPool) Remove(txID []byte) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.removeLocked(hex.EncodeToString(txID), true)
+}
+
+/*
+Has повідомляє, чи є транзакція в пулі.
+*/
+func (mp *TxPool) Has(txID []byte) bool {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	_, ok := mp.pool[hex.EncodeToString(txID)]
+
+	return ok
+}
+
+/*
+Get повертає транзакцію з пулу.
+*/
+func (mp *TxPool) Get(txID []byte) (*transaction.Transaction, bool) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	desc, ok := mp.pool[hex.EncodeToString(txID)]
+	if !ok {
+		return nil, false
+	}
+
+	return desc.Tx, true
+}
+
+/*
+Count повертає кількість транзакцій у пулі.
+*/
+func (mp *TxPool) Count() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return len(mp.pool)
+}
+
+/*
+Size повертає сумарний розмір транзакцій у пулі в байтах.
+*/
+func (mp *TxPool) Size() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.size
+}
+
+/*
+TxDescs повертає всі транзакції пулу, впорядковані від найбільшої комісії на байт до найменшої.
+*/
+func (mp *TxPool) TxDescs() []*TxDesc {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		descs = append(descs, desc)
+	}
+
+	sort.Slice(descs, func(i, j int) bool {
+		return feeRateLess(descs[j], descs[i])
+	})
+
+	return descs
+}
+
+/*
+BlockConnected видаляє з пулу транзакції, включені в блок,
+та транзакції, які витрачають ті самі виходи, що й транзакції блоку, разом з їх нащадками.
+Нащадки включених у блок транзакцій залишаються в пулі: тепер вони витрачають підтверджені виходи.
+*/
+func (mp *TxPool) BlockConnected(block *bloks.Block) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, tx := range block.Transactions {
+		mp.removeLocked(hex.EncodeToString(tx.ID), false)
+
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.VIn {
+			if conflict, ok := mp.spent[vin.OutPoint()]; ok {
+				mp.removeLocked(conflict, true)
+			}
+		}
+	}
+
+	mp.expireLocked(time.Now())
+}
+
+/*
+BlockDisconnected повертає в пул транзакції блоку, відключеного від основного ланцюга.
+Транзакції, які стали недійсними відносно нового UTXO set, відкидаються,
+як і нащадки в пулі, які без них стали недійсними.
+Транзакції повертаються в порядку блоку, а кілька відключених блоків потрібно передавати
+від найстарішого до колишньої вершини, щоб батьківські транзакції потрапили в пул раніше за дочірні.
+*/
+func (mp *TxPool) BlockDisconnected(block *bloks.Block) {
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		_, err := mp.Add(tx)
+		if err != nil && !errors.Is(err, ErrAlreadyInPool) {
+			mp.removeInvalidRedeemers(tx)
+		}
+	}
+}
+
+/*
+removeInvalidRedeemers перевіряє транзакції пулу, які витрачають виходи tx,
+і видаляє ті з них, що стали недійсними, разом з їх нащадками.
+*/
+func (mp *TxPool) removeInvalidRedeemers(tx *transaction.Transaction) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	txID := hex.EncodeToString(tx.ID)
+	for i := range tx.VOut {
+		redeemer, ok := mp.spent[transaction.OutPoint{TxId: txID, VOut: i}]
+		if !ok {
+			continue
+		}
+
+		_, err := blockchain.ValidateTransaction(mp.pool[redeemer].Tx, poolView{mp}, mp.bc.SpendContext())
+		if err != nil {
+			mp.removeLocked(redeemer, true)
+		}
+	}
+}
+
+/*
+Expire видаляє транзакції, які пробули в пулі довше за Expiry.
+*/
+func (mp *TxPool) Expire() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.expireLocked(time.Now())
+}
+
+func (mp *TxPool) expireLocked(now time.Time) {
+	if mp.Expiry <= 0 {
+		return
+	}
+
+	for txID, desc := range mp.pool {
+		if now.Sub(desc.Added) > mp.Expiry {
+			mp.removeLocked(txID, true)
+		}
+	}
+}
+
+/*
+makeRoomLocked звільняє місце для транзакції desc, витісняючи транзакції з меншою комісією на байт
+разом з їх нащадками. Якщо місця не вистачає навіть після витіснення всіх таких транзакцій
+або desc витрачає вихід транзакції, яку довелося б витіснити, пул не змінюється.
+*/
+func (mp *TxPool) makeRoomLocked(desc *TxDesc) error {
+	if mp.MaxSize <= 0 || mp.size+desc.Size <= mp.MaxSize {
+		return nil
+	}
+
+	var cheaper []*TxDesc
+	for _, other := range mp.pool {
+		if feeRateLess(other, desc) {
+			cheaper = append(cheaper, other)
+		}
+	}
+	sort.Slice(cheaper, func(i, j int) bool {
+		return feeRateLess(cheaper[i], cheaper[j])
+	})
+
+	evicted := make(map[string]bool)
+	freed := 0
+	for _, other := range cheaper {
+		if mp.size-freed+desc.Size <= mp.MaxSize {
+			break
+		}
+
+		for _, txID := range mp.descendantsLocked(hex.EncodeToString(other.Tx.ID)) {
+			if !evicted[txID] {
+				evicted[txID] = true
+				freed += mp.pool[txID].Size
+			}
+		}
+	}
+	if mp.size-freed+desc.Size > mp.MaxSize {
+		return ErrPoolFull
+	}
+	for _, vin := range desc.Tx.VIn {
+		if evicted[vin.OutPoint().TxId] {
+			return ErrPoolFull
+		}
+	}
+
+	for txID := range evicted {
+		mp.removeLocked(txID, false)
+	}
+
+	return nil
+}
+
+/*
+descendantsLocked повертає ідентифікатор транзакції txID та всіх транзакцій пулу,
+які прямо чи через інші транзакції пулу витрачають її виходи.
+*/
+func (mp *TxPool) descendantsLocked(txID string) []string {
+	descendants := []string{txID}
+	seen := map[string]bool{txID: true}
+
+	for i := 0; i < len(descendants); i++ {
+		desc := mp.pool[descendants[i]]
+		for vout := range desc.Tx.VOut {
+			redeemer, ok := mp.spent[transaction.OutPoint{TxId: descendants[i], VOut: vout}]
+			if ok && !seen[redeemer] {
+				seen[redeemer] = true
+				descendants = append(descendants, redeemer)
+			}
+		}
+	}
+
+	return descendants
+}
+
+/*
+removeLocked видаляє транзакцію з пулу. Якщо removeRedeemers, видаляються і її нащадки,
+які без неї стали б недійсними.
+*/
+func (mp *TxPool) removeLocked(txID string, removeRedeemers bool) {
+	desc, ok := mp.pool[txID]
+	if !ok {
+		return
+	}
+
+	if removeRedeemers {
+		for vout := range desc.Tx.VOut {
+			if redeemer, ok := mp.spent[transaction.OutPoint{TxId: txID, VOut: vout}]; ok {
+				mp.removeLocked(redeemer, true)
+			}
+		}
+	}
+
+	for _, vin := range desc.Tx.VIn {
+		delete(mp.spent, vin.OutPoint())
+	}
+	mp.size -= desc.Size
+	delete(mp.pool, txID)
+}
@@ -0,0 +1,301 @@
+package mempool
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/bloks"
+	"blockchain1/chaincfg"
+	"blockchain1/script"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+/*
+testPool - пул над ланцюгом regtest у тимчасовому каталозі, всі coinbase виходи якого належать wallet.
+*/
+type testPool struct {
+	*TxPool
+	bc        *blockchain.Blockchain
+	wallet    *wal.Wallet
+	address   string
+	coinbases []*transaction.Transaction
+}
+
+func newTestPool(t *testing.T, blocks int) *testPool {
+	t.Helper()
+
+	active := chaincfg.ActiveNetParams
+	chaincfg.ActiveNetParams = &chaincfg.RegTestParams
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ключі з коротшим записом координат не проходять перевірку підпису, тому беремо повні
+	wallet := wal.NewWallet()
+	for len(wallet.PublicKey) != 64 {
+		wallet = wal.NewWallet()
+	}
+	address := fmt.Sprintf("%s", wallet.GetAddress())
+
+	bc := blockchain.CreateBlockchain(address, "test")
+	t.Cleanup(func() {
+		_ = bc.Db.Close()
+		_ = os.Chdir(wd)
+		chaincfg.ActiveNetParams = active
+	})
+
+	p := &testPool{TxPool: New(bc), bc: bc, wallet: wallet, address: address}
+	genesis, err := bc.GetBlock(bc.GetBestHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.coinbases = append(p.coinbases, genesis.Transactions[0])
+	for i := 0; i < blocks; i++ {
+		block := p.mine()
+		p.coinbases = append(p.coinbases, block.Transactions[0])
+	}
+
+	return p
+}
+
+/*
+mine добуває блок з транзакціями txs над вершиною ланцюга.
+*/
+func (p *testPool) mine(txs ...*transaction.Transaction) *bloks.Block {
+	coinbase := transaction.NewCoinbaseTX(p.address, "", p.bc.GetBestHeight()+1, 0)
+
+	return p.bc.MineBlock(append([]*transaction.Transaction{coinbase}, txs...))
+}
+
+/*
+spend створює транзакцію, яка витрачає вихід vout транзакції prev гаманця пулу і переказує amount
+на той самий гаманець з комісією fee. Решта - другий вихід.
+*/
+func (p *testPool) spend(t *testing.T, prev *transaction.Transaction, vout int, amount int, fee int) *transaction.Transaction {
+	t.Helper()
+
+	unspent := []blockchain.UnspentOutput{{TxId: hex.EncodeToString(prev.ID), VOut: vout, Output: prev.VOut[vout]}}
+	for {
+		tx, err := blockchain.BuildTransaction(p.wallet, p.address, amount, fee, 0, unspent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// підпис з коротшим r або s так само не проходить перевірку, тоді підписуємо знову
+		if fullSignatures(tx) {
+			return tx
+		}
+	}
+}
+
+func fullSignatures(tx *transaction.Transaction) bool {
+	for _, vin := range tx.VIn {
+		unlocking, err := vin.UnlockingScript()
+		if err != nil {
+			return false
+		}
+		data, err := script.PushedData(unlocking)
+		if err != nil || len(data) == 0 || len(data[0]) != 64 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *testPool) mustAdd(t *testing.T, txs ...*transaction.Transaction) {
+	t.Helper()
+
+	for _, tx := range txs {
+		if _, err := p.Add(tx); err != nil {
+			t.Fatalf("transaction %x: %v", tx.ID, err)
+		}
+	}
+}
+
+func (p *testPool) expectTxs(t *testing.T, present []*transaction.Transaction, absent []*transaction.Transaction) {
+	t.Helper()
+
+	for _, tx := range present {
+		if !p.Has(tx.ID) {
+			t.Errorf("transaction %x is not in pool", tx.ID)
+		}
+	}
+	for _, tx := range absent {
+		if p.Has(tx.ID) {
+			t.Errorf("transaction %x is still in pool", tx.ID)
+		}
+	}
+	if p.Count() != len(present) {
+		t.Errorf("pool has %d transactions, want %d", p.Count(), len(present))
+	}
+}
+
+func TestAddRejectsInvalidTransactions(t *testing.T) {
+	p := newTestPool(t, 1)
+
+	tx := p.spend(t, p.coinbases[0], 0, 10, 1)
+	p.mustAdd(t, tx)
+
+	missing := p.spend(t, tx, 0, 5, 1)
+	missing.VIn[0].TxId = p.coinbases[1].ID[:31]
+	missing.ID = missing.Hash()
+
+	tests := []struct {
+		name string
+		tx   *transaction.Transaction
+		err  error
+	}{
+		{"repeated", tx, ErrAlreadyInPool},
+		{"conflicting spend", p.spend(t, p.coinbases[0], 0, 20, 2), ErrConflict},
+		{"coinbase", transaction.NewCoinbaseTX(p.address, "", 2, 0), ErrCoinbase},
+		{"unknown output", missing, blockchain.ErrMissingInput},
+	}
+
+	for _, test := range tests {
+		_, err := p.Add(test.tx)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+	p.expectTxs(t, []*transaction.Transaction{tx}, nil)
+}
+
+func TestAddAcceptsChainOfTransactions(t *testing.T) {
+	p := newTestPool(t, 0)
+
+	parent := p.spend(t, p.coinbases[0], 0, 60, 1)
+	child := p.spend(t, parent, 0, 10, 1)
+	grandchild := p.spend(t, child, 1, 20, 1)
+	p.mustAdd(t, parent, child, grandchild)
+
+	p.Remove(parent.ID)
+	p.expectTxs(t, nil, []*transaction.Transaction{parent, child, grandchild})
+	if p.Size() != 0 {
+		t.Errorf("pool size is %d after removing all transactions", p.Size())
+	}
+}
+
+func TestMakeRoomEvictsDescendants(t *testing.T) {
+	p := newTestPool(t, 2)
+
+	// дешевий батько з дорогим нащадком: нащадок не витісняється сам, але зникає разом з батьком
+	parent := p.spend(t, p.coinbases[0], 0, 60, 1)
+	child := p.spend(t, parent, 0, 10, 40)
+	unrelated := p.spend(t, p.coinbases[1], 0, 10, 30)
+	p.mustAdd(t, parent, child, unrelated)
+
+	rich := p.spend(t, p.coinbases[2], 0, 10, 20)
+	p.MaxSize = p.Size() + len(rich.Serialize()) - 1
+
+	p.mustAdd(t, rich)
+	p.expectTxs(t, []*transaction.Transaction{unrelated, rich}, []*transaction.Transaction{parent, child})
+	if p.Size() > p.MaxSize {
+		t.Errorf("pool size %d exceeds %d", p.Size(), p.MaxSize)
+	}
+}
+
+func TestMakeRoomRejectsCheapTransactions(t *testing.T) {
+	p := newTestPool(t, 2)
+
+	// батько - найдешевша транзакція пулу, тому витісняється першим
+	parent := p.spend(t, p.coinbases[0], 0, 60, 5)
+	other := p.spend(t, p.coinbases[1], 0, 10, 10)
+	p.mustAdd(t, parent, other)
+	p.MaxSize = p.Size()
+
+	tests := []struct {
+		name string
+		tx   *transaction.Transaction
+	}{
+		// місце можна звільнити лише витісненням транзакцій з більшою комісією на байт
+		{"cheaper than pool", p.spend(t, p.coinbases[2], 0, 10, 1)},
+		// нащадок дорожчий за батька, але витіснення батька зробило б його недійсним
+		{"spends evicted output", p.spend(t, parent, 0, 10, 50)},
+	}
+
+	for _, test := range tests {
+		_, err := p.Add(test.tx)
+		if !errors.Is(err, ErrPoolFull) {
+			t.Errorf("%s: got %v, want %v", test.name, err, ErrPoolFull)
+		}
+	}
+	p.expectTxs(t, []*transaction.Transaction{parent, other}, nil)
+}
+
+func TestExpire(t *testing.T) {
+	p := newTestPool(t, 1)
+	p.Expiry = time.Hour
+
+	old := p.spend(t, p.coinbases[0], 0, 60, 1)
+	oldChild := p.spend(t, old, 0, 10, 1)
+	fresh := p.spend(t, p.coinbases[1], 0, 10, 1)
+	p.mustAdd(t, old, oldChild, fresh)
+
+	// нащадок доданий нещодавно, але без батька він недійсний
+	p.pool[hex.EncodeToString(old.ID)].Added = time.Now().Add(-2 * time.Hour)
+	p.Expire()
+
+	p.expectTxs(t, []*transaction.Transaction{fresh}, []*transaction.Transaction{old, oldChild})
+}
+
+func TestBlockConnectedAndDisconnected(t *testing.T) {
+	p := newTestPool(t, 1)
+
+	parent := p.spend(t, p.coinbases[0], 0, 60, 1)
+	child := p.spend(t, parent, 0, 10, 1)
+	conflict := p.spend(t, p.coinbases[1], 0, 10, 1)
+	p.mustAdd(t, parent, child, conflict)
+
+	// блок з батьком: нащадок залишається, бо тепер витрачає підтверджений вихід
+	first := p.mine(parent)
+	p.BlockConnected(first)
+	p.expectTxs(t, []*transaction.Transaction{child, conflict}, []*transaction.Transaction{parent})
+
+	// блок з іншою транзакцією, що витрачає той самий вихід, що й conflict
+	replacement := p.spend(t, p.coinbases[1], 0, 20, 2)
+	second := p.mine(child, replacement)
+	p.BlockConnected(second)
+	p.expectTxs(t, nil, []*transaction.Transaction{child, conflict})
+
+	// реорганізація відключає обидва блоки, транзакції повертаються від найстарішого блоку
+	for i := 0; i < 2; i++ {
+		_, err := p.bc.DisconnectTip()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.BlockDisconnected(first)
+	p.BlockDisconnected(second)
+	p.expectTxs(t, []*transaction.Transaction{parent, child, replacement}, []*transaction.Transaction{conflict})
+}
+
+func TestBlockDisconnectedFromTipLosesChildren(t *testing.T) {
+	p := newTestPool(t, 0)
+
+	parent := p.spend(t, p.coinbases[0], 0, 60, 1)
+	child := p.spend(t, parent, 0, 10, 1)
+	first := p.mine(parent)
+	second := p.mine(child)
+
+	for i := 0; i < 2; i++ {
+		_, err := p.bc.DisconnectTip()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// від вершини нащадок повертається раніше за батька і відкидається, тому порядок важливий
+	p.BlockDisconnected(second)
+	p.BlockDisconnected(first)
+	p.expectTxs(t, []*transaction.Transaction{parent}, []*transaction.Transaction{child})
+}
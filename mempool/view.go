@@ -0,0 +1,158 @@
+package mempool
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/transaction"
+	"encoding/hex"
+	"fmt"
+)
+
+/*
+View повертає UTXOView, який накладає пул на підтверджений UTXO set:
+виходи транзакцій пулу вважаються непотраченими, а виходи, які транзакції пулу
+вже витратили, - потраченими. Через нього гаманець може створити кілька транзакцій
+між блоками, не витрачаючи один і той самий вихід двічі.
+*/
+func (mp *TxPool) View() blockchain.UTXOView {
+	return lockedView{mp}
+}
+
+/*
+lockedView - UTXOView пулу для зовнішніх викликів, кожен метод блокує пул на читання.
+*/
+type lockedView struct {
+	mp *TxPool
+}
+
+func (v lockedView) FindPrevOutputs(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.TXOutput, error) {
+	v.mp.mu.RLock()
+	defer v.mp.mu.RUnlock()
+
+	return poolView(v).FindPrevOutputs(tx)
+}
+
+func (v lockedView) FindPrevBlocks(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.SpendContext, error) {
+	v.mp.mu.RLock()
+	defer v.mp.mu.RUnlock()
+
+	return poolView(v).FindPrevBlocks(tx)
+}
+
+func (v lockedView) FindUnspentOutputs(lockingScript []byte) []blockchain.UnspentOutput {
+	v.mp.mu.RLock()
+	defer v.mp.mu.RUnlock()
+
+	return poolView(v).FindUnspentOutputs(lockingScript)
+}
+
+/*
+poolView - UTXOView пулу, яким користуються методи TxPool, що вже тримають mp.mu.
+*/
+type poolView struct {
+	mp *TxPool
+}
+
+/*
+FindPrevOutputs бере виходи транзакцій пулу з пулу, а решту - з UTXO set.
+Вихід, який уже витратила інша транзакція пулу, вважається відсутнім.
+*/
+func (v poolView) FindPrevOutputs(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.TXOutput, error) {
+	prevOutputs := make(map[transaction.OutPoint]transaction.TXOutput)
+	txID := hex.EncodeToString(tx.ID)
+	confirmed := transaction.Transaction{ID: tx.ID}
+
+	for _, vin := range tx.VIn {
+		outPoint := vin.OutPoint()
+
+		if redeemer, ok := v.mp.spent[outPoint]; ok && redeemer != txID {
+			return nil, fmt.Errorf("%w: output %s:%d is spent by %s", ErrConflict, outPoint.TxId, vin.VOut, redeemer)
+		}
+
+		parent, ok := v.mp.pool[outPoint.TxId]
+		if !ok {
+			confirmed.VIn = append(confirmed.VIn, vin)
+			continue
+		}
+		if vin.VOut < 0 || vin.VOut >= len(parent.Tx.VOut) {
+			return nil, blockchain.RuleError{
+				Err:         blockchain.ErrMissingInput,
+				Description: fmt.Sprintf("output %s:%d is not found in memory pool", outPoint.TxId, vin.VOut),
+			}
+		}
+		prevOutputs[outPoint] = parent.Tx.VOut[vin.VOut]
+	}
+
+	if len(confirmed.VIn) > 0 {
+		confirmedOutputs, err := blockchain.UTXOSet{Blockchain: v.mp.bc}.FindPrevOutputs(&confirmed)
+		if err != nil {
+			return nil, err
+		}
+		for outPoint, out := range confirmedOutputs {
+			prevOutputs[outPoint] = out
+		}
+	}
+
+	return prevOutputs, nil
+}
+
+/*
+FindPrevBlocks повертає для виходів транзакцій пулу наступний блок, до якого вони увійдуть,
+а для решти - блоки з UTXO set.
+*/
+func (v poolView) FindPrevBlocks(tx *transaction.Transaction) (map[transaction.OutPoint]transaction.SpendContext, error) {
+	prevBlocks := make(map[transaction.OutPoint]transaction.SpendContext)
+	confirmed := transaction.Transaction{ID: tx.ID}
+
+	for _, vin := range tx.VIn {
+		if _, ok := v.mp.pool[vin.OutPoint().TxId]; ok {
+			prevBlocks[vin.OutPoint()] = v.mp.bc.SpendContext()
+			continue
+		}
+		confirmed.VIn = append(confirmed.VIn, vin)
+	}
+
+	if len(confirmed.VIn) > 0 {
+		confirmedBlocks, err := blockchain.UTXOSet{Blockchain: v.mp.bc}.FindPrevBlocks(&confirmed)
+		if err != nil {
+			return nil, err
+		}
+		for outPoint, block := range confirmedBlocks {
+			prevBlocks[outPoint] = block
+		}
+	}
+
+	return prevBlocks, nil
+}
+
+/*
+FindUnspentOutputs повертає підтверджені виходи, які не витратили транзакції пулу,
+а потім непотрачені виходи транзакцій пулу.
+*/
+func (v poolView) FindUnspentOutputs(lockingScript []byte) []blockchain.UnspentOutput {
+	var unspent []blockchain.UnspentOutput
+
+	for _, utxo := range (blockchain.UTXOSet{Blockchain: v.mp.bc}).FindUnspentOutputs(lockingScript) {
+		if _, ok := v.mp.spent[transaction.OutPoint{TxId: utxo.TxId, VOut: utxo.VOut}]; !ok {
+			unspent = append(unspent, utxo)
+		}
+	}
+
+	for txID, desc := range v.mp.pool {
+		for vout, out := range desc.Tx.VOut {
+			if !out.IsLockedWithScript(lockingScript) {
+				continue
+			}
+			if _, ok := v.mp.spent[transaction.OutPoint{TxId: txID, VOut: vout}]; ok {
+				continue
+			}
+
+			unspent = append(unspent, blockchain.UnspentOutput{
+				TxId:   txID,
+				VOut:   vout,
+				Output: out,
+			})
+		}
+	}
+
+	return unspent
+}
@@ -12,6 +12,10 @@ type MerkleNode struct {
 	Data  []byte
 }
 
+/*
+NewMerkleTree будує дерево Меркла з переданих даних.
+Якщо на якомусь рівні дерева ( включно з листям ) непарна кількість вузлів, останній вузол дублюється.
+*/
 func NewMerkleTree(data [][]byte) *MerkleTree {
 	var nodes []MerkleNode
 
@@ -24,7 +28,11 @@ func NewMerkleTree(data [][]byte) *MerkleTree {
 		nodes = append(nodes, *node)
 	}
 
-	for i := 0; i < len(data)/2; i++ {
+	for len(nodes) > 1 {
+		if len(nodes)%2 != 0 {
+			nodes = append(nodes, nodes[len(nodes)-1])
+		}
+
 		var newLevel []MerkleNode
 
 		for j := 0; j < len(nodes); j += 2 {
@@ -0,0 +1,470 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+/*
+Обмеження, які не дають скрипту використати надто багато пам'яті чи часу:
+- MaxScriptSize - найбільший розмір скрипта в байтах.
+- MaxElementSize - найбільший розмір елемента стеку.
+- MaxStackSize - найбільша кількість елементів стеку.
+- MaxOpsPerScript - найбільша кількість операцій скрипта, крім операцій з даними.
+- MaxPubKeysPerMultiSig - найбільша кількість публічних ключів OpCheckMultiSig.
+- maxNumSize - найбільший розмір числа зі стеку, maxLockTimeSize - часу блокування.
+*/
+const (
+	MaxScriptSize         = 10000
+	MaxElementSize        = 520
+	MaxStackSize          = 1000
+	MaxOpsPerScript       = 201
+	MaxPubKeysPerMultiSig = 20
+
+	maxNumSize      = 4
+	maxLockTimeSize = 5
+)
+
+/*
+sequenceDisableFlag - біт відносного блокування, з яким OpCheckSequenceVerify нічого не перевіряє.
+*/
+const sequenceDisableFlag = 1 << 31
+
+/*
+Помилки виконання скрипта. Verify загортає їх у помилку з операцією, на якій скрипт зупинився.
+*/
+var (
+	ErrScriptTooBig          = errors.New("script is too big")
+	ErrMalformedPush         = errors.New("script data runs past end of script")
+	ErrElementTooBig         = errors.New("stack element is too big")
+	ErrStackOverflow         = errors.New("stack size limit exceeded")
+	ErrTooManyOps            = errors.New("script has too many operations")
+	ErrStackUnderflow        = errors.New("operation needs more stack elements")
+	ErrUnbalancedConditional = errors.New("script has unbalanced conditional")
+	ErrBadOpcode             = errors.New("opcode is unknown")
+	ErrEarlyReturn           = errors.New("script is terminated by OP_RETURN")
+	ErrVerifyFailed          = errors.New("verify operation failed")
+	ErrEvalFalse             = errors.New("script finished with false on top of stack")
+	ErrNotPushOnly           = errors.New("unlocking script has operations other than data pushes")
+	ErrNumberTooBig          = errors.New("number on stack is too big")
+	ErrBadKeyCount           = errors.New("multisig public key count is out of range")
+	ErrBadSigCount           = errors.New("multisig signature count is out of range")
+	ErrNegativeLockTime      = errors.New("lock time is negative")
+	ErrUnsatisfiedLockTime   = errors.New("lock time is not reached")
+)
+
+/*
+Checker перевіряє умови, які залежать від транзакції, що витрачає вихід:
+- CheckSig перевіряє підпис sig входу публічним ключем pubKey. scriptCode - скрипт,
+який виконується, підписані дані транзакції мають його враховувати.
+- CheckLockTime перевіряє, що час блокування lockTime ( висота блоку або час ) вже настав.
+- CheckSequence перевіряє, що відносне блокування входу не коротше за sequence.
+*/
+type Checker interface {
+	CheckSig(sig []byte, pubKey []byte, scriptCode []byte) bool
+	CheckLockTime(lockTime int64) bool
+	CheckSequence(sequence int64) bool
+}
+
+/*
+Verify перевіряє, що скрипт розблокування unlocking відкриває скрипт блокування locking:
+спочатку виконується unlocking, потім locking з тим самим стеком, і на вершині стеку має залишитися true.
+Скрипт розблокування може лише класти дані на стек.
+Якщо locking - PayToScriptHash, останній елемент, який поклав unlocking, - redeem script з цим хешем,
+і він виконується так само, як скрипт блокування, з рештою елементів unlocking на стеку.
+*/
+func Verify(unlocking []byte, locking []byte, checker Checker) error {
+	if !IsPushOnly(unlocking) {
+		return ErrNotPushOnly
+	}
+
+	e := engine{checker: checker}
+	err := e.execute(unlocking)
+	if err != nil {
+		return err
+	}
+	unlockingStack := append([][]byte{}, e.stack...)
+
+	err = e.execute(locking)
+	if err != nil {
+		return err
+	}
+	err = e.checkResult()
+	if err != nil {
+		return err
+	}
+
+	if ExtractScriptHash(locking) == nil {
+		return nil
+	}
+
+	e.stack = unlockingStack
+	redeemScript, err := e.pop()
+	if err != nil {
+		return err
+	}
+	err = e.execute(redeemScript)
+	if err != nil {
+		return fmt.Errorf("redeem script: %w", err)
+	}
+
+	return e.checkResult()
+}
+
+/*
+checkResult перевіряє, що скрипт завершився з true на вершині стеку.
+*/
+func (e *engine) checkResult() error {
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return ErrEvalFalse
+	}
+
+	return nil
+}
+
+/*
+engine - стан виконання скрипта.
+- stack - стек, спільний для скриптів розблокування і блокування.
+- conditions - стан вкладених OpIf: чи виконується поточна гілка кожного з них.
+- ops - кількість виконаних операцій поточного скрипта.
+- scriptCode - скрипт, що виконується, для перевірки підписів.
+*/
+type engine struct {
+	checker    Checker
+	stack      [][]byte
+	conditions []bool
+	ops        int
+	scriptCode []byte
+}
+
+func (e *engine) execute(script []byte) error {
+	if len(script) > MaxScriptSize {
+		return fmt.Errorf("%w: %d bytes", ErrScriptTooBig, len(script))
+	}
+
+	instructions, err := parse(script)
+	if err != nil {
+		return err
+	}
+
+	e.conditions = nil
+	e.ops = 0
+	e.scriptCode = script
+
+	for _, in := range instructions {
+		err := e.step(in)
+		if err != nil {
+			return fmt.Errorf("%s: %w", opName(in.opcode), err)
+		}
+		if len(e.stack) > MaxStackSize {
+			return ErrStackOverflow
+		}
+	}
+
+	if len(e.conditions) > 0 {
+		return ErrUnbalancedConditional
+	}
+
+	return nil
+}
+
+/*
+executing перевіряє, чи виконується поточна гілка, тобто чи виконуються гілки всіх вкладених OpIf.
+*/
+func (e *engine) executing() bool {
+	for _, condition := range e.conditions {
+		if !condition {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *engine) step(in instruction) error {
+	if len(in.data) > MaxElementSize {
+		return ErrElementTooBig
+	}
+	if !in.isPush() {
+		e.ops++
+		if e.ops > MaxOpsPerScript {
+			return ErrTooManyOps
+		}
+	}
+
+	// умовні операції обробляються і в гілках, що не виконуються, щоб знайти відповідні OpElse і OpEndIf
+	switch in.opcode {
+	case OpIf, OpNotIf:
+		condition := false
+		if e.executing() {
+			v, err := e.pop()
+			if err != nil {
+				return err
+			}
+			condition = asBool(v) == (in.opcode == OpIf)
+		}
+		e.conditions = append(e.conditions, condition)
+		return nil
+	case OpElse:
+		if len(e.conditions) == 0 {
+			return ErrUnbalancedConditional
+		}
+		e.conditions[len(e.conditions)-1] = !e.conditions[len(e.conditions)-1]
+		return nil
+	case OpEndIf:
+		if len(e.conditions) == 0 {
+			return ErrUnbalancedConditional
+		}
+		e.conditions = e.conditions[:len(e.conditions)-1]
+		return nil
+	}
+
+	if !e.executing() {
+		return nil
+	}
+
+	if in.isPush() {
+		e.push(pushValue(in))
+		return nil
+	}
+
+	switch in.opcode {
+	case OpNop:
+	case OpVerify:
+		return e.verify()
+	case OpReturn:
+		return ErrEarlyReturn
+
+	case OpDrop:
+		_, err := e.pop()
+		return err
+	case OpDup:
+		v, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		e.push(v)
+	case OpOver:
+		v, err := e.peek(1)
+		if err != nil {
+			return err
+		}
+		e.push(v)
+	case OpSwap:
+		if len(e.stack) < 2 {
+			return ErrStackUnderflow
+		}
+		n := len(e.stack)
+		e.stack[n-1], e.stack[n-2] = e.stack[n-2], e.stack[n-1]
+	case OpSize:
+		v, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		e.push(encodeNum(int64(len(v))))
+
+	case OpEqual, OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(fromBool(bytes.Equal(a, b)))
+		if in.opcode == OpEqualVerify {
+			return e.verify()
+		}
+
+	case OpSha256:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(v)
+		e.push(hash[:])
+	case OpHash160:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(Hash160(v))
+
+	case OpCheckSig, OpCheckSigVerify:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(fromBool(e.checker.CheckSig(sig, pubKey, e.scriptCode)))
+		if in.opcode == OpCheckSigVerify {
+			return e.verify()
+		}
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		if in.opcode == OpCheckMultiSigVerify {
+			return e.verify()
+		}
+
+	case OpCheckLockTimeVerify:
+		// як і в Bitcoin, час блокування залишається на стеку, тому за операцією зазвичай іде OpDrop
+		v, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		lockTime, err := decodeNum(v, maxLockTimeSize)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return ErrNegativeLockTime
+		}
+		if !e.checker.CheckLockTime(lockTime) {
+			return ErrUnsatisfiedLockTime
+		}
+	case OpCheckSequenceVerify:
+		// значення так само залишається на стеку
+		v, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		sequence, err := decodeNum(v, maxLockTimeSize)
+		if err != nil {
+			return err
+		}
+		if sequence < 0 {
+			return ErrNegativeLockTime
+		}
+		if sequence&sequenceDisableFlag != 0 {
+			return nil
+		}
+		if !e.checker.CheckSequence(sequence) {
+			return ErrUnsatisfiedLockTime
+		}
+
+	default:
+		return ErrBadOpcode
+	}
+
+	return nil
+}
+
+/*
+checkMultiSig виконує OpCheckMultiSig. Стек: <sig 1> ... <sig m> <m> <pubkey 1> ... <pubkey n> <n>.
+Підписи мають іти в тому самому порядку, що й ключі, якими їх зроблено. На відміну від Bitcoin,
+операція не забирає зі стеку зайвого елемента.
+*/
+func (e *engine) checkMultiSig() error {
+	n, err := e.popInt()
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > MaxPubKeysPerMultiSig {
+		return ErrBadKeyCount
+	}
+	e.ops += int(n)
+	if e.ops > MaxOpsPerScript {
+		return ErrTooManyOps
+	}
+	pubKeys, err := e.popN(int(n))
+	if err != nil {
+		return err
+	}
+
+	m, err := e.popInt()
+	if err != nil {
+		return err
+	}
+	if m < 0 || m > n {
+		return ErrBadSigCount
+	}
+	sigs, err := e.popN(int(m))
+	if err != nil {
+		return err
+	}
+
+	valid := true
+	for len(sigs) > 0 {
+		if len(sigs) > len(pubKeys) {
+			valid = false
+			break
+		}
+		if e.checker.CheckSig(sigs[0], pubKeys[0], e.scriptCode) {
+			sigs = sigs[1:]
+		}
+		pubKeys = pubKeys[1:]
+	}
+	e.push(fromBool(valid))
+
+	return nil
+}
+
+func (e *engine) verify() error {
+	v, err := e.pop()
+	if err != nil {
+		return err
+	}
+	if !asBool(v) {
+		return ErrVerifyFailed
+	}
+
+	return nil
+}
+
+func (e *engine) push(v []byte) {
+	e.stack = append(e.stack, v)
+}
+
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+
+	v := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return v, nil
+}
+
+/*
+peek повертає елемент на глибині depth від вершини стеку, не забираючи його.
+*/
+func (e *engine) peek(depth int) ([]byte, error) {
+	if len(e.stack) <= depth {
+		return nil, ErrStackUnderflow
+	}
+
+	return e.stack[len(e.stack)-1-depth], nil
+}
+
+func (e *engine) popInt() (int64, error) {
+	v, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+
+	return decodeNum(v, maxNumSize)
+}
+
+/*
+popN забирає зі стеку n елементів і повертає їх у тому порядку, в якому їх клали на стек.
+*/
+func (e *engine) popN(n int) ([][]byte, error) {
+	if len(e.stack) < n {
+		return nil, ErrStackUnderflow
+	}
+
+	items := append([][]byte{}, e.stack[len(e.stack)-n:]...)
+	e.stack = e.stack[:len(e.stack)-n]
+
+	return items, nil
+}
@@ -0,0 +1,206 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+/*
+testChecker замінює перевірку транзакції: підпис ключа pubKey - це "sig" і сам ключ,
+а блокування вважаються такими, що настали, до lockTime і sequence включно.
+*/
+type testChecker struct {
+	lockTime int64
+	sequence int64
+}
+
+func (c testChecker) CheckSig(sig []byte, pubKey []byte, scriptCode []byte) bool {
+	return bytes.Equal(sig, testSig(pubKey))
+}
+
+func (c testChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= c.lockTime
+}
+
+func (c testChecker) CheckSequence(sequence int64) bool {
+	return sequence <= c.sequence
+}
+
+func testSig(pubKey []byte) []byte {
+	return append([]byte("sig"), pubKey...)
+}
+
+func testKey(i byte) []byte {
+	return bytes.Repeat([]byte{i}, 64)
+}
+
+/*
+mustScript повертає скрипт, який тест складає з правильних даних, тому помилка - це помилка тесту.
+*/
+func mustScript(script []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+
+	return script
+}
+
+/*
+pushScript повертає скрипт розблокування, який кладе на стек items.
+*/
+func pushScript(items ...[]byte) []byte {
+	b := NewBuilder()
+	for _, item := range items {
+		b.AddData(item)
+	}
+
+	return mustScript(b.Script())
+}
+
+func TestVerifyStandardScripts(t *testing.T) {
+	key1, key2, key3 := testKey(1), testKey(2), testKey(3)
+
+	p2pkh := PayToPubKeyHash(Hash160(key1))
+
+	redeemScript := mustScript(MultiSigScript(2, [][]byte{key1, key2, key3}))
+	p2sh := PayToScriptHash(Hash160(redeemScript))
+	otherRedeemScript := mustScript(MultiSigScript(1, [][]byte{key1, key2}))
+
+	secret := bytes.Repeat([]byte{7}, SecretSize)
+	secretHash := sha256.Sum256(secret)
+	contract := mustScript(HTLCScript(HTLC{
+		SecretHash:    secretHash[:],
+		RecipientHash: Hash160(key1),
+		RefundHash:    Hash160(key2),
+		LockTime:      100,
+	}))
+	htlc := PayToScriptHash(Hash160(contract))
+
+	cltv := mustScript(NewBuilder().AddInt64(100).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).AddOp(Op1).Script())
+	negativeCLTV := mustScript(NewBuilder().AddInt64(-1).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).AddOp(Op1).Script())
+	csv := mustScript(NewBuilder().AddInt64(10).AddOp(OpCheckSequenceVerify).AddOp(OpDrop).AddOp(Op1).Script())
+	disabledCSV := mustScript(NewBuilder().AddInt64(sequenceDisableFlag | 10).AddOp(OpCheckSequenceVerify).AddOp(OpDrop).AddOp(Op1).Script())
+
+	late := testChecker{lockTime: 100, sequence: 10}
+	early := testChecker{lockTime: 99, sequence: 9}
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		locking   []byte
+		checker   testChecker
+		err       error
+	}{
+		{"p2pkh", SignatureScript(testSig(key1), key1), p2pkh, late, nil},
+		{"p2pkh wrong key", SignatureScript(testSig(key2), key2), p2pkh, late, ErrVerifyFailed},
+		{"p2pkh wrong signature", SignatureScript(testSig(key2), key1), p2pkh, late, ErrEvalFalse},
+		{"p2pkh no signature", pushScript(key1), p2pkh, late, ErrStackUnderflow},
+		{"unlocking with operations", append(SignatureScript(testSig(key1), key1), OpDup), p2pkh, late, ErrNotPushOnly},
+
+		{"bare multisig", pushScript(testSig(key1), testSig(key2)), redeemScript, late, nil},
+		{"p2sh multisig", pushScript(testSig(key1), testSig(key3), redeemScript), p2sh, late, nil},
+		{"p2sh multisig out of order", pushScript(testSig(key3), testSig(key1), redeemScript), p2sh, late, ErrEvalFalse},
+		{"p2sh multisig repeated signature", pushScript(testSig(key1), testSig(key1), redeemScript), p2sh, late, ErrEvalFalse},
+		{"p2sh multisig too few signatures", pushScript(testSig(key1), redeemScript), p2sh, late, ErrStackUnderflow},
+		{"p2sh bad script hash", pushScript(testSig(key1), otherRedeemScript), p2sh, late, ErrEvalFalse},
+		{"p2sh no redeem script", nil, p2sh, late, ErrStackUnderflow},
+
+		{"cltv", nil, cltv, late, nil},
+		{"cltv not reached", nil, cltv, early, ErrUnsatisfiedLockTime},
+		{"cltv negative", nil, negativeCLTV, late, ErrNegativeLockTime},
+		{"csv", nil, csv, late, nil},
+		{"csv not reached", nil, csv, early, ErrUnsatisfiedLockTime},
+		{"csv disabled", nil, disabledCSV, early, nil},
+
+		{"htlc redeem", RedeemHTLCScript(testSig(key1), key1, secret, contract), htlc, early, nil},
+		{"htlc redeem wrong secret", RedeemHTLCScript(testSig(key1), key1, bytes.Repeat([]byte{8}, SecretSize), contract), htlc, early, ErrVerifyFailed},
+		{"htlc redeem short secret", RedeemHTLCScript(testSig(key1), key1, secret[1:], contract), htlc, early, ErrVerifyFailed},
+		{"htlc redeem by sender", RedeemHTLCScript(testSig(key2), key2, secret, contract), htlc, early, ErrVerifyFailed},
+		{"htlc refund", RefundHTLCScript(testSig(key2), key2, contract), htlc, late, nil},
+		{"htlc refund too early", RefundHTLCScript(testSig(key2), key2, contract), htlc, early, ErrUnsatisfiedLockTime},
+		{"htlc refund by recipient", RefundHTLCScript(testSig(key1), key1, contract), htlc, late, ErrVerifyFailed},
+	}
+
+	for _, test := range tests {
+		err := Verify(test.unlocking, test.locking, test.checker)
+		if test.err == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+/*
+rawPush кладе на стек data через OpPushData2 без перевірок Builder.
+*/
+func rawPush(data []byte) []byte {
+	script := binary.LittleEndian.AppendUint16([]byte{OpPushData2}, uint16(len(data)))
+
+	return append(script, data...)
+}
+
+func TestVerifyLimits(t *testing.T) {
+	maxElement := bytes.Repeat([]byte{1}, MaxElementSize)
+
+	// 19 найбільших елементів і OP_1 до розміру рівно MaxScriptSize
+	var maxScript []byte
+	for len(maxScript)+len(rawPush(maxElement)) <= MaxScriptSize {
+		maxScript = append(maxScript, rawPush(maxElement)...)
+	}
+	maxScript = append(maxScript, bytes.Repeat([]byte{Op1}, MaxScriptSize-len(maxScript))...)
+
+	tests := []struct {
+		name    string
+		locking []byte
+		err     error
+	}{
+		{"largest element", append(rawPush(maxElement), OpDrop, Op1), nil},
+		{"element too big", append(rawPush(append(maxElement, 1)), OpDrop, Op1), ErrElementTooBig},
+		{"largest script", maxScript, nil},
+		{"script too big", append(maxScript, Op1), ErrScriptTooBig},
+		{"most operations", append(bytes.Repeat([]byte{OpNop}, MaxOpsPerScript), Op1), nil},
+		{"too many operations", append(bytes.Repeat([]byte{OpNop}, MaxOpsPerScript+1), Op1), ErrTooManyOps},
+		{"largest stack", bytes.Repeat([]byte{Op1}, MaxStackSize), nil},
+		{"stack overflow", bytes.Repeat([]byte{Op1}, MaxStackSize+1), ErrStackOverflow},
+		{"truncated push", rawPush(maxElement)[:100], ErrMalformedPush},
+		{"unbalanced if", []byte{Op1, OpIf, Op1}, ErrUnbalancedConditional},
+		{"unknown opcode", []byte{Op1, 0xff}, ErrBadOpcode},
+		{"return", []byte{Op1, OpReturn}, ErrEarlyReturn},
+	}
+
+	for _, test := range tests {
+		err := Verify(nil, test.locking, testChecker{})
+		if test.err == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestBuilderLimits(t *testing.T) {
+	_, err := NewBuilder().AddData(make([]byte, MaxElementSize)).Script()
+	if err != nil {
+		t.Errorf("largest element: %v", err)
+	}
+
+	_, err = NewBuilder().AddData(make([]byte, MaxElementSize+1)).Script()
+	if !errors.Is(err, ErrElementTooBig) {
+		t.Errorf("element too big: got %v, want %v", err, ErrElementTooBig)
+	}
+
+	b := NewBuilder()
+	for i := 0; i <= MaxScriptSize/MaxElementSize; i++ {
+		b.AddData(make([]byte, MaxElementSize))
+	}
+	_, err = b.Script()
+	if !errors.Is(err, ErrScriptTooBig) {
+		t.Errorf("script too big: got %v, want %v", err, ErrScriptTooBig)
+	}
+}
@@ -0,0 +1,79 @@
+package script
+
+/*
+Коди операцій скрипта. Значення збігаються з кодами операцій Bitcoin з тими самими назвами.
+- Op0 кладе на стек порожній масив, який означає нуль і false.
+- Коди від 0x01 до 0x4b кладуть на стек стільки наступних байтів скрипта, яке значення коду.
+- OpPushData1 та OpPushData2 кладуть на стек дані, довжина яких записана в наступних 1 або 2 байтах ( little-endian ).
+- Op1Negate та Op1 ... Op16 кладуть на стек числа -1 та 1 ... 16.
+*/
+const (
+	Op0         = 0x00
+	OpPushData1 = 0x4c
+	OpPushData2 = 0x4d
+	Op1Negate   = 0x4f
+	Op1         = 0x51
+	Op16        = 0x60
+
+	OpNop    = 0x61
+	OpIf     = 0x63
+	OpNotIf  = 0x64
+	OpElse   = 0x67
+	OpEndIf  = 0x68
+	OpVerify = 0x69
+	OpReturn = 0x6a
+
+	OpDrop = 0x75
+	OpDup  = 0x76
+	OpOver = 0x78
+	OpSwap = 0x7c
+	OpSize = 0x82
+
+	OpEqual       = 0x87
+	OpEqualVerify = 0x88
+
+	OpSha256  = 0xa8
+	OpHash160 = 0xa9
+
+	OpCheckSig            = 0xac
+	OpCheckSigVerify      = 0xad
+	OpCheckMultiSig       = 0xae
+	OpCheckMultiSigVerify = 0xaf
+
+	OpCheckLockTimeVerify = 0xb1
+	OpCheckSequenceVerify = 0xb2
+)
+
+/*
+maxDirectPush - найбільша довжина даних, які код операції кладе на стек без OpPushData1.
+*/
+const maxDirectPush = 0x4b
+
+var opNames = map[byte]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	Op1Negate:             "OP_1NEGATE",
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpOver:                "OP_OVER",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSha256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+}
@@ -0,0 +1,304 @@
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+/*
+instruction - одна операція розібраного скрипта: код операції і дані, які вона кладе на стек.
+*/
+type instruction struct {
+	opcode byte
+	data   []byte
+}
+
+func (in instruction) isPush() bool {
+	return in.opcode <= OpPushData2 || in.opcode == Op1Negate || (in.opcode >= Op1 && in.opcode <= Op16)
+}
+
+/*
+parse розбирає скрипт на операції. Повертає помилку, якщо дані операції виходять за межі скрипта.
+*/
+func parse(script []byte) ([]instruction, error) {
+	var instructions []instruction
+
+	for i := 0; i < len(script); {
+		opcode := script[i]
+		i++
+
+		size := 0
+		switch {
+		case opcode > Op0 && opcode <= maxDirectPush:
+			size = int(opcode)
+		case opcode == OpPushData1:
+			if i+1 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(script[i])
+			i++
+		case opcode == OpPushData2:
+			if i+2 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		}
+
+		if i+size > len(script) {
+			return nil, ErrMalformedPush
+		}
+		instructions = append(instructions, instruction{opcode: opcode, data: script[i : i+size]})
+		i += size
+	}
+
+	return instructions, nil
+}
+
+/*
+IsPushOnly перевіряє, що скрипт лише кладе дані на стек. Таким має бути скрипт розблокування.
+*/
+func IsPushOnly(script []byte) bool {
+	instructions, err := parse(script)
+	if err != nil {
+		return false
+	}
+
+	for _, in := range instructions {
+		if !in.isPush() {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+PushedData повертає дані, які кладе на стек скрипт, що складається лише з операцій з даними,
+наприклад підписи і публічні ключі скрипта розблокування.
+*/
+func PushedData(script []byte) ([][]byte, error) {
+	instructions, err := parse(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var data [][]byte
+	for _, in := range instructions {
+		if !in.isPush() {
+			return nil, ErrNotPushOnly
+		}
+		data = append(data, pushValue(in))
+	}
+
+	return data, nil
+}
+
+/*
+pushValue повертає значення, яке операція з даними кладе на стек.
+*/
+func pushValue(in instruction) []byte {
+	switch {
+	case in.opcode == Op1Negate:
+		return encodeNum(-1)
+	case in.opcode >= Op1 && in.opcode <= Op16:
+		return encodeNum(int64(in.opcode - Op1 + 1))
+	}
+
+	return in.data
+}
+
+/*
+Disassemble повертає текстове представлення скрипта: назви операцій і дані в hex.
+*/
+func Disassemble(script []byte) string {
+	instructions, err := parse(script)
+
+	var parts []string
+	for _, in := range instructions {
+		if in.opcode > Op0 && in.opcode <= OpPushData2 {
+			parts = append(parts, hex.EncodeToString(in.data))
+			continue
+		}
+		parts = append(parts, opName(in.opcode))
+	}
+	if err != nil {
+		parts = append(parts, "[error: "+err.Error()+"]")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func opName(opcode byte) string {
+	if opcode >= Op1 && opcode <= Op16 {
+		return fmt.Sprintf("OP_%d", opcode-Op1+1)
+	}
+	if name, ok := opNames[opcode]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("OP_UNKNOWN(0x%02x)", opcode)
+}
+
+/*
+Builder складає скрипт з операцій і даних. Помилка першого недопустимого елемента
+зберігається і повертається з Script.
+*/
+type Builder struct {
+	script []byte
+	err    error
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+/*
+AddOp дописує до скрипта операцію opcode.
+*/
+func (b *Builder) AddOp(opcode byte) *Builder {
+	b.script = append(b.script, opcode)
+
+	return b
+}
+
+/*
+AddData дописує до скрипта операцію, яка кладе data на стек, найкоротшим способом.
+*/
+func (b *Builder) AddData(data []byte) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if len(data) > MaxElementSize {
+		b.err = fmt.Errorf("%w: %d bytes", ErrElementTooBig, len(data))
+		return b
+	}
+
+	switch {
+	case len(data) == 0:
+		b.script = append(b.script, Op0)
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		b.script = append(b.script, Op1+data[0]-1)
+	case len(data) == 1 && data[0] == 0x81:
+		b.script = append(b.script, Op1Negate)
+	case len(data) <= maxDirectPush:
+		b.script = append(b.script, byte(len(data)))
+		b.script = append(b.script, data...)
+	case len(data) <= 0xff:
+		b.script = append(b.script, OpPushData1, byte(len(data)))
+		b.script = append(b.script, data...)
+	default:
+		b.script = append(b.script, OpPushData2)
+		b.script = binary.LittleEndian.AppendUint16(b.script, uint16(len(data)))
+		b.script = append(b.script, data...)
+	}
+
+	return b
+}
+
+/*
+AddInt64 дописує до скрипта операцію, яка кладе на стек число n.
+*/
+func (b *Builder) AddInt64(n int64) *Builder {
+	return b.AddData(encodeNum(n))
+}
+
+/*
+Script повертає складений скрипт або першу помилку.
+*/
+func (b *Builder) Script() ([]byte, error) {
+	if b.err == nil && len(b.script) > MaxScriptSize {
+		b.err = fmt.Errorf("%w: %d bytes", ErrScriptTooBig, len(b.script))
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return b.script, nil
+}
+
+/*
+Числа на стеку записуються в little-endian зі знаком у старшому біті останнього байта,
+нуль - порожнім масивом, як у Bitcoin.
+*/
+func encodeNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+
+	var result []byte
+	for abs > 0 {
+		result = append(result, byte(abs))
+		abs >>= 8
+	}
+
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0)
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+
+	return result
+}
+
+/*
+decodeNum розбирає число зі стеку, яке займає не більше maxLen байтів.
+*/
+func decodeNum(data []byte, maxLen int) (int64, error) {
+	if len(data) > maxLen {
+		return 0, fmt.Errorf("%w: %d bytes", ErrNumberTooBig, len(data))
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << (8 * i)
+	}
+
+	last := data[len(data)-1]
+	if last&0x80 != 0 {
+		n &^= int64(0x80) << (8 * (len(data) - 1))
+		n = -n
+	}
+
+	return n, nil
+}
+
+/*
+asBool перетворює елемент стеку на логічне значення: false - це нуль, у тому числі від'ємний.
+*/
+func asBool(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			// від'ємний нуль
+			if i == len(data)-1 && b == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+
+	return nil
+}
@@ -0,0 +1,313 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"golang.org/x/crypto/ripemd160"
+	"log"
+)
+
+/*
+PubKeyHashSize - розмір хешу публічного ключа Hash160.
+*/
+const PubKeyHashSize = ripemd160.Size
+
+/*
+Hash160 повертає RIPEMD160(SHA256(data)) - хеш, яким OpHash160 хешує публічні ключі.
+*/
+func Hash160(data []byte) []byte {
+	hash := sha256.Sum256(data)
+
+	hasher := ripemd160.New()
+	_, err := hasher.Write(hash[:])
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return hasher.Sum(nil)
+}
+
+/*
+PayToPubKeyHash повертає стандартний скрипт блокування виходу на хеш публічного ключа:
+OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG.
+Його відкриває скрипт розблокування SignatureScript з підписом і публічним ключем.
+*/
+func PayToPubKeyHash(pubKeyHash []byte) []byte {
+	script, err := NewBuilder().
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(pubKeyHash).
+		AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+/*
+ExtractPubKeyHash повертає хеш публічного ключа зі скрипта PayToPubKeyHash
+або nil, якщо скрипт має інший вигляд.
+*/
+func ExtractPubKeyHash(script []byte) []byte {
+	if len(script) != PubKeyHashSize+5 {
+		return nil
+	}
+	pubKeyHash := script[3 : 3+PubKeyHashSize]
+	if !bytes.Equal(script, PayToPubKeyHash(pubKeyHash)) {
+		return nil
+	}
+
+	return pubKeyHash
+}
+
+/*
+SignatureScript повертає скрипт розблокування виходу PayToPubKeyHash: <sig> <pubKey>.
+*/
+func SignatureScript(sig []byte, pubKey []byte) []byte {
+	script, err := NewBuilder().AddData(sig).AddData(pubKey).Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+/*
+PayToScriptHash повертає скрипт блокування виходу на хеш скрипта: OP_HASH160 <scriptHash> OP_EQUAL.
+Вхід, який його витрачає, кладе на стек сам скрипт ( redeem script ) останнім елементом скрипта розблокування,
+і після перевірки хешу redeem script виконується з рештою елементів ( див. Verify ).
+*/
+func PayToScriptHash(scriptHash []byte) []byte {
+	script, err := NewBuilder().
+		AddOp(OpHash160).
+		AddData(scriptHash).
+		AddOp(OpEqual).
+		Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+/*
+ExtractScriptHash повертає хеш скрипта зі скрипта PayToScriptHash
+або nil, якщо скрипт має інший вигляд.
+*/
+func ExtractScriptHash(script []byte) []byte {
+	if len(script) != PubKeyHashSize+3 {
+		return nil
+	}
+	scriptHash := script[2 : 2+PubKeyHashSize]
+	if !bytes.Equal(script, PayToScriptHash(scriptHash)) {
+		return nil
+	}
+
+	return scriptHash
+}
+
+/*
+MultiSigScript повертає скрипт M-of-N multisig: <m> <pubkey 1> ... <pubkey n> <n> OP_CHECKMULTISIG.
+Його відкривають m підписів, зроблених ключами pubKeys в тому ж порядку.
+*/
+func MultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxPubKeysPerMultiSig {
+		return nil, fmt.Errorf("%w: %d", ErrBadKeyCount, len(pubKeys))
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrBadSigCount, m, len(pubKeys))
+	}
+
+	b := NewBuilder().AddInt64(int64(m))
+	for _, pubKey := range pubKeys {
+		b.AddData(pubKey)
+	}
+
+	return b.AddInt64(int64(len(pubKeys))).AddOp(OpCheckMultiSig).Script()
+}
+
+/*
+ExtractMultiSig повертає кількість потрібних підписів і публічні ключі скрипта MultiSigScript.
+*/
+func ExtractMultiSig(script []byte) (int, [][]byte, error) {
+	instructions, err := parse(script)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	notMultiSig := fmt.Errorf("script %s is not multisig", Disassemble(script))
+	if len(instructions) < 4 || instructions[len(instructions)-1].opcode != OpCheckMultiSig {
+		return 0, nil, notMultiSig
+	}
+	for _, in := range instructions[:len(instructions)-1] {
+		if !in.isPush() {
+			return 0, nil, notMultiSig
+		}
+	}
+
+	m, err := decodeNum(pushValue(instructions[0]), maxNumSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := decodeNum(pushValue(instructions[len(instructions)-2]), maxNumSize)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var pubKeys [][]byte
+	for _, in := range instructions[1 : len(instructions)-2] {
+		pubKeys = append(pubKeys, pushValue(in))
+	}
+	if int(n) != len(pubKeys) || m < 1 || m > n {
+		return 0, nil, notMultiSig
+	}
+
+	return int(m), pubKeys, nil
+}
+
+/*
+SecretSize - розмір секрету HTLC, хеш SHA256 якого записано в скрипті HTLCScript.
+*/
+const SecretSize = sha256.Size
+
+/*
+HTLC - умови hash time-locked contract:
+- SecretHash - хеш SHA256 секрету, яким отримувач може забрати вихід.
+- RecipientHash - хеш публічного ключа отримувача.
+- RefundHash - хеш публічного ключа відправника, який може повернути вихід собі після LockTime.
+- LockTime - висота блоку або unix час ( див. OpCheckLockTimeVerify ).
+*/
+type HTLC struct {
+	SecretHash    []byte
+	RecipientHash []byte
+	RefundHash    []byte
+	LockTime      int64
+}
+
+/*
+HTLCScript повертає скрипт hash time-locked contract:
+
+	OP_IF
+		OP_SIZE <SecretSize> OP_EQUALVERIFY OP_SHA256 <secretHash> OP_EQUALVERIFY OP_DUP OP_HASH160 <recipientHash>
+	OP_ELSE
+		<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_HASH160 <refundHash>
+	OP_ENDIF
+	OP_EQUALVERIFY OP_CHECKSIG
+
+Перша гілка відкривається секретом і підписом отримувача ( RedeemHTLCScript ),
+друга - підписом відправника після lockTime ( RefundHTLCScript ).
+*/
+func HTLCScript(htlc HTLC) ([]byte, error) {
+	if len(htlc.SecretHash) != sha256.Size {
+		return nil, fmt.Errorf("secret hash must be %d bytes, got %d", sha256.Size, len(htlc.SecretHash))
+	}
+	if len(htlc.RecipientHash) != PubKeyHashSize || len(htlc.RefundHash) != PubKeyHashSize {
+		return nil, fmt.Errorf("public key hashes must be %d bytes", PubKeyHashSize)
+	}
+	if htlc.LockTime <= 0 || htlc.LockTime > 0xffffffff {
+		return nil, fmt.Errorf("lock time %d is out of range", htlc.LockTime)
+	}
+
+	return NewBuilder().
+		AddOp(OpIf).
+		AddOp(OpSize).
+		AddInt64(SecretSize).
+		AddOp(OpEqualVerify).
+		AddOp(OpSha256).
+		AddData(htlc.SecretHash).
+		AddOp(OpEqualVerify).
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(htlc.RecipientHash).
+		AddOp(OpElse).
+		AddInt64(htlc.LockTime).
+		AddOp(OpCheckLockTimeVerify).
+		AddOp(OpDrop).
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(htlc.RefundHash).
+		AddOp(OpEndIf).
+		AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+/*
+ExtractHTLC повертає умови скрипта HTLCScript.
+*/
+func ExtractHTLC(script []byte) (*HTLC, error) {
+	notHTLC := fmt.Errorf("script %s is not HTLC", Disassemble(script))
+
+	instructions, err := parse(script)
+	if err != nil {
+		return nil, err
+	}
+	if len(instructions) != 20 || !instructions[11].isPush() {
+		return nil, notHTLC
+	}
+
+	lockTime, err := decodeNum(pushValue(instructions[11]), maxLockTimeSize)
+	if err != nil {
+		return nil, notHTLC
+	}
+	htlc := &HTLC{
+		SecretHash:    instructions[5].data,
+		RecipientHash: instructions[9].data,
+		RefundHash:    instructions[16].data,
+		LockTime:      lockTime,
+	}
+
+	// скрипт з тими ж умовами має збігатися байт у байт
+	expected, err := HTLCScript(*htlc)
+	if err != nil || !bytes.Equal(script, expected) {
+		return nil, notHTLC
+	}
+
+	return htlc, nil
+}
+
+/*
+RedeemHTLCScript повертає скрипт розблокування першої гілки HTLCScript через P2SH:
+<sig> <pubKey> <secret> OP_1 <contract>.
+*/
+func RedeemHTLCScript(sig []byte, pubKey []byte, secret []byte, contract []byte) []byte {
+	script, err := NewBuilder().AddData(sig).AddData(pubKey).AddData(secret).AddOp(Op1).AddData(contract).Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+/*
+RefundHTLCScript повертає скрипт розблокування другої гілки HTLCScript через P2SH:
+<sig> <pubKey> OP_0 <contract>.
+*/
+func RefundHTLCScript(sig []byte, pubKey []byte, contract []byte) []byte {
+	script, err := NewBuilder().AddData(sig).AddData(pubKey).AddOp(Op0).AddData(contract).Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+/*
+ExtractHTLCSecret повертає секрет зі скрипта розблокування RedeemHTLCScript
+або nil, якщо скрипт має інший вигляд, наприклад повертає вихід відправнику.
+*/
+func ExtractHTLCSecret(unlockingScript []byte) []byte {
+	data, err := PushedData(unlockingScript)
+	if err != nil || len(data) != 5 || !asBool(data[3]) {
+		return nil
+	}
+	if _, err := ExtractHTLC(data[4]); err != nil {
+		return nil
+	}
+
+	return data[2]
+}
@@ -0,0 +1,116 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"reflect"
+	"testing"
+)
+
+func TestExtractStandardScripts(t *testing.T) {
+	key := testKey(1)
+	hash := Hash160(key)
+
+	if got := ExtractPubKeyHash(PayToPubKeyHash(hash)); !bytes.Equal(got, hash) {
+		t.Errorf("ExtractPubKeyHash = %x, want %x", got, hash)
+	}
+	if got := ExtractScriptHash(PayToScriptHash(hash)); !bytes.Equal(got, hash) {
+		t.Errorf("ExtractScriptHash = %x, want %x", got, hash)
+	}
+	if ExtractPubKeyHash(PayToScriptHash(hash)) != nil || ExtractScriptHash(PayToPubKeyHash(hash)) != nil {
+		t.Error("script of one type is recognized as another")
+	}
+}
+
+func testKeys(n int) [][]byte {
+	var pubKeys [][]byte
+	for i := 1; i <= n; i++ {
+		pubKeys = append(pubKeys, testKey(byte(i)))
+	}
+
+	return pubKeys
+}
+
+func TestExtractMultiSig(t *testing.T) {
+	tests := []struct {
+		m       int
+		pubKeys [][]byte
+	}{
+		{1, [][]byte{testKey(1)}},
+		{2, testKeys(3)},
+		{7, testKeys(7)},
+		{17, testKeys(MaxPubKeysPerMultiSig)},
+	}
+
+	for _, test := range tests {
+		script := mustScript(MultiSigScript(test.m, test.pubKeys))
+
+		m, pubKeys, err := ExtractMultiSig(script)
+		if err != nil {
+			t.Errorf("%d-of-%d: %v", test.m, len(test.pubKeys), err)
+			continue
+		}
+		if m != test.m || !reflect.DeepEqual(pubKeys, test.pubKeys) {
+			t.Errorf("%d-of-%d: got %d-of-%d", test.m, len(test.pubKeys), m, len(pubKeys))
+		}
+	}
+
+	invalid := [][]byte{
+		PayToPubKeyHash(Hash160(testKey(1))),
+		mustScript(NewBuilder().AddInt64(2).AddData(testKey(1)).AddInt64(1).AddOp(OpCheckMultiSig).Script()),
+		mustScript(NewBuilder().AddInt64(1).AddData(testKey(1)).AddInt64(2).AddOp(OpCheckMultiSig).Script()),
+		mustScript(NewBuilder().AddInt64(1).AddData(testKey(1)).AddOp(OpDup).AddInt64(1).AddOp(OpCheckMultiSig).Script()),
+	}
+	for _, script := range invalid {
+		if _, _, err := ExtractMultiSig(script); err == nil {
+			t.Errorf("script %s is recognized as multisig", Disassemble(script))
+		}
+	}
+}
+
+func TestExtractHTLC(t *testing.T) {
+	secret := bytes.Repeat([]byte{7}, SecretSize)
+	secretHash := sha256.Sum256(secret)
+
+	tests := []HTLC{
+		{secretHash[:], Hash160(testKey(1)), Hash160(testKey(2)), 1},
+		{secretHash[:], Hash160(testKey(1)), Hash160(testKey(2)), 500000},
+		{secretHash[:], Hash160(testKey(2)), Hash160(testKey(1)), 0xffffffff},
+	}
+
+	for _, htlc := range tests {
+		contract := mustScript(HTLCScript(htlc))
+
+		got, err := ExtractHTLC(contract)
+		if err != nil {
+			t.Errorf("lock time %d: %v", htlc.LockTime, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, htlc) {
+			t.Errorf("lock time %d: got %+v, want %+v", htlc.LockTime, *got, htlc)
+		}
+
+		redeem := RedeemHTLCScript(testSig(testKey(1)), testKey(1), secret, contract)
+		if got := ExtractHTLCSecret(redeem); !bytes.Equal(got, secret) {
+			t.Errorf("lock time %d: ExtractHTLCSecret = %x, want %x", htlc.LockTime, got, secret)
+		}
+		refund := RefundHTLCScript(testSig(testKey(2)), testKey(2), contract)
+		if got := ExtractHTLCSecret(refund); got != nil {
+			t.Errorf("lock time %d: refund reveals secret %x", htlc.LockTime, got)
+		}
+
+		// будь-яка зміна скрипта робить його іншим контрактом
+		tampered := append([]byte{}, contract...)
+		tampered[len(tampered)-1] = OpCheckSigVerify
+		if _, err := ExtractHTLC(tampered); err == nil {
+			t.Errorf("lock time %d: tampered contract is recognized", htlc.LockTime)
+		}
+	}
+
+	if _, err := HTLCScript(HTLC{SecretHash: secretHash[:16], RecipientHash: Hash160(testKey(1)), RefundHash: Hash160(testKey(2)), LockTime: 1}); err == nil {
+		t.Error("HTLC with short secret hash is created")
+	}
+	if _, err := HTLCScript(HTLC{SecretHash: secretHash[:], RecipientHash: Hash160(testKey(1)), RefundHash: Hash160(testKey(2)), LockTime: 0}); err == nil {
+		t.Error("HTLC without lock time is created")
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"blockchain1/chaincfg"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+Повідомлення мережі передаються в конверті з заголовком фіксованої довжини:
+- magic ( 4 байти ) - ідентифікатор мережі ( chaincfg.Params.Net ), вузли різних мереж не приймають повідомлень одна одної.
+- command ( commandLength байтів ) - назва команди, доповнена нульовими байтами.
+- length ( 4 байти, little-endian ) - довжина даних повідомлення.
+- checksum ( 4 байти ) - перші 4 байти подвійного sha256 від даних повідомлення.
+За заголовком йдуть дані повідомлення, тому в одному з'єднанні можна передати кілька повідомлень.
+
+messageHeaderLength - довжина заголовка повідомлення.
+maxMessageSize - максимальна довжина даних одного повідомлення.
+*/
+const (
+	messageHeaderLength = 4 + commandLength + 4 + 4
+	maxMessageSize      = 32 * 1024 * 1024
+)
+
+var (
+	ErrBadMagic        = errors.New("message belongs to another network")
+	ErrBadCommand      = errors.New("message command is malformed")
+	ErrMessageTooLarge = errors.New("message is too large")
+	ErrBadChecksum     = errors.New("message checksum doesn't match payload")
+)
+
+/*
+writeMessage записує повідомлення з командою command і даними payload у конверті.
+*/
+func writeMessage(w io.Writer, command string, payload []byte) error {
+	if len(command) == 0 || len(command) > commandLength {
+		return fmt.Errorf("%w: %q", ErrBadCommand, command)
+	}
+	if len(payload) > maxMessageSize {
+		return fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, len(payload))
+	}
+
+	header := make([]byte, messageHeaderLength)
+	binary.LittleEndian.PutUint32(header[0:4], chaincfg.ActiveNetParams.Net)
+	copy(header[4:4+commandLength], commandToBytes(command))
+	binary.LittleEndian.PutUint32(header[4+commandLength:8+commandLength], uint32(len(payload)))
+	copy(header[8+commandLength:], checksum(payload))
+
+	_, err := w.Write(append(header, payload...))
+
+	return err
+}
+
+/*
+readMessage читає одне повідомлення і повертає його команду та дані.
+Якщо з'єднання закрито до початку повідомлення, повертається io.EOF,
+якщо посередині повідомлення - io.ErrUnexpectedEOF.
+*/
+func readMessage(r io.Reader) (string, []byte, error) {
+	header := make([]byte, messageHeaderLength)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return "", nil, err
+	}
+
+	magic := binary.LittleEndian.Uint32(header[0:4])
+	if magic != chaincfg.ActiveNetParams.Net {
+		return "", nil, fmt.Errorf("%w: magic %08x", ErrBadMagic, magic)
+	}
+
+	commandBytes := header[4 : 4+commandLength]
+	command := bytesToCommand(commandBytes)
+	if len(command) == 0 || !bytes.Equal(commandToBytes(command), commandBytes) {
+		return "", nil, fmt.Errorf("%w: %x", ErrBadCommand, commandBytes)
+	}
+
+	length := binary.LittleEndian.Uint32(header[4+commandLength : 8+commandLength])
+	if length > maxMessageSize {
+		return "", nil, fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, length)
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !bytes.Equal(checksum(payload), header[8+commandLength:]) {
+		return "", nil, fmt.Errorf("%w: command %s", ErrBadChecksum, command)
+	}
+
+	return command, payload, nil
+}
+
+/*
+checksum повертає контрольну суму даних повідомлення.
+*/
+func checksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+
+	return second[:4]
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"blockchain1/bloks"
+	"blockchain1/transaction"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+)
+
+/*
+minBlockTransactions - скільки транзакцій має накопичитися в пулі, щоб майнер почав добувати блок.
+Після цього майнер добуває блоки, поки пул не спорожніє.
+*/
+const minBlockTransactions = 2
+
+/*
+updateMiner повідомляє майнеру, що вершина ланцюга або пул транзакцій змінилися:
+поточна робота покидається, а майнер створює новий шаблон блоку.
+Викликається під n.mu.
+*/
+func (n *Node) updateMiner() {
+	if n.cancelMining != nil {
+		n.cancelMining()
+		n.cancelMining = nil
+	}
+
+	select {
+	case n.minerUpdate <- struct{}{}:
+	default:
+	}
+}
+
+/*
+runMiner добуває блоки у фоні, поки вузол не зупинено.
+Пошук nonce виконується без n.mu, тому вузол тим часом обробляє повідомлення,
+а щойно приходить новий блок чи транзакція, updateMiner скасовує пошук.
+*/
+func (n *Node) runMiner() {
+	defer n.wg.Done()
+
+	active := false
+
+	for {
+		select {
+		case <-n.quit:
+			return
+		case <-n.minerUpdate:
+		}
+
+		n.mu.Lock()
+		count := n.mempool.Count()
+		if count == 0 || (!active && count < minBlockTransactions) {
+			active = false
+			n.mu.Unlock()
+			continue
+		}
+		active = true
+
+		template := n.newBlockTemplate(n.MiningAddress)
+		if template == nil || len(template.Transactions) == 1 {
+			fmt.Println("All transactions are invalid! Waiting for new ones...")
+			n.mu.Unlock()
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		n.cancelMining = cancel
+		n.mu.Unlock()
+
+		stats, err := n.mineBlock(ctx, template)
+		cancel()
+
+		n.mu.Lock()
+		n.hashRate = stats.HashRate()
+		n.mu.Unlock()
+
+		if err != nil {
+			fmt.Println("Block template is stale, mining is restarted")
+			continue
+		}
+
+		n.submitBlock(template)
+	}
+}
+
+/*
+mineBlock шукає nonce для шаблону, поки пошук не скасовано через ctx або не зупинено вузол.
+*/
+func (n *Node) mineBlock(ctx context.Context, template *bloks.Block) (bloks.MiningStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-n.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return template.Mine(ctx)
+}
+
+/*
+newBlockTemplate обирає транзакції з пулу і створює з них шаблон блоку поверх поточної вершини,
+винагорода за який надходить на адресу address. Якщо в пулі немає транзакцій, які можна включити в блок,
+шаблон містить лише coinbase транзакцію. Повертає nil, якщо шаблон створити не вдалося. Викликається під n.mu.
+*/
+func (n *Node) newBlockTemplate(address string) *bloks.Block {
+	var txs []*transaction.Transaction
+	selected := make(map[string]bool)
+	spent := make(map[transaction.OutPoint]bool)
+	fees := 0
+	descs := n.mempool.TxDescs()
+
+	/*
+		транзакції з більшою комісією на байт потрапляють у блок першими,
+		але транзакція, яка витрачає вихід іншої транзакції пулу, може йти лише після неї,
+		тому повторюємо прохід, поки в блок додаються нові транзакції
+	*/
+	for added := true; added; {
+		added = false
+
+	SelectTransactions:
+		for _, desc := range descs {
+			txID := hex.EncodeToString(desc.Tx.ID)
+			if selected[txID] {
+				continue
+			}
+
+			hasPoolParents := false
+			for _, vin := range desc.Tx.VIn {
+				if n.mempool.Has(vin.TxId) {
+					if !selected[vin.OutPoint().TxId] {
+						continue SelectTransactions
+					}
+					hasPoolParents = true
+				}
+			}
+			// транзакції без батьків у пулі ще раз перевіряємо відносно UTXO set
+			if !hasPoolParents && !n.bc.VerifyTransaction(desc.Tx) {
+				continue
+			}
+			// блок не може містити дві транзакції, які витрачають один і той самий вихід
+			for _, vin := range desc.Tx.VIn {
+				if spent[vin.OutPoint()] {
+					continue SelectTransactions
+				}
+			}
+			for _, vin := range desc.Tx.VIn {
+				spent[vin.OutPoint()] = true
+			}
+
+			txs = append(txs, desc.Tx)
+			selected[txID] = true
+			fees += desc.Fee
+			added = true
+		}
+	}
+
+	// coinbase транзакція завжди перша в блоці та отримує комісії всіх транзакцій блоку
+	cbTx := transaction.NewCoinbaseTX(address, "", n.bc.GetBestHeight()+1, fees)
+	txs = append([]*transaction.Transaction{cbTx}, txs...)
+
+	template, err := n.bc.NewBlockTemplate(txs)
+	if err != nil {
+		fmt.Printf("Can't create block template: %s\n", err)
+		return nil
+	}
+
+	return template
+}
+
+/*
+submitBlock додає добутий блок до ланцюга і повідомляє про нього інші вузли.
+Якщо поки йшов пошук nonce вершина ланцюга змінилася, блок відкидається.
+*/
+func (n *Node) submitBlock(block *bloks.Block) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	select {
+	case <-n.quit:
+		return
+	default:
+	}
+
+	if !bytes.Equal(block.PrevBlockHash, n.bc.GetBestHash()) {
+		fmt.Println("Mined block is stale, it is dropped")
+		n.updateMiner()
+		return
+	}
+
+	err := n.connectMinedBlock(block)
+	if err != nil {
+		fmt.Printf("Mined block %x is rejected: %s\n", block.Hash, err)
+	}
+}
+
+/*
+connectMinedBlock додає добутий вузлом блок до ланцюга, оновлює пул транзакцій
+і повідомляє про блок інші вузли. Викликається під n.mu.
+*/
+func (n *Node) connectMinedBlock(block *bloks.Block) error {
+	reorg, err := n.bc.AddBlock(block)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("New block is mined!")
+
+	if reorg != nil {
+		n.updateMemoryPool(reorg)
+	}
+
+	for _, node := range n.knownNodes {
+		if node != n.address {
+			n.sendInv(node, "block", [][]byte{block.Hash})
+		}
+	}
+
+	return nil
+}
+
+/*
+generateBlocks одразу добуває count блоків поверх поточної вершини з транзакціями з пулу
+і винагородою на адресу address. Повертає хеші добутих блоків.
+Викликається під n.mu, тому поки блоки добуваються, вузол не обробляє повідомлення.
+*/
+func (n *Node) generateBlocks(count int, address string) ([][]byte, error) {
+	var hashes [][]byte
+
+	for i := 0; i < count; i++ {
+		template := n.newBlockTemplate(address)
+		if template == nil {
+			return hashes, fmt.Errorf("can't create block template")
+		}
+
+		_, err := n.mineBlock(context.Background(), template)
+		if err != nil {
+			return hashes, err
+		}
+
+		err = n.connectMinedBlock(template)
+		if err != nil {
+			return hashes, err
+		}
+		hashes = append(hashes, template.Hash)
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/chaincfg"
+	"blockchain1/mempool"
+	"blockchain1/transaction"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+DefaultMaxInboundPeers - максимальна кількість вхідних з'єднань з іншими вузлами за замовчуванням.
+DefaultMaxOutboundPeers - максимальна кількість з'єднань, які вузол встановлює сам, за замовчуванням.
+*/
+const (
+	DefaultMaxInboundPeers  = 117
+	DefaultMaxOutboundPeers = 8
+)
+
+/*
+rpcShutdownTimeout - скільки чекати завершення запитів до RPC сервера під час зупинки вузла.
+*/
+const rpcShutdownTimeout = 10 * time.Second
+
+/*
+Node - вузол мережі блокчейну.
+- ID - ідентифікатор вузла, з нього формуються адреса вузла та назва файлу бази даних.
+- MiningAddress - адреса для винагороди за блоки. Якщо порожня, вузол не добуває блоки,
+інакше майнер добуває їх у фоні ( див. miner.go ).
+- MaxInboundPeers, MaxOutboundPeers - ліміти з'єднань, їх можна змінити до виклику Start.
+- RPCAddress - адреса JSON-RPC сервера вузла. Якщо порожня, RPC сервер не запускається.
+- MemPoolMaxSize, MemPoolExpiry - ліміт розміру пулу транзакцій у байтах та час, через який
+транзакція видаляється з пулу, якщо її не включили в блок.
+
+Стан вузла ( відомі вузли, завантаження ланцюга, пул транзакцій, робота майнера ) змінюється лише під mu.
+Обробники повідомлень виконуються під mu по одному, тому всередині них стан доступний без додаткових блокувань.
+*/
+type Node struct {
+	ID               string
+	MiningAddress    string
+	MaxInboundPeers  int
+	MaxOutboundPeers int
+	RPCAddress       string
+	MemPoolMaxSize   int
+	MemPoolExpiry    time.Duration
+
+	address   string
+	bc        *blockchain.Blockchain
+	peers     *PeerManager
+	listener  net.Listener
+	rpcServer *http.Server
+
+	mu             sync.Mutex
+	knownNodes     []string
+	syncPeers      map[string]*syncPeer
+	downloadQueue  []*blockchain.IndexedHeader
+	blocksInFlight map[string]*blockRequest
+	receivedBlocks map[string]*receivedBlock
+	orphans        *orphanPool
+	mempool        *mempool.TxPool
+	minerUpdate    chan struct{}
+	cancelMining   context.CancelFunc
+	hashRate       float64
+
+	wg        sync.WaitGroup
+	quit      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+/*
+NewNode створює вузол з ідентифікатором nodeID. Вузол починає роботу після виклику Start.
+*/
+func NewNode(nodeID string, miningAddress string) *Node {
+	return &Node{
+		ID:               nodeID,
+		MiningAddress:    miningAddress,
+		MaxInboundPeers:  DefaultMaxInboundPeers,
+		MaxOutboundPeers: DefaultMaxOutboundPeers,
+		RPCAddress:       DefaultRPCAddress(nodeID),
+		MemPoolMaxSize:   mempool.DefaultMaxSize,
+		MemPoolExpiry:    mempool.DefaultExpiry,
+		// формуємо адресу вузла nodeID може мати наступні значення 3000, 3001, 3002 це для локального тестування,
+		// центральний вузол мережі слухає chaincfg.Params.DefaultPort
+		address:        fmt.Sprintf("127.0.0.1:%s", nodeID),
+		knownNodes:     append([]string{}, chaincfg.ActiveNetParams.SeedNodes...),
+		syncPeers:      make(map[string]*syncPeer),
+		blocksInFlight: make(map[string]*blockRequest),
+		receivedBlocks: make(map[string]*receivedBlock),
+		orphans:        newOrphanPool(),
+		minerUpdate:    make(chan struct{}, 1),
+		quit:           make(chan struct{}),
+	}
+}
+
+/*
+DefaultRPCAddress повертає адресу RPC сервера вузла nodeID за замовчуванням:
+до номера порту вузла дописується 1 спереду, наприклад 3000 -> 13000.
+*/
+func DefaultRPCAddress(nodeID string) string {
+	return fmt.Sprintf("127.0.0.1:1%s", nodeID)
+}
+
+/*
+Address повертає адресу, на якій вузол приймає з'єднання.
+*/
+func (n *Node) Address() string {
+	return n.address
+}
+
+/*
+Start відкриває базу даних вузла, починає приймати з'єднання
+та підключається до основного вузла мережі.
+*/
+func (n *Node) Start() error {
+	err := fmt.Errorf("node %s is already started", n.ID)
+
+	n.startOnce.Do(func() {
+		err = n.start()
+	})
+
+	return err
+}
+
+func (n *Node) start() error {
+	// ініціалізуємо новий екземпляр блокчейну з вказаним nodeID
+	n.bc = blockchain.NewBlockchain(n.ID)
+	n.mempool = mempool.New(n.bc)
+	n.mempool.MaxSize = n.MemPoolMaxSize
+	n.mempool.Expiry = n.MemPoolExpiry
+	// якщо вузол зупинили під час завантаження, заголовки вже перевірено і лишається завантажити блоки
+	n.downloadQueue = n.bc.PendingHeaders()
+
+	//ініціюємо прослуховування мережі на вказаній адресі protocol = "tcp"
+	ln, err := net.Listen(protocol, n.address)
+	if err != nil {
+		_ = n.bc.Db.Close()
+		return err
+	}
+	n.listener = ln
+
+	n.peers = NewPeerManager(n.MaxInboundPeers, n.MaxOutboundPeers, n.handleMessage, n.sendVersion, n.peerDisconnected)
+
+	if n.RPCAddress != "" {
+		rpcListener, err := net.Listen(protocol, n.RPCAddress)
+		if err != nil {
+			_ = ln.Close()
+			_ = n.bc.Db.Close()
+			n.listener = nil
+			return err
+		}
+
+		n.rpcServer = &http.Server{Handler: n}
+		go func() { _ = n.rpcServer.Serve(rpcListener) }()
+		fmt.Printf("JSON-RPC server is listening on %s\n", n.RPCAddress)
+	}
+
+	/*
+			 якщо поточний вузол не є першим відомим вузлом
+			 у нашій реалізації це перший з chaincfg.Params.SeedNodes, наприклад " 127.0.0.1:3000 "
+		     то встановлюємо з ним постійне з'єднання
+	*/
+	if len(n.knownNodes) > 0 && n.address != n.knownNodes[0] {
+		n.peers.ConnectPersistent(n.knownNodes[0])
+	}
+
+	n.wg.Add(1)
+	go n.acceptConnections()
+
+	n.wg.Add(1)
+	go n.runSyncMonitor()
+
+	if len(n.MiningAddress) > 0 {
+		n.wg.Add(1)
+		go n.runMiner()
+	}
+
+	return nil
+}
+
+/*
+Stop припиняє приймати з'єднання та RPC запити, закриває з'єднання з іншими вузлами,
+чекає завершення обробки поточних повідомлень і запитів та закриває базу даних.
+*/
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.quit)
+		if n.listener == nil {
+			return
+		}
+
+		_ = n.listener.Close()
+		if n.rpcServer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), rpcShutdownTimeout)
+			_ = n.rpcServer.Shutdown(ctx)
+			cancel()
+		}
+		n.peers.Stop()
+		n.wg.Wait()
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		err := n.bc.Db.Close()
+		if err != nil {
+			fmt.Printf("Can't close database of node %s: %s\n", n.ID, err)
+		}
+	})
+}
+
+/*
+Done повертає канал, який закривається після виклику Stop.
+*/
+func (n *Node) Done() <-chan struct{} {
+	return n.quit
+}
+
+/*
+KnownNodes повертає копію списку відомих вузлу адрес.
+*/
+func (n *Node) KnownNodes() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]string{}, n.knownNodes...)
+}
+
+/*
+MemPool повертає транзакції з пулу вузла, впорядковані від найбільшої комісії на байт до найменшої.
+*/
+func (n *Node) MemPool() []*transaction.Transaction {
+	var txs []*transaction.Transaction
+	for _, desc := range n.mempool.TxDescs() {
+		txs = append(txs, desc.Tx)
+	}
+
+	return txs
+}
+
+/*
+acceptConnections приймає з'єднання від інших вузлів, поки вузол не зупинено.
+*/
+func (n *Node) acceptConnections() {
+	defer n.wg.Done()
+
+	for {
+		conn, err := n.listener.Accept()
+		if err != nil {
+			select {
+			case <-n.quit:
+				return
+			default:
+			}
+			fmt.Printf("Can't accept connection: %s\n", err)
+			continue
+		}
+		n.peers.AcceptConnection(conn)
+	}
+}
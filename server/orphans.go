@@ -0,0 +1,208 @@
+package server
+
+import (
+	"blockchain1/bloks"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+/*
+maxOrphanBlocks - скільки блоків без попередника вузол тримає в пам'яті.
+maxOrphanBytes - найбільший сумарний розмір блоків пулу.
+maxOrphansPerPeer - скільки блоків пулу може надіслати один вузол, щоб він не витіснив блоки інших.
+orphanExpiry - через скільки часу блок видаляється з пулу, якщо його попередник так і не надійшов.
+*/
+const (
+	maxOrphanBlocks   = 100
+	maxOrphanBytes    = 2 * maxMessageSize
+	maxOrphansPerPeer = 10
+	orphanExpiry      = time.Hour
+)
+
+/*
+orphanBlock - блок, попередника якого ще немає в базі даних.
+- from - вузол, який надіслав блок.
+- size - розмір закодованого блоку.
+- expires - коли блок буде видалено з пулу.
+*/
+type orphanBlock struct {
+	block   *bloks.Block
+	from    string
+	size    int
+	expires time.Time
+}
+
+/*
+orphanPool - блоки, отримані раніше за своїх попередників. Блоки зберігаються за своїм хешем
+і групуються за хешем відсутнього попередника, щоб після прийняття попередника одразу знайти блоки,
+які на нього посилаються. Кількість блоків обмежена maxOrphanBlocks, їх розмір - maxOrphanBytes,
+а кількість блоків від одного вузла - maxOrphansPerPeer.
+*/
+type orphanPool struct {
+	orphans  map[string]*orphanBlock
+	byParent map[string][]*orphanBlock
+	byPeer   map[string]int
+	bytes    int
+}
+
+func newOrphanPool() *orphanPool {
+	return &orphanPool{
+		orphans:  make(map[string]*orphanBlock),
+		byParent: make(map[string][]*orphanBlock),
+		byPeer:   make(map[string]int),
+	}
+}
+
+func (op *orphanPool) has(hash []byte) bool {
+	return op.orphans[hex.EncodeToString(hash)] != nil
+}
+
+/*
+add додає блок до пулу. Спочатку видаляються прострочені блоки. Якщо вузол from вже надіслав
+maxOrphansPerPeer блоків, видаляється найстаріший з них, а якщо пул все одно заповнений -
+блоки, які мали бути видалені найраніше.
+*/
+func (op *orphanPool) add(block *bloks.Block, from string, now time.Time) {
+	if op.has(block.Hash) {
+		return
+	}
+
+	for _, orphan := range op.orphans {
+		if now.After(orphan.expires) {
+			op.remove(orphan)
+		}
+	}
+
+	if op.byPeer[from] >= maxOrphansPerPeer {
+		op.remove(op.oldest(from))
+	}
+
+	size := len(block.Serialize())
+	for len(op.orphans) > 0 && (len(op.orphans) >= maxOrphanBlocks || op.bytes+size > maxOrphanBytes) {
+		op.remove(op.oldest(""))
+	}
+
+	orphan := &orphanBlock{
+		block:   block,
+		from:    from,
+		size:    size,
+		expires: now.Add(orphanExpiry),
+	}
+	op.orphans[hex.EncodeToString(block.Hash)] = orphan
+	op.byPeer[from]++
+	op.bytes += size
+
+	parent := hex.EncodeToString(block.PrevBlockHash)
+	op.byParent[parent] = append(op.byParent[parent], orphan)
+}
+
+/*
+oldest повертає блок пулу, який мав бути видалений найраніше, серед блоків вузла from
+або, якщо from порожній, серед усіх блоків.
+*/
+func (op *orphanPool) oldest(from string) *orphanBlock {
+	var oldest *orphanBlock
+	for _, orphan := range op.orphans {
+		if from != "" && orphan.from != from {
+			continue
+		}
+		if oldest == nil || orphan.expires.Before(oldest.expires) {
+			oldest = orphan
+		}
+	}
+
+	return oldest
+}
+
+func (op *orphanPool) remove(orphan *orphanBlock) {
+	delete(op.orphans, hex.EncodeToString(orphan.block.Hash))
+
+	op.bytes -= orphan.size
+	op.byPeer[orphan.from]--
+	if op.byPeer[orphan.from] == 0 {
+		delete(op.byPeer, orphan.from)
+	}
+
+	parent := hex.EncodeToString(orphan.block.PrevBlockHash)
+	var siblings []*orphanBlock
+	for _, sibling := range op.byParent[parent] {
+		if sibling != orphan {
+			siblings = append(siblings, sibling)
+		}
+	}
+
+	if len(siblings) == 0 {
+		delete(op.byParent, parent)
+	} else {
+		op.byParent[parent] = siblings
+	}
+}
+
+/*
+takeChildren видаляє з пулу і повертає блоки, попередником яких є блок з хешем parent.
+*/
+func (op *orphanPool) takeChildren(parent []byte) []*orphanBlock {
+	children := op.byParent[hex.EncodeToString(parent)]
+	for _, child := range children {
+		op.remove(child)
+	}
+
+	return children
+}
+
+/*
+root повертає найстаршого предка блоку hash, який теж є в пулі: саме його попередника бракує вузлу.
+*/
+func (op *orphanPool) root(hash []byte) *orphanBlock {
+	orphan := op.orphans[hex.EncodeToString(hash)]
+	for orphan != nil {
+		parent := op.orphans[hex.EncodeToString(orphan.block.PrevBlockHash)]
+		if parent == nil {
+			break
+		}
+		orphan = parent
+	}
+
+	return orphan
+}
+
+/*
+addOrphan зберігає блок без попередника, отриманий від вузла from, і запитує в цього вузла
+заголовки відсутніх предків: після їх перевірки блоки предків будуть завантажені як звичайно,
+а блок з пулу буде приєднано, щойно буде прийнято його попередника ( див. connectOrphans ).
+*/
+func (n *Node) addOrphan(block *bloks.Block, from string) {
+	if n.orphans.has(block.Hash) {
+		return
+	}
+	n.orphans.add(block, from, time.Now())
+
+	root := n.orphans.root(block.Hash)
+	fmt.Printf("Block %x is an orphan, its ancestor %x is missing\n", block.Hash, root.block.PrevBlockHash)
+
+	if peer := n.syncPeers[from]; peer != nil {
+		if block.Height > peer.bestHeight {
+			peer.bestHeight = block.Height
+		}
+		n.requestHeaders(peer, nil)
+	}
+}
+
+/*
+connectOrphans приєднує блоки з пулу, які чекали на блок з хешем parent, а потім їх нащадків.
+*/
+func (n *Node) connectOrphans(parent []byte) {
+	parents := [][]byte{parent}
+
+	for len(parents) > 0 {
+		children := n.orphans.takeChildren(parents[0])
+		parents = parents[1:]
+
+		for _, orphan := range children {
+			if err := n.addBlock(orphan.block); err == nil {
+				parents = append(parents, orphan.block.Hash)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"blockchain1/bloks"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testOrphan(i int) *bloks.Block {
+	return &bloks.Block{
+		BlockHeader: bloks.BlockHeader{Version: bloks.BlockVersion, PrevBlockHash: []byte(fmt.Sprintf("parent %d", i))},
+		Hash:        []byte(fmt.Sprintf("orphan %d", i)),
+		Height:      i,
+	}
+}
+
+func TestOrphanPoolLimitsBlocksPerPeer(t *testing.T) {
+	op := newOrphanPool()
+	now := time.Now()
+
+	for i := 0; i < maxOrphansPerPeer+5; i++ {
+		op.add(testOrphan(i), "bad", now.Add(time.Duration(i)*time.Second))
+	}
+	op.add(testOrphan(1000), "good", now)
+
+	if op.byPeer["bad"] != maxOrphansPerPeer {
+		t.Fatalf("peer holds %d orphans, want %d", op.byPeer["bad"], maxOrphansPerPeer)
+	}
+	if op.has(testOrphan(0).Hash) || !op.has(testOrphan(maxOrphansPerPeer+4).Hash) {
+		t.Fatal("oldest orphans of the peer must be evicted first")
+	}
+	if !op.has(testOrphan(1000).Hash) {
+		t.Fatal("orphan of another peer is evicted")
+	}
+}
+
+func TestOrphanPoolTracksBytes(t *testing.T) {
+	op := newOrphanPool()
+	now := time.Now()
+
+	total := 0
+	for i := 0; i < 3; i++ {
+		block := testOrphan(i)
+		op.add(block, fmt.Sprintf("peer %d", i), now)
+		total += len(block.Serialize())
+	}
+	if op.bytes != total {
+		t.Fatalf("pool size is %d bytes, want %d", op.bytes, total)
+	}
+
+	for _, child := range op.takeChildren(testOrphan(1).PrevBlockHash) {
+		total -= child.size
+	}
+	if op.bytes != total || len(op.byPeer) != 2 {
+		t.Fatalf("pool size is %d bytes with %d peers after removal, want %d bytes and 2 peers", op.bytes, len(op.byPeer), total)
+	}
+
+	op.add(testOrphan(10), "late", now.Add(2*orphanExpiry))
+	if len(op.orphans) != 1 || op.bytes != len(testOrphan(10).Serialize()) {
+		t.Fatal("expired orphans must be removed")
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+sendQueueSize - скільки повідомлень може очікувати відправки до одного вузла.
+handshakeTimeout - за який час вузол має надіслати повідомлення version після встановлення з'єднання.
+writeTimeout - за який час вузол має прийняти одне повідомлення, інакше з'єднання розривається.
+*/
+const (
+	sendQueueSize    = 100
+	handshakeTimeout = 30 * time.Second
+	writeTimeout     = 30 * time.Second
+)
+
+type outMessage struct {
+	command string
+	payload []byte
+}
+
+/*
+Peer - постійне з'єднання з іншим вузлом мережі.
+Повідомлення читаються і відправляються окремими горутинами,
+а повідомлення для відправки спочатку потрапляють у чергу sendQueue.
+- inbound - з'єднання встановив інший вузол.
+- persistent - після розриву з'єднання з вузлом потрібно підключитися знову.
+- addr - адреса, на якій інший вузол приймає з'єднання. Для вхідних з'єднань
+вона стає відомою лише з повідомлення version.
+- versionReceived, verAckReceived - стан рукостискання version/verack.
+*/
+type Peer struct {
+	conn       net.Conn
+	inbound    bool
+	persistent bool
+
+	mu              sync.Mutex
+	addr            string
+	versionReceived bool
+	verAckReceived  bool
+
+	sendQueue chan outMessage
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPeer(conn net.Conn, addr string, inbound bool, persistent bool) *Peer {
+	return &Peer{
+		conn:       conn,
+		inbound:    inbound,
+		persistent: persistent,
+		addr:       addr,
+		sendQueue:  make(chan outMessage, sendQueueSize),
+		quit:       make(chan struct{}),
+	}
+}
+
+/*
+Addr повертає адресу, на якій вузол приймає з'єднання, або порожній рядок,
+якщо вона ще невідома.
+*/
+func (p *Peer) Addr() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.addr
+}
+
+/*
+Inbound повідомляє, чи з'єднання встановив інший вузол.
+*/
+func (p *Peer) Inbound() bool {
+	return p.inbound
+}
+
+/*
+HandshakeDone повідомляє, чи вузли вже обмінялися повідомленнями version і verack.
+*/
+func (p *Peer) HandshakeDone() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.versionReceived && p.verAckReceived
+}
+
+func (p *Peer) String() string {
+	direction := "outbound"
+	if p.inbound {
+		direction = "inbound"
+	}
+
+	addr := p.Addr()
+	if addr == "" {
+		addr = p.conn.RemoteAddr().String()
+	}
+
+	return fmt.Sprintf("%s (%s)", addr, direction)
+}
+
+/*
+QueueMessage ставить повідомлення в чергу на відправку і не чекає на місце в ній, бо викликається
+під блокуванням вузла. Якщо з'єднання вже закрито, повідомлення відкидається, а якщо черга заповнена,
+вузол не встигає приймати повідомлення і з'єднання з ним розривається.
+*/
+func (p *Peer) QueueMessage(command string, payload []byte) {
+	if p.closed() {
+		return
+	}
+
+	select {
+	case p.sendQueue <- outMessage{command: command, payload: payload}:
+	default:
+		fmt.Printf("Disconnecting %s: send queue is full\n", p)
+		p.Disconnect()
+	}
+}
+
+/*
+Disconnect закриває з'єднання з вузлом. Повторні виклики нічого не роблять.
+*/
+func (p *Peer) Disconnect() {
+	p.closeOnce.Do(func() {
+		close(p.quit)
+		_ = p.conn.Close()
+	})
+}
+
+/*
+run запускає горутини читання і запису та чекає, поки з'єднання не буде закрито.
+Кожне прочитане повідомлення передається в handler, помилка обробки розриває з'єднання.
+*/
+func (p *Peer) run(handler func(p *Peer, command string, payload []byte) error) {
+	go p.writeLoop()
+	p.readLoop(handler)
+}
+
+func (p *Peer) readLoop(handler func(p *Peer, command string, payload []byte) error) {
+	defer p.Disconnect()
+
+	// вузол, який не представився вчасно, займає слот даремно
+	_ = p.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	waitingVersion := true
+
+	for {
+		command, payload, err := readMessage(p.conn)
+		if err != nil {
+			if err != io.EOF && !p.closed() {
+				fmt.Printf("Disconnecting %s: %s\n", p, err)
+			}
+			return
+		}
+
+		err = handler(p, command, payload)
+		if err != nil {
+			fmt.Printf("Disconnecting %s: can't handle %s command: %s\n", p, command, err)
+			return
+		}
+
+		if waitingVersion && p.hasVersion() {
+			_ = p.conn.SetReadDeadline(time.Time{})
+			waitingVersion = false
+		}
+	}
+}
+
+func (p *Peer) writeLoop() {
+	defer p.Disconnect()
+
+	for {
+		select {
+		case msg := <-p.sendQueue:
+			_ = p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err := writeMessage(p.conn, msg.command, msg.payload)
+			if err != nil {
+				if !p.closed() {
+					fmt.Printf("Disconnecting %s: can't send %s command: %s\n", p, msg.command, err)
+				}
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *Peer) hasVersion() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.versionReceived
+}
+
+func (p *Peer) closed() bool {
+	select {
+	case <-p.quit:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+dialTimeout - скільки чекати на встановлення з'єднання з вузлом.
+minReconnectDelay, maxReconnectDelay - межі затримки перед повторним підключенням
+до постійного вузла, затримка подвоюється після кожної невдалої спроби.
+*/
+const (
+	dialTimeout       = 5 * time.Second
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+/*
+PeerManager керує з'єднаннями з іншими вузлами: приймає вхідні з'єднання,
+встановлює вихідні, обмежує їх кількість та підключається знову до постійних вузлів.
+- handler - обробник повідомлень, отриманих від вузлів.
+- onConnect - викликається для кожного нового вихідного з'єднання, щоб почати рукостискання.
+- onDisconnect - викликається після закриття з'єднання.
+*/
+type PeerManager struct {
+	maxInbound   int
+	maxOutbound  int
+	handler      func(p *Peer, command string, payload []byte) error
+	onConnect    func(p *Peer)
+	onDisconnect func(p *Peer)
+
+	mu      sync.Mutex
+	peers   map[*Peer]bool
+	dialing map[string][]outMessage
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewPeerManager(maxInbound int, maxOutbound int,
+	handler func(p *Peer, command string, payload []byte) error, onConnect func(p *Peer), onDisconnect func(p *Peer)) *PeerManager {
+	return &PeerManager{
+		maxInbound:   maxInbound,
+		maxOutbound:  maxOutbound,
+		handler:      handler,
+		onConnect:    onConnect,
+		onDisconnect: onDisconnect,
+		peers:        make(map[*Peer]bool),
+		dialing:      make(map[string][]outMessage),
+		quit:         make(chan struct{}),
+	}
+}
+
+/*
+AcceptConnection реєструє вхідне з'єднання або закриває його,
+якщо досягнуто ліміту вхідних з'єднань.
+*/
+func (m *PeerManager) AcceptConnection(conn net.Conn) {
+	p := newPeer(conn, "", true, false)
+
+	if !m.addPeer(p) {
+		fmt.Printf("Rejecting connection from %s: too many inbound peers or node is stopping\n", conn.RemoteAddr())
+		_ = conn.Close()
+		return
+	}
+
+	go m.runPeer(p)
+}
+
+/*
+ConnectPersistent підключається до вузла за адресою addr і підтримує з'єднання:
+якщо воно розривається або вузол недоступний, спроби повторюються з наростаючою затримкою.
+*/
+func (m *PeerManager) ConnectPersistent(addr string) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		delay := minReconnectDelay
+
+		for {
+			p, err := m.connect(addr, true)
+			if err == nil {
+				delay = minReconnectDelay
+				m.runPeer(p)
+			} else {
+				fmt.Printf("Can't connect to %s: %s. Retrying in %s\n", addr, err, delay)
+			}
+
+			select {
+			case <-m.quit:
+				return
+			case <-time.After(delay):
+			}
+
+			if err != nil {
+				delay *= 2
+				if delay > maxReconnectDelay {
+					delay = maxReconnectDelay
+				}
+			}
+		}
+	}()
+}
+
+/*
+Send відправляє повідомлення вузлу з адресою addr і не чекає на мережу, бо викликається під блокуванням вузла.
+Якщо з'єднання з ним ще немає, нове вихідне з'єднання встановлюється в окремій горутині,
+а повідомлення чекають на нього в черзі. Якщо з'єднатися не вдалося, викликається onFail.
+*/
+func (m *PeerManager) Send(addr string, command string, payload []byte, onFail func(err error)) {
+	msg := outMessage{command: command, payload: payload}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// поки триває підключення, повідомлення стають у чергу за попередніми
+	if queued, ok := m.dialing[addr]; ok {
+		if len(queued) < sendQueueSize {
+			m.dialing[addr] = append(queued, msg)
+		}
+		return
+	}
+	if p := m.findPeerLocked(addr); p != nil {
+		p.QueueMessage(msg.command, msg.payload)
+		return
+	}
+	if m.stopped() {
+		return
+	}
+
+	m.dialing[addr] = []outMessage{msg}
+	m.wg.Add(1)
+	go m.dial(addr, onFail)
+}
+
+/*
+Peers повертає всі поточні з'єднання.
+*/
+func (m *PeerManager) Peers() []*Peer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var peers []*Peer
+	for p := range m.peers {
+		peers = append(peers, p)
+	}
+
+	return peers
+}
+
+/*
+Stop закриває всі з'єднання, припиняє повторні підключення
+та чекає завершення обробки повідомлень, які вже отримано.
+*/
+func (m *PeerManager) Stop() {
+	m.mu.Lock()
+	select {
+	case <-m.quit:
+	default:
+		close(m.quit)
+	}
+	peers := make([]*Peer, 0, len(m.peers))
+	for p := range m.peers {
+		peers = append(peers, p)
+	}
+	m.mu.Unlock()
+
+	for _, p := range peers {
+		p.Disconnect()
+	}
+
+	m.wg.Wait()
+}
+
+/*
+connect встановлює вихідне з'єднання і ставить у чергу повідомлення version,
+з якого починається рукостискання.
+*/
+func (m *PeerManager) connect(addr string, persistent bool) (*Peer, error) {
+	if m.stopped() {
+		return nil, fmt.Errorf("peer manager is stopped")
+	}
+	if m.countPeers(false) >= m.maxOutbound {
+		return nil, fmt.Errorf("too many outbound peers")
+	}
+
+	conn, err := net.DialTimeout(protocol, addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPeer(conn, addr, false, persistent)
+	if !m.addPeer(p) {
+		_ = conn.Close()
+		return nil, fmt.Errorf("too many outbound peers or peer manager is stopped")
+	}
+
+	m.onConnect(p)
+
+	return p, nil
+}
+
+/*
+dial встановлює вихідне з'єднання для Send і передає в нього повідомлення, які чекали на підключення.
+*/
+func (m *PeerManager) dial(addr string, onFail func(err error)) {
+	defer m.wg.Done()
+
+	p, err := m.connect(addr, false)
+
+	m.mu.Lock()
+	queued := m.dialing[addr]
+	delete(m.dialing, addr)
+	if err == nil {
+		for _, msg := range queued {
+			p.QueueMessage(msg.command, msg.payload)
+		}
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		onFail(err)
+		return
+	}
+
+	m.runPeer(p)
+}
+
+/*
+runPeer обслуговує з'єднання, поки воно не закриється, після чого видаляє його зі списку і повідомляє onDisconnect.
+*/
+func (m *PeerManager) runPeer(p *Peer) {
+	defer m.wg.Done()
+
+	p.run(m.handler)
+
+	m.mu.Lock()
+	delete(m.peers, p)
+	m.mu.Unlock()
+
+	fmt.Printf("Peer %s is disconnected\n", p)
+	m.onDisconnect(p)
+}
+
+/*
+addPeer реєструє з'єднання, якщо не досягнуто ліміту і менеджер не зупинено.
+Кожне зареєстроване з'єднання потрібно обслужити через runPeer.
+*/
+func (m *PeerManager) addPeer(p *Peer) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped() {
+		return false
+	}
+
+	limit := m.maxOutbound
+	if p.inbound {
+		limit = m.maxInbound
+	}
+	if m.countPeersLocked(p.inbound) >= limit {
+		return false
+	}
+
+	m.peers[p] = true
+	m.wg.Add(1)
+
+	return true
+}
+
+func (m *PeerManager) findPeer(addr string) *Peer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.findPeerLocked(addr)
+}
+
+func (m *PeerManager) findPeerLocked(addr string) *Peer {
+	for p := range m.peers {
+		if p.Addr() == addr && !p.closed() {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func (m *PeerManager) countPeers(inbound bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.countPeersLocked(inbound)
+}
+
+func (m *PeerManager) countPeersLocked(inbound bool) int {
+	count := 0
+	for p := range m.peers {
+		if p.inbound == inbound {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (m *PeerManager) stopped() bool {
+	select {
+	case <-m.quit:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQueueMessageDisconnectsSlowPeer(t *testing.T) {
+	conn, remote := net.Pipe()
+	defer func() { _ = remote.Close() }()
+
+	// writeLoop не запущено, тож черга лише заповнюється
+	p := newPeer(conn, "slow", true, false)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < sendQueueSize+1; i++ {
+			p.QueueMessage("inv", nil)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("QueueMessage blocks on a full queue")
+	}
+	if !p.closed() {
+		t.Fatal("peer with a full queue is not disconnected")
+	}
+}
+
+func TestSendDoesNotWaitForDial(t *testing.T) {
+	listener, err := net.Listen(protocol, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	m := NewPeerManager(1, 1, nil, func(p *Peer) {}, func(p *Peer) {})
+	defer m.Stop()
+
+	failed := make(chan error, 1)
+	start := time.Now()
+	m.Send(addr, "inv", nil, func(err error) { failed <- err })
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatal("Send waits for the connection")
+	}
+
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Fatal("onFail got nil error")
+		}
+	case <-time.After(dialTimeout + time.Second):
+		t.Fatal("onFail is not called for an unavailable node")
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+/*
+rpcTimeout - скільки чекати на відповідь вузла. Добування блоку може зайняти час,
+тому значення більше за звичайний мережевий тайм-аут.
+*/
+const rpcTimeout = 5 * time.Minute
+
+/*
+RPCClient викликає методи JSON-RPC запущеного вузла за адресою Address.
+*/
+type RPCClient struct {
+	Address string
+
+	httpClient *http.Client
+	nextID     uint64
+}
+
+func NewRPCClient(address string) *RPCClient {
+	return &RPCClient{
+		Address:    address,
+		httpClient: &http.Client{Timeout: rpcTimeout},
+	}
+}
+
+/*
+Call викликає метод method з позиційними параметрами params і записує результат у result.
+Помилка, яку повернув вузол, має тип *RPCError.
+*/
+func (c *RPCClient) Call(method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      atomic.AddUint64(&c.nextID, 1),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Post("http://"+c.Address, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc server %s returned %s", c.Address, resp.Status)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return err
+	}
+
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
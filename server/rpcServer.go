@@ -0,0 +1,453 @@
+package server
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/bloks"
+	"blockchain1/chaincfg"
+	"blockchain1/transaction"
+	wal "blockchain1/wallet"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+/*
+maxRPCRequestSize - максимальна довжина тіла запиту до RPC сервера.
+*/
+const maxRPCRequestSize = 4 * 1024 * 1024
+
+/*
+Коди помилок JSON-RPC. Перші п'ять визначені специфікацією JSON-RPC 2.0,
+решта - як у Біткоїні: RPCInvalidAddress - невідома адреса, блок або транзакція,
+RPCDeserializationError - транзакцію не вдалося розібрати, RPCVerifyRejected - транзакція не пройшла перевірку.
+*/
+const (
+	RPCParseError           = -32700
+	RPCInvalidRequest       = -32600
+	RPCMethodNotFound       = -32601
+	RPCInvalidParams        = -32602
+	RPCInternalError        = -32603
+	RPCInvalidAddress       = -5
+	RPCDeserializationError = -22
+	RPCVerifyRejected       = -26
+)
+
+/*
+RPCError - помилка виконання RPC методу.
+*/
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+/*
+BlockInfo - опис блоку, який повертає метод getblock.
+*/
+type BlockInfo struct {
+	Hash          string   `json:"hash"`
+	Height        int      `json:"height"`
+	Version       int32    `json:"version"`
+	PrevBlockHash string   `json:"previousblockhash"`
+	MerkleRoot    string   `json:"merkleroot"`
+	Timestamp     int64    `json:"time"`
+	Bits          string   `json:"bits"`
+	Nonce         int      `json:"nonce"`
+	Transactions  []string `json:"tx"`
+}
+
+/*
+UnspentInfo - непотрачений вихід, який повертає метод listunspent.
+*/
+type UnspentInfo struct {
+	TxId         string `json:"txid"`
+	VOut         int    `json:"vout"`
+	Value        int    `json:"amount"`
+	ScriptPubKey string `json:"scriptpubkey"`
+}
+
+/*
+MemPoolInfo - стан пулу транзакцій, який повертає метод getmempoolinfo.
+*/
+type MemPoolInfo struct {
+	Size  int `json:"size"`
+	Bytes int `json:"bytes"`
+}
+
+/*
+MiningInfo - стан майнера, який повертає метод getmininginfo.
+- HashRate - швидкість пошуку nonce під час добування останнього шаблону блоку, хешів за секунду.
+- Chain - назва мережі, з якою працює вузол.
+*/
+type MiningInfo struct {
+	Blocks        int     `json:"blocks"`
+	Bits          string  `json:"bits"`
+	PooledTx      int     `json:"pooledtx"`
+	Mining        bool    `json:"generate"`
+	HashRate      float64 `json:"hashespersec"`
+	MiningAddress string  `json:"miningaddress,omitempty"`
+	Chain         string  `json:"chain"`
+}
+
+/*
+rpcHandler - обробник RPC методу. Отримує позиційні параметри запиту
+і викликається під n.mu.
+*/
+type rpcHandler func(n *Node, params []json.RawMessage) (interface{}, error)
+
+var rpcHandlers map[string]rpcHandler
+
+func init() {
+	rpcHandlers = map[string]rpcHandler{
+		"getblockcount":      rpcGetBlockCount,
+		"getbestblockhash":   rpcGetBestBlockHash,
+		"getblock":           rpcGetBlock,
+		"getrawtransaction":  rpcGetRawTransaction,
+		"sendrawtransaction": rpcSendRawTransaction,
+		"getbalance":         rpcGetBalance,
+		"listunspent":        rpcListUnspent,
+		"getmempoolinfo":     rpcGetMemPoolInfo,
+		"getmininginfo":      rpcGetMiningInfo,
+		"generate":           rpcGenerate,
+	}
+}
+
+/*
+ServeHTTP приймає запити JSON-RPC 2.0 методом POST. Параметри методів передаються масивом.
+*/
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRPCRequestSize+1))
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(n.handleRPC(body))
+}
+
+/*
+handleRPC розбирає запит, виконує метод і формує відповідь.
+*/
+func (n *Node) handleRPC(body []byte) rpcResponse {
+	response := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+
+	if len(body) > maxRPCRequestSize {
+		response.Error = &RPCError{Code: RPCInvalidRequest, Message: "request is too large"}
+		return response
+	}
+
+	var request rpcRequest
+	err := json.Unmarshal(body, &request)
+	if err != nil {
+		response.Error = &RPCError{Code: RPCParseError, Message: err.Error()}
+		return response
+	}
+	if request.ID != nil {
+		response.ID = request.ID
+	}
+
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		response.Error = &RPCError{Code: RPCInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		return response
+	}
+
+	handler, ok := rpcHandlers[request.Method]
+	if !ok {
+		response.Error = &RPCError{Code: RPCMethodNotFound, Message: fmt.Sprintf("method %s is not found", request.Method)}
+		return response
+	}
+
+	var params []json.RawMessage
+	if len(request.Params) > 0 && string(request.Params) != "null" {
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			response.Error = &RPCError{Code: RPCInvalidParams, Message: "params must be an array"}
+			return response
+		}
+	}
+
+	result, err := n.callRPC(handler, params)
+	if err != nil {
+		rpcErr, ok := err.(*RPCError)
+		if !ok {
+			rpcErr = &RPCError{Code: RPCInternalError, Message: err.Error()}
+		}
+		response.Error = rpcErr
+		return response
+	}
+	response.Result = result
+
+	return response
+}
+
+func (n *Node) callRPC(handler rpcHandler, params []json.RawMessage) (result interface{}, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return handler(n, params)
+}
+
+func rpcGetBlockCount(n *Node, params []json.RawMessage) (interface{}, error) {
+	return n.bc.GetBestHeight(), nil
+}
+
+func rpcGetBestBlockHash(n *Node, params []json.RawMessage) (interface{}, error) {
+	return hex.EncodeToString(n.bc.GetBestHash()), nil
+}
+
+func rpcGetBlock(n *Node, params []json.RawMessage) (interface{}, error) {
+	hash, err := hexParam(params, 0, "block hash")
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := n.bc.GetBlock(hash)
+	if err != nil {
+		return nil, &RPCError{Code: RPCInvalidAddress, Message: "block is not found"}
+	}
+
+	return newBlockInfo(&block), nil
+}
+
+func rpcGetRawTransaction(n *Node, params []json.RawMessage) (interface{}, error) {
+	txID, err := hexParam(params, 0, "transaction ID")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, ok := n.mempool.Get(txID)
+	if !ok {
+		found, err := n.bc.FindTransaction(txID)
+		if err != nil {
+			return nil, &RPCError{Code: RPCInvalidAddress, Message: "transaction is not found"}
+		}
+		tx = &found
+	}
+
+	return hex.EncodeToString(tx.Serialize()), nil
+}
+
+func rpcSendRawTransaction(n *Node, params []json.RawMessage) (interface{}, error) {
+	txData, err := hexParam(params, 0, "raw transaction")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := transaction.DecodeTransaction(txData)
+	if err != nil {
+		return nil, &RPCError{Code: RPCDeserializationError, Message: err.Error()}
+	}
+
+	err = n.acceptTransaction(&tx, "")
+	if err != nil {
+		return nil, &RPCError{Code: RPCVerifyRejected, Message: err.Error()}
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func rpcGetBalance(n *Node, params []json.RawMessage) (interface{}, error) {
+	lockingScript, err := addressParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: n.bc}
+
+	balance := 0
+	for _, out := range UTXOSet.FindUTXO(lockingScript) {
+		balance += out.Value
+	}
+
+	return balance, nil
+}
+
+func rpcListUnspent(n *Node, params []json.RawMessage) (interface{}, error) {
+	lockingScript, err := addressParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// виходи непідтверджених транзакцій пулу теж можна витратити, а вже витрачені пулом - ні
+	unspent := []UnspentInfo{}
+	for _, utxo := range n.mempool.View().FindUnspentOutputs(lockingScript) {
+		unspent = append(unspent, UnspentInfo{
+			TxId:         utxo.TxId,
+			VOut:         utxo.VOut,
+			Value:        utxo.Output.Value,
+			ScriptPubKey: hex.EncodeToString(utxo.Output.LockingScript()),
+		})
+	}
+
+	return unspent, nil
+}
+
+func rpcGetMemPoolInfo(n *Node, params []json.RawMessage) (interface{}, error) {
+	return MemPoolInfo{Size: n.mempool.Count(), Bytes: n.mempool.Size()}, nil
+}
+
+func rpcGetMiningInfo(n *Node, params []json.RawMessage) (interface{}, error) {
+	bestHash := n.bc.GetBestHash()
+	best, err := n.bc.GetBlock(bestHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return MiningInfo{
+		Blocks:        best.Height,
+		Bits:          fmt.Sprintf("%08x", best.Bits),
+		PooledTx:      n.mempool.Count(),
+		Mining:        len(n.MiningAddress) > 0,
+		HashRate:      n.hashRate,
+		MiningAddress: n.MiningAddress,
+		Chain:         chaincfg.ActiveNetParams.Name,
+	}, nil
+}
+
+func rpcGenerate(n *Node, params []json.RawMessage) (interface{}, error) {
+	count, err := intParam(params, 0, "number of blocks")
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		return nil, &RPCError{Code: RPCInvalidParams, Message: "number of blocks must be positive"}
+	}
+
+	address, err := stringParam(params, 1, "address")
+	if err != nil {
+		return nil, err
+	}
+	if !wal.ValidateAddress(address) {
+		return nil, &RPCError{Code: RPCInvalidAddress, Message: "address is not valid"}
+	}
+
+	hashes, err := n.generateBlocks(count, address)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, hash := range hashes {
+		result = append(result, hex.EncodeToString(hash))
+	}
+
+	return result, nil
+}
+
+func newBlockInfo(block *bloks.Block) BlockInfo {
+	info := BlockInfo{
+		Hash:          hex.EncodeToString(block.Hash),
+		Height:        block.Height,
+		Version:       block.Version,
+		PrevBlockHash: hex.EncodeToString(block.PrevBlockHash),
+		MerkleRoot:    hex.EncodeToString(block.MerkleRoot),
+		Timestamp:     block.Timestamp,
+		Bits:          fmt.Sprintf("%08x", block.Bits),
+		Nonce:         block.Nonce,
+		Transactions:  []string{},
+	}
+	for _, tx := range block.Transactions {
+		info.Transactions = append(info.Transactions, hex.EncodeToString(tx.ID))
+	}
+
+	return info
+}
+
+/*
+stringParam повертає рядковий параметр запиту з позиції index.
+*/
+func stringParam(params []json.RawMessage, index int, name string) (string, error) {
+	if index >= len(params) {
+		return "", &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("missing %s parameter", name)}
+	}
+
+	var value string
+	err := json.Unmarshal(params[index], &value)
+	if err != nil {
+		return "", &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("%s must be a string", name)}
+	}
+
+	return value, nil
+}
+
+/*
+intParam повертає цілочисельний параметр запиту з позиції index.
+*/
+func intParam(params []json.RawMessage, index int, name string) (int, error) {
+	if index >= len(params) {
+		return 0, &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("missing %s parameter", name)}
+	}
+
+	var value int
+	err := json.Unmarshal(params[index], &value)
+	if err != nil {
+		return 0, &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("%s must be an integer", name)}
+	}
+
+	return value, nil
+}
+
+func hexParam(params []json.RawMessage, index int, name string) ([]byte, error) {
+	value, err := stringParam(params, index, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("%s must be hex encoded", name)}
+	}
+
+	return data, nil
+}
+
+/*
+addressParam повертає скрипт блокування адреси з позиції index ( див. wallet.PayToAddrScript ).
+*/
+func addressParam(params []json.RawMessage, index int) ([]byte, error) {
+	address, err := stringParam(params, index, "address")
+	if err != nil {
+		return nil, err
+	}
+
+	if !wal.ValidateAddress(address) {
+		return nil, &RPCError{Code: RPCInvalidAddress, Message: "address is not valid"}
+	}
+
+	lockingScript, err := wal.PayToAddrScript(address)
+	if err != nil {
+		return nil, &RPCError{Code: RPCInvalidAddress, Message: err.Error()}
+	}
+
+	return lockingScript, nil
+}
@@ -3,35 +3,29 @@ package server
 import (
 	"blockchain1/blockchain"
 	"blockchain1/bloks"
+	"blockchain1/chaincfg"
 	"blockchain1/transaction"
 	"bytes"
 	"encoding/gob"
-	"encoding/hex"
 	"fmt"
-	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
-const (
-	protocol      = "tcp"
-	nodeVersion   = 1
-	commandLength = 12
-)
-
-var nodeAddress string
-var miningAddress string
-
 /*
-KnownNodes - зберігає список відомих вузлів зазвичай це має бути список DNS
-за допомогою якого можна знайти вузол в мережі
-для локального тестування використовуємо список з одного вузла
-який фактично являється основним центральним вузлом з адресою
-"127.0.0.1:3000"
+nodeVersion - версія протоколу вузла. З версії 2 блоки і транзакції передаються в канонічному кодуванні,
+з версії 3 ланцюг завантажується через getheaders/headers замість getblocks,
+тому з вузлами старіших версій ( minPeerVersion ) вузол не з'єднується.
 */
-var KnownNodes = []string{"127.0.0.1:3000"}
-var blocksInTransit [][]byte
-var TransactionMemoryPool = make(map[string]transaction.Transaction)
+const (
+	protocol       = "tcp"
+	nodeVersion    = 3
+	minPeerVersion = 3
+	commandLength  = 12
+)
 
 type ver struct {
 	Version    int
@@ -39,8 +33,22 @@ type ver struct {
 	AddrFrom   string
 }
 
-type getBlocks struct {
+/*
+getHeaders - запит заголовків основного ланцюга, що йдуть після останнього спільного блоку з локатора Locator,
+до HashStop включно або до maxHeadersPerMessage заголовків.
+*/
+type getHeaders struct {
 	AddrFrom string
+	Locator  [][]byte
+	HashStop []byte
+}
+
+/*
+headers - відповідь на getHeaders: закодовані заголовки ( див. bloks.BlockHeader.Serialize ) від старших до новіших.
+*/
+type headers struct {
+	AddrFrom string
+	Headers  [][]byte
 }
 
 type inv struct {
@@ -66,433 +74,542 @@ type tx struct {
 }
 
 /*
-StartServer виконує запуск сервера ( вузла блокчейну node )
+StartServer виконує запуск сервера ( вузла блокчейну node ) і працює, поки процес не отримає
+сигнал завершення, після чого зупиняє вузол і закриває базу даних.
 */
-func StartServer(nodeID string, minerAddress string) {
-	// формуємо адресу вузла nodeID може мати наступні значення 3000, 3001, 3002 це для локального тестування
-	nodeAddress = fmt.Sprintf("127.0.0.1:%s", nodeID)
-
-	miningAddress = minerAddress
-
-	//ініціюємо прослуховування мережі на вказаній адресі protocol = "tcp"
-	ln, err := net.Listen(protocol, nodeAddress)
+func StartServer(node *Node) {
+	err := node.Start()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer func() { _ = ln.Close() }()
-	// ініціалізуємо новий екземпляр блокчейну з вказаним nodeID
-	bc := blockchain.NewBlockchain(nodeID)
 
-	/*
-			 якщо поточний вузол не є першим відомим вузлом
-			 у нашій реалізації це вузол з адресою " 127.0.0.1:3000 "
-		     то відправляємо запит для встановлення звязку з осно вним вузлом
-	*/
-	if nodeAddress != KnownNodes[0] {
-		sendVersion(KnownNodes[0], bc)
-	}
-	/*
-		запуск безкінечного циклу для прийому запитів від інших вузлів
-	*/
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Panic(err)
-		}
-		go handleConnection(conn, bc)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case <-signals:
+		fmt.Printf("Stopping node %s\n", node.ID)
+	case <-node.Done():
 	}
 
+	node.Stop()
 }
 
 /*
-handleConnection приймає вхідні запити і передає їх на обробку відповідним функціям обробки
+handleMessage передає повідомлення обробнику його команди.
+Першим повідомленням від вузла має бути version, інакше з'єднання розривається.
+Повідомлення обробляються по одному під n.mu, бо обробники змінюють стан вузла.
+Дані повідомлення приходять від іншого вузла і можуть бути некоректними,
+тому паніка під час їх розбору перетворюється на помилку.
 */
-func handleConnection(conn net.Conn, bc *blockchain.Blockchain) {
-	request, err := io.ReadAll(conn)
-	if err != nil {
-		log.Panic(err)
+func (n *Node) handleMessage(p *Peer, command string, payload []byte) (err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed payload: %v", r)
+		}
+	}()
+
+	fmt.Printf("Received %s command from %s\n", command, p)
+
+	if command != "version" && !p.hasVersion() {
+		return fmt.Errorf("%s command before version", command)
 	}
-	command := bytesToCommand(request[:commandLength])
-	fmt.Printf("Received %s command\n", command)
 
 	switch command {
 	case "block":
-		handleBlock(request, bc)
+		return n.handleBlock(p, payload)
 	case "inv":
-		handleInv(request)
-	case "getblocks":
-		handleGetBlocks(request, bc)
+		return n.handleInv(p, payload)
+	case "getheaders":
+		return n.handleGetHeaders(payload)
+	case "headers":
+		return n.handleHeaders(p, payload)
 	case "getdata":
-		handleGetData(request, bc)
+		return n.handleGetData(payload)
 	case "tx":
-		handleTx(request, bc)
+		return n.handleTx(payload)
 	case "version":
-		handleVersion(request, bc)
+		return n.handleVersion(p, payload)
+	case "verack":
+		return n.handleVerAck(p)
 	default:
 		fmt.Println("Unknown command!")
 	}
 
-	defer func() { _ = conn.Close() }()
+	return nil
 }
 
 /*
 handleVersion обробляє вхідний запит типу "version"
 та забезпечує синхронізацію станів блокчейну між вузлами та допомагає підтримувати актуальний список вузлів в мережі.
+Вузол, до якого підключилися, відповідає своїм version, і обидва вузли підтверджують отримання повідомленням verack.
 */
-func handleVersion(request []byte, bc *blockchain.Blockchain) {
+func (n *Node) handleVersion(p *Peer, request []byte) error {
 	var buff bytes.Buffer
 	var payload ver
 
 	// декодуємо дані з запиту
 	// та записуємо їх у структуру ver
-	// відкидається перших n байтів визначених у константі які вказують на тип команди
-	buff.Write(request[commandLength:])
+	buff.Write(request)
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+
+	if payload.Version < minPeerVersion {
+		return fmt.Errorf("peer protocol version %d is too old", payload.Version)
 	}
 
-	myBestHeight := bc.GetBestHeight()        // отримуємо висоту останнього блоку в ланцюгу поточного вузла
-	foreignerBestHeight := payload.BestHeight // отримуємо висоту останнього блоку в ланцюгу яка прийшла у запиті
+	p.mu.Lock()
+	if p.versionReceived {
+		p.mu.Unlock()
+		return fmt.Errorf("duplicate version message")
+	}
+	p.versionReceived = true
+	if payload.AddrFrom != "" {
+		p.addr = payload.AddrFrom
+	}
+	p.mu.Unlock()
+
+	if p.Inbound() {
+		n.sendVersion(p)
+	}
+	p.QueueMessage("verack", []byte{})
 
-	if myBestHeight < foreignerBestHeight {
-		/* якщо у поточного вузла висота менша
-		виконуємо запит на отримання блоків від вузла з вищою висотою
-		*/
-		sendGetBlocks(payload.AddrFrom)
-	} else if myBestHeight > foreignerBestHeight {
-		/*якщо у поточного вузла висота більша
-		виконуємо запит у відповідь і передаємо дані про свою висоту блоку
-		*/
-		sendVersion(payload.AddrFrom, bc)
+	// клієнт без адреси ( наприклад CLI ) лише передає транзакцію і не синхронізує ланцюг
+	if payload.AddrFrom == "" {
+		return nil
 	}
 
+	/*
+		якщо ланцюг іншого вузла вищий, запитуємо в нього заголовки,
+		а потім блоки ( див. sync.go ). Якщо нижчий - інший вузол побачить це з нашого version
+		і сам запросить заголовки
+	*/
+	n.addSyncPeer(payload.AddrFrom, payload.BestHeight)
+
 	// додаємо адресу вузла який надіслав запит до списку відомих вузлів
-	if !nodeIsKnown(payload.AddrFrom) {
-		KnownNodes = append(KnownNodes, payload.AddrFrom)
+	if !n.nodeIsKnown(payload.AddrFrom) {
+		n.knownNodes = append(n.knownNodes, payload.AddrFrom)
 	}
+
+	return nil
+}
+
+/*
+handleVerAck обробляє підтвердження рукостискання від іншого вузла.
+*/
+func (n *Node) handleVerAck(p *Peer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.verAckReceived = true
+
+	return nil
 }
 
-func handleTx(request []byte, bc *blockchain.Blockchain) {
+func (n *Node) handleTx(request []byte) error {
 	var buff bytes.Buffer
 	var payload tx
 
-	buff.Write(request[commandLength:])
+	buff.Write(request)
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	txData := payload.Transaction
-	tx := transaction.DeserializeTransaction(txData)
-	TransactionMemoryPool[hex.EncodeToString(tx.ID)] = tx
-
-	fmt.Printf("curent node addr:  %s\n", nodeAddress)
-	if nodeAddress == KnownNodes[0] {
-		for _, node := range KnownNodes {
-			if node != nodeAddress && node != payload.AddFrom {
-				sendInv(node, "tx", [][]byte{tx.ID})
-			}
-		}
-	} else {
-		if len(TransactionMemoryPool) >= 2 && len(miningAddress) > 0 {
-		MineTransactions:
-			var txs []*transaction.Transaction
-
-			for id := range TransactionMemoryPool {
-				tx := TransactionMemoryPool[id]
-				if bc.VerifyTransaction(&tx) {
-					txs = append(txs, &tx)
-				}
-			}
-			if len(txs) == 0 {
-				fmt.Println("All transactions are invalid! Waiting for new ones...")
-				return
-			}
-			cbTx := transaction.NewCoinbaseTX(miningAddress, "")
-			txs = append(txs, cbTx)
-
-			newBlock := bc.MineBlock(txs)
-			UTXOSet := blockchain.UTXOSet{
-				Blockchain: bc,
-			}
-			UTXOSet.Reindex()
+	tx, err := transaction.DecodeTransaction(payload.Transaction)
+	if err != nil {
+		return err
+	}
 
-			fmt.Println("New block is mined!")
+	err = n.acceptTransaction(&tx, payload.AddFrom)
+	if err != nil {
+		fmt.Printf("Transaction %x is rejected: %s\n", tx.ID, err)
+	}
 
-			for _, tx := range txs {
-				txID := hex.EncodeToString(tx.ID)
-				delete(TransactionMemoryPool, txID)
-			}
+	return nil
+}
 
-			for _, node := range KnownNodes {
-				if node != nodeAddress {
-					sendInv(node, "block", [][]byte{newBlock.Hash})
-				}
-			}
+/*
+acceptTransaction перевіряє транзакцію і додає її в пул: основний вузол розсилає її іншим вузлам,
+окрім вузла from, від якого вона прийшла, а майнер вузла оновлює шаблон блоку.
+Повертає помилку, якщо пул відхилив транзакцію. Викликається під n.mu.
+*/
+func (n *Node) acceptTransaction(tx *transaction.Transaction, from string) error {
+	_, err := n.mempool.Add(tx)
+	if err != nil {
+		return err
+	}
 
-			if len(TransactionMemoryPool) > 0 {
-				goto MineTransactions
+	fmt.Printf("curent node addr:  %s\n", n.address)
+	if n.address == chaincfg.ActiveNetParams.CentralNode() {
+		for _, node := range n.knownNodes {
+			if node != n.address && node != from {
+				n.sendInv(node, "tx", [][]byte{tx.ID})
 			}
 		}
 	}
+
+	n.updateMiner()
+
+	return nil
 }
 
 /*
 handleBlock обробляє вхідний запит від іншого вузла з новим блоком
 і зберігає його
 */
-func handleBlock(request []byte, bc *blockchain.Blockchain) {
+func (n *Node) handleBlock(p *Peer, request []byte) error {
 	var buff bytes.Buffer
 	var payload block
 
-	buff.Write(request[commandLength:])
+	buff.Write(request)
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	blockData := payload.Block
-	block := bloks.DeserializeBlock(blockData)
+	block, err := bloks.DecodeBlock(payload.Block)
+	if err != nil {
+		return err
+	}
 
 	fmt.Println("Received a new block!")
-	bc.AddBlock(block)
-
-	fmt.Printf("Added block %x\n", block.Hash)
-
-	if len(blocksInTransit) > 0 {
-		blockHash := blocksInTransit[0]
-		sendGetData(payload.AddrFrom, "block", blockHash)
+	n.receiveBlock(block, p.Addr())
 
-		blocksInTransit = blocksInTransit[1:]
-	} else {
-		UTXOSet := blockchain.UTXOSet{
-			Blockchain: bc,
-		}
-		UTXOSet.Reindex()
-	}
+	return nil
 }
 
 /*
 handleGetData обробляє запит від іншого вузла на отримання блоку або транзакції
 за їх хешем
 */
-func handleGetData(request []byte, bc *blockchain.Blockchain) {
+func (n *Node) handleGetData(request []byte) error {
 	var buff bytes.Buffer
 	var payload getData
 
-	buff.Write(request[commandLength:])
+	buff.Write(request)
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	if payload.Type == "block" {
-		block, err := bc.GetBlock(payload.ID)
+		block, err := n.bc.GetBlock(payload.ID)
 		if err != nil {
-			return
+			return nil
 		}
 		// відправляємо блок на вказану адресу
-		sendBlock(payload.AddrFrom, &block)
+		n.sendBlock(payload.AddrFrom, &block)
 	}
 
 	if payload.Type == "tx" {
-		txID := hex.EncodeToString(payload.ID)
-		tx := TransactionMemoryPool[txID]
+		tx, ok := n.mempool.Get(payload.ID)
+		if !ok {
+			return nil
+		}
+
+		n.sendTx(payload.AddrFrom, tx)
+
+	}
+
+	return nil
+}
 
-		SendTx(payload.AddrFrom, &tx)
+/*
+updateMemoryPool синхронізує пул транзакцій зі зміною основного ланцюга:
+транзакції з відключених блоків повертаються в пул, а транзакції з приєднаних блоків видаляються з нього.
+*/
+func (n *Node) updateMemoryPool(reorg *blockchain.Reorganization) {
+	// відключені блоки зібрані від вершини вниз, а транзакції пізніших блоків можуть витрачати
+	// виходи раніших, тому повертаємо їх у пул від точки розгалуження
+	for i := len(reorg.Disconnected) - 1; i >= 0; i-- {
+		n.mempool.BlockDisconnected(reorg.Disconnected[i])
+	}
 
+	for _, block := range reorg.Connected {
+		n.mempool.BlockConnected(block)
 	}
+
+	// шаблон, над яким працює майнер, посилається на стару вершину
+	n.updateMiner()
 }
 
 /*
 handleInv обробляє вхідний запит від іншого вузла з інформацією про наявність блоків або транзакцій
 */
-func handleInv(request []byte) {
+func (n *Node) handleInv(p *Peer, request []byte) error {
 	var buff bytes.Buffer
 	var payload inv
 
-	buff.Write(request[commandLength:])
+	buff.Write(request)
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
 
-	// обробляємо інформацію про блоки
+	/*
+		обробляємо інформацію про блоки: щоб перевірити новий блок до завантаження,
+		спочатку запитуємо заголовки, яких ще немає, а блоки будуть запитані після них
+	*/
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
-		blockHash := payload.Items[0]
-		sendGetData(payload.AddrFrom, "block", blockHash)
-
-		var newInTransit [][]byte
-		for _, b := range blocksInTransit {
-			if bytes.Compare(b, blockHash) != 0 {
-				newInTransit = append(newInTransit, b)
+		peer := n.syncPeers[p.Addr()]
+		if peer == nil {
+			return nil
+		}
+
+		for _, hash := range payload.Items {
+			if !n.bc.HasHeader(hash) {
+				n.requestHeaders(peer, nil)
+				break
 			}
 		}
-		blocksInTransit = newInTransit
 	}
 
 	// обробляємо інформацію про транзакції
-	if payload.Type == "tx" {
+	if payload.Type == "tx" && len(payload.Items) > 0 {
 		txID := payload.Items[0]
 
-		if TransactionMemoryPool[hex.EncodeToString(txID)].ID == nil {
-			sendGetData(payload.AddrFrom, "tx", txID)
+		if !n.mempool.Has(txID) {
+			n.sendGetData(payload.AddrFrom, "tx", txID)
 		}
 	}
+
+	return nil
 }
 
 /*
-handleGetBlocks обробляє запит від іншого вузла на отримання відсутніх блоків
+handleGetHeaders обробляє запит від іншого вузла на отримання заголовків,
+яких у нього немає, і відповідає повідомленням headers
 */
-func handleGetBlocks(request []byte, bc *blockchain.Blockchain) {
+func (n *Node) handleGetHeaders(request []byte) error {
 	var buff bytes.Buffer
-	var payload getBlocks
+	var payload getHeaders
 
-	buff.Write(request[commandLength:])
+	buff.Write(request)
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+
+	var encoded [][]byte
+	for _, header := range n.bc.LocateHeaders(payload.Locator, payload.HashStop, maxHeadersPerMessage) {
+		encoded = append(encoded, header.Serialize())
+	}
+
+	n.sendData(payload.AddrFrom, "headers", gobEncode(headers{
+		AddrFrom: n.address,
+		Headers:  encoded,
+	}))
+
+	return nil
+}
+
+/*
+handleHeaders обробляє заголовки, надіслані у відповідь на getheaders.
+Недійсні заголовки або заголовки, які не продовжують відомий ланцюг, розривають з'єднання з вузлом
+*/
+func (n *Node) handleHeaders(p *Peer, request []byte) error {
+	var buff bytes.Buffer
+	var payload headers
+
+	buff.Write(request)
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		return err
+	}
+
+	peer := n.syncPeers[p.Addr()]
+	if peer == nil {
+		return nil
+	}
+
+	if len(payload.Headers) > maxHeadersPerMessage {
+		return fmt.Errorf("%d headers in one message", len(payload.Headers))
 	}
 
-	// отримуємо хеші усіх блоків з поточної ноди
-	blocks := bc.GetBlockHashes()
-	// формуємо запит з даними про хеші блоків
-	sendInv(payload.AddrFrom, "block", blocks)
+	var decoded []*bloks.BlockHeader
+	for _, data := range payload.Headers {
+		header, err := bloks.DecodeBlockHeader(data)
+		if err != nil {
+			return err
+		}
+		decoded = append(decoded, header)
+	}
+
+	fmt.Printf("Received %d headers\n", len(decoded))
+
+	return n.processHeaders(peer, decoded)
 }
 
 /*
-sendVersion отримує адресу вузла ( сервера ) і екземпляр блокчейну
+sendVersion отримує з'єднання з вузлом і екземпляр блокчейну
 підготовлює дані у вигляді байтів для відправки
-передає дані та адресу вузла ( сервера ) для відправки у функцію send Data
+та ставить повідомлення version в чергу відправки цього з'єднання
 */
-func sendVersion(addr string, bc *blockchain.Blockchain) {
+func (n *Node) sendVersion(p *Peer) {
 	//отримуємо число яке представляє номер останньго блоку в ланцюгу
 	// фактично це загальна кількість блоків в ланцюгу
 	// формуємо структуру ver з даними
-	bestHeight := bc.GetBestHeight()
+	bestHeight := n.bc.GetBestHeight()
 	// сереалізуємо структуру ver в байтовий масив
 	payload := gobEncode(ver{
 		Version:    nodeVersion,
 		BestHeight: bestHeight,
-		AddrFrom:   nodeAddress,
+		AddrFrom:   n.address,
 	})
-	// обєднуємо дані у одне байтове представлення
-	request := append(commandToBytes("version"), payload...)
-	sendData(addr, request)
+
+	p.QueueMessage("version", payload)
 }
 
 /*
-SendTx відправляє транзакцію на вказану адресу
+SendTx відправляє транзакцію на вказану адресу з клієнта, на якому вузол не запущено ( наприклад з CLI )
 */
 func SendTx(addr string, tnx *transaction.Transaction) {
 	data := tx{
-		AddFrom:     nodeAddress,
 		Transaction: tnx.Serialize(),
 	}
 	payload := gobEncode(data)
-	request := append(commandToBytes("tx"), payload...)
 
-	sendData(addr, request)
+	sendOnce(addr, "tx", payload)
+}
+
+/*
+sendTx відправляє транзакцію з пулу вузла на вказану адресу
+*/
+func (n *Node) sendTx(addr string, tnx *transaction.Transaction) {
+	data := tx{
+		AddFrom:     n.address,
+		Transaction: tnx.Serialize(),
+	}
+	payload := gobEncode(data)
+
+	n.sendData(addr, "tx", payload)
 }
 
 /*
 sendBlock відправляє блок на вказану адресу
 */
-func sendBlock(addr string, b *bloks.Block) {
+func (n *Node) sendBlock(addr string, b *bloks.Block) {
 	data := block{
-		AddrFrom: nodeAddress,
+		AddrFrom: n.address,
 		Block:    b.Serialize(),
 	}
 	payload := gobEncode(data)
-	request := append(commandToBytes("block"), payload...)
 
-	sendData(addr, request)
+	n.sendData(addr, "block", payload)
 }
 
 /*
 sendGetData відправляє запит на віддалений вузол для отримання одого блоку або транзакції
 */
-func sendGetData(address, kind string, id []byte) {
+func (n *Node) sendGetData(address, kind string, id []byte) {
 	payload := gobEncode(getData{
-		AddrFrom: nodeAddress,
+		AddrFrom: n.address,
 		Type:     kind, // block or tx (transaction)
 		ID:       id,
 	})
-	request := append(commandToBytes("getdata"), payload...)
 
-	sendData(address, request)
+	n.sendData(address, "getdata", payload)
 }
 
 /*
-sendGetBlocks відправляє запит на віддалений вузол для отримання відсутніх блоків
+sendGetHeaders відправляє запит на віддалений вузол для отримання заголовків після блоків локатора locator
 */
-func sendGetBlocks(address string) {
-	// формуємо структуру getBlocks з адресою відправника
-	payload := gobEncode(getBlocks{
-		AddrFrom: nodeAddress,
+func (n *Node) sendGetHeaders(address string, locator [][]byte) {
+	payload := gobEncode(getHeaders{
+		AddrFrom: n.address,
+		Locator:  locator,
 	})
-	// додаємо команду до байтового представлення даних
-	request := append(commandToBytes("getblocks"), payload...)
 
-	sendData(address, request)
+	n.sendData(address, "getheaders", payload)
 }
 
 /*
 sendInv формує та відправляє дані про наявність блоків або транзакцій на вказану адресу
 */
-func sendInv(address, kind string, items [][]byte) {
+func (n *Node) sendInv(address, kind string, items [][]byte) {
 	inventory := inv{
-		AddrFrom: nodeAddress,
+		AddrFrom: n.address,
 		Type:     kind,  // block or tx (transaction)
 		Items:    items, // хеші блоків або транзакцій
 	}
 	payload := gobEncode(inventory)
-	request := append(commandToBytes("inv"), payload...)
 
-	sendData(address, request)
+	n.sendData(address, "inv", payload)
 }
 
 /*
-sendData відправляє дані на вказану адресу
+sendData відправляє повідомлення з командою command і даними payload на вказану адресу
+через постійне з'єднання з нею
 */
-func sendData(addr string, data []byte) {
-	// відкриваємо з'єднання з вказаним адресом
-	conn, err := net.Dial(protocol, addr)
+func (n *Node) sendData(addr string, command string, payload []byte) {
 	/*
 		Якщо виникає помилка при спробі встановлення з'єднання,
 		виводиться повідомлення, що адреса addr недоступна.
-		Тоді виконується перебір відомих вузлів мережі (KnownNodes),
+		Тоді виконується перебір відомих вузлів мережі,
 		і вузол з адресою addr видаляється зі списку.
-		Після цього функція завершує роботу.
+		З'єднання встановлюється в окремій горутині, тому список блокується заново.
 	*/
+	n.peers.Send(addr, command, payload, func(err error) {
+		fmt.Printf("%s is not available: %s\n", addr, err)
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		n.removeKnownNode(addr)
+	})
+}
+
+/*
+sendOnce відкриває з'єднання з вузлом, представляється повідомленням version без адреси,
+відправляє одне повідомлення і закриває з'єднання.
+*/
+func sendOnce(addr string, command string, payload []byte) {
+	conn, err := net.DialTimeout(protocol, addr, dialTimeout)
 	if err != nil {
 		fmt.Printf("%s is not available\n", addr)
-		var updatedNodes []string
-		for _, node := range KnownNodes {
-			if node != addr {
-				updatedNodes = append(updatedNodes, node)
-			}
-		}
-		KnownNodes = updatedNodes
 		return
 	}
 	defer func() { _ = conn.Close() }()
 
-	// відправляємо дані на вказану адресу через відкрите з'єднання
-	_, err = io.Copy(conn, bytes.NewReader(data))
+	version := gobEncode(ver{
+		Version: nodeVersion,
+	})
+	err = writeMessage(conn, "version", version)
+	if err == nil {
+		err = writeMessage(conn, command, payload)
+	}
 	if err != nil {
-		log.Panic(err)
+		fmt.Printf("Can't send %s command to %s: %s\n", command, addr, err)
+	}
+}
+
+/*
+removeKnownNode видаляє недоступний вузол зі списку відомих вузлів
+*/
+func (n *Node) removeKnownNode(addr string) {
+	var updatedNodes []string
+	for _, node := range n.knownNodes {
+		if node != addr {
+			updatedNodes = append(updatedNodes, node)
+		}
 	}
+	n.knownNodes = updatedNodes
 }
 
 /*
@@ -541,8 +658,8 @@ func bytesToCommand(bytes []byte) string {
 /*
 nodeIsKnown перевіряє чи вузол з адресою addr вже відомий і збережений
 */
-func nodeIsKnown(addr string) bool {
-	for _, node := range KnownNodes {
+func (n *Node) nodeIsKnown(addr string) bool {
+	for _, node := range n.knownNodes {
 		if node == addr {
 			return true
 		}
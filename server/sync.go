@@ -0,0 +1,440 @@
+package server
+
+import (
+	"blockchain1/blockchain"
+	"blockchain1/bloks"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+Завантаження ланцюга починається з заголовків: вузол надсилає getheaders з локатором
+( див. blockchain.BlockLocator ), перевіряє отримані заголовки і лише потім запитує тіла блоків
+найкращого ланцюга заголовків. Блоки запитуються паралельно в усіх вузлів, які мають потрібну висоту,
+а приєднуються до ланцюга по черзі.
+
+maxHeadersPerMessage - максимальна кількість заголовків у повідомленні headers. Якщо їх стільки,
+у вузла, ймовірно, є ще заголовки, і їх запитують наступним getheaders.
+maxBlocksInFlightPerPeer - скільки блоків можна одночасно запитувати в одного вузла.
+downloadWindow - скільки блоків після вершини основного ланцюга можна запитувати одночасно.
+Блоки, отримані раніше за попередників, чекають у пам'яті, тому вікно обмежує її використання.
+headersTimeout - за який час вузол має відповісти на getheaders.
+blockRequestTimeout - за який час вузол має надіслати запитаний блок.
+blockStallingTimeout - скільки можна чекати перший блок вікна, коли вікно заповнене і решта блоків
+вже не запитуються: вузол, який його затримує, гальмує завантаження з усіх вузлів.
+syncCheckInterval - як часто перевіряються тайм-аути.
+*/
+const (
+	maxHeadersPerMessage     = 2000
+	maxBlocksInFlightPerPeer = 16
+	downloadWindow           = 512
+	headersTimeout           = 30 * time.Second
+	blockRequestTimeout      = 60 * time.Second
+	blockStallingTimeout     = 5 * time.Second
+	syncCheckInterval        = time.Second
+)
+
+/*
+syncPeer - стан завантаження ланцюга з одного вузла.
+- bestHeight - висота ланцюга вузла, відома з його version та надісланих заголовків.
+- headersRequested - коли вузлу надіслано getheaders, на який він ще не відповів.
+- blocksInFlight - скільки блоків запитано у вузла.
+*/
+type syncPeer struct {
+	addr             string
+	bestHeight       int
+	headersRequested time.Time
+	blocksInFlight   int
+}
+
+/*
+blockRequest - запитаний блок, який ще не отримано.
+*/
+type blockRequest struct {
+	peer      string
+	requested time.Time
+}
+
+/*
+receivedBlock - блок, отриманий раніше за свого попередника, і вузол, від якого він прийшов.
+*/
+type receivedBlock struct {
+	block *bloks.Block
+	from  string
+}
+
+/*
+addSyncPeer починає завантаження ланцюга з вузла addr, ланцюг якого має висоту bestHeight.
+Заголовки запитуються, лише якщо ланцюг вузла вищий за найкращий відомий заголовок,
+а блоки вже перевірених заголовків можна запитувати в нього одразу.
+*/
+func (n *Node) addSyncPeer(addr string, bestHeight int) {
+	// вузол міг перепідключитися, запити попереднього з'єднання вже не прийдуть
+	n.removeSyncPeer(addr)
+
+	peer := &syncPeer{addr: addr, bestHeight: bestHeight}
+	n.syncPeers[addr] = peer
+
+	if n.bc.BestHeader().Height < bestHeight {
+		n.requestHeaders(peer, nil)
+	}
+	n.requestBlocks()
+}
+
+/*
+removeSyncPeer припиняє завантаження з вузла addr, а запитані в нього блоки запитує в інших вузлів.
+*/
+func (n *Node) removeSyncPeer(addr string) {
+	if _, ok := n.syncPeers[addr]; !ok {
+		return
+	}
+	delete(n.syncPeers, addr)
+
+	for key, request := range n.blocksInFlight {
+		if request.peer == addr {
+			delete(n.blocksInFlight, key)
+		}
+	}
+
+	n.requestBlocks()
+}
+
+/*
+peerDisconnected викликається після розриву з'єднання з вузлом.
+*/
+func (n *Node) peerDisconnected(p *Peer) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	select {
+	case <-n.quit:
+		return
+	default:
+	}
+
+	// з вузлом може залишатися інше з'єднання
+	if p.Addr() == "" || n.peers.findPeer(p.Addr()) != nil {
+		return
+	}
+
+	n.removeSyncPeer(p.Addr())
+}
+
+/*
+disconnectSyncPeer розриває з'єднання з вузлом, який порушив протокол завантаження.
+*/
+func (n *Node) disconnectSyncPeer(addr string, reason string) {
+	fmt.Printf("Disconnecting %s: %s\n", addr, reason)
+
+	if p := n.peers.findPeer(addr); p != nil {
+		p.Disconnect()
+	}
+	n.removeSyncPeer(addr)
+}
+
+/*
+requestHeaders надсилає вузлу getheaders з локатором, який починається із заголовка from.
+Одночасно у вузла запитується лише одна порція заголовків.
+*/
+func (n *Node) requestHeaders(peer *syncPeer, from []byte) {
+	if !peer.headersRequested.IsZero() {
+		return
+	}
+	peer.headersRequested = time.Now()
+
+	n.sendGetHeaders(peer.addr, n.bc.BlockLocator(from))
+}
+
+/*
+processHeaders перевіряє заголовки, отримані від вузла peer, додає нові блоки до черги завантаження
+і, якщо заголовків повна порція, запитує наступну.
+*/
+func (n *Node) processHeaders(peer *syncPeer, headers []*bloks.BlockHeader) error {
+	peer.headersRequested = time.Time{}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	accepted, err := n.bc.AddHeaders(headers)
+	if len(accepted) > 0 {
+		last := accepted[len(accepted)-1]
+		if last.Height > peer.bestHeight {
+			peer.bestHeight = last.Height
+		}
+		n.updateDownloadQueue(accepted)
+	}
+	if err != nil {
+		n.requestBlocks()
+		return err
+	}
+
+	fmt.Printf("Best header is %x at height %d\n", n.bc.BestHeader().Hash, n.bc.BestHeader().Height)
+
+	if len(headers) == maxHeadersPerMessage {
+		n.requestHeaders(peer, accepted[len(accepted)-1].Hash)
+	}
+	n.requestBlocks()
+
+	return nil
+}
+
+/*
+updateDownloadQueue оновлює чергу блоків для завантаження після прийняття заголовків headers.
+Якщо заголовки продовжують чергу до найкращого заголовка, вони дописуються в її кінець,
+інакше ( найкращим став заголовок іншої гілки ) черга будується заново.
+*/
+func (n *Node) updateDownloadQueue(headers []*blockchain.IndexedHeader) {
+	best := n.bc.BestHeader()
+
+	tail := n.bc.GetBestHash()
+	if len(n.downloadQueue) > 0 {
+		tail = n.downloadQueue[len(n.downloadQueue)-1].Hash
+	}
+	if bytes.Equal(best.Hash, tail) {
+		return
+	}
+
+	if bytes.Equal(headers[len(headers)-1].Hash, best.Hash) {
+		for i, header := range headers {
+			if bytes.Equal(header.PrevBlockHash, tail) {
+				n.downloadQueue = append(n.downloadQueue, headers[i:]...)
+				return
+			}
+		}
+	}
+
+	n.resetDownloadQueue()
+}
+
+/*
+resetDownloadQueue будує чергу завантаження заново від вершини основного ланцюга до найкращого заголовка.
+Запити і отримані блоки попередньої черги забуваються: якщо такий блок все ж прийде,
+він буде оброблений як блок, про який вузол не просив.
+*/
+func (n *Node) resetDownloadQueue() {
+	n.downloadQueue = n.bc.PendingHeaders()
+	n.receivedBlocks = make(map[string]*receivedBlock)
+	n.blocksInFlight = make(map[string]*blockRequest)
+	for _, peer := range n.syncPeers {
+		peer.blocksInFlight = 0
+	}
+}
+
+/*
+requestBlocks запитує блоки з вікна завантаження, які ще не запитано і не отримано.
+Кожен блок запитується у найменш завантаженого вузла, ланцюг якого досягає висоти блоку.
+*/
+func (n *Node) requestBlocks() {
+	now := time.Now()
+
+	for i := 0; i < len(n.downloadQueue) && i < downloadWindow; i++ {
+		header := n.downloadQueue[i]
+		if n.blockIsPending(header.Hash) {
+			continue
+		}
+
+		var peer *syncPeer
+		for _, candidate := range n.syncPeers {
+			if candidate.bestHeight < header.Height || candidate.blocksInFlight >= maxBlocksInFlightPerPeer {
+				continue
+			}
+			if peer == nil || candidate.blocksInFlight < peer.blocksInFlight {
+				peer = candidate
+			}
+		}
+		// висоти блоків у черзі зростають, тому наступні блоки теж нікому запитати
+		if peer == nil {
+			return
+		}
+
+		peer.blocksInFlight++
+		n.blocksInFlight[hex.EncodeToString(header.Hash)] = &blockRequest{peer: peer.addr, requested: now}
+		n.sendGetData(peer.addr, "block", header.Hash)
+	}
+}
+
+/*
+receiveBlock обробляє блок, отриманий від вузла from. Запитаний блок чекає, поки будуть приєднані
+його попередники, а блок, про який вузол не просив, додається одразу або, якщо його попередника ще немає,
+потрапляє в пул блоків без попередника.
+*/
+func (n *Node) receiveBlock(block *bloks.Block, from string) {
+	key := hex.EncodeToString(block.Hash)
+
+	request := n.blocksInFlight[key]
+	if request == nil {
+		_ = n.processBlock(block, from)
+		return
+	}
+
+	delete(n.blocksInFlight, key)
+	if peer := n.syncPeers[request.peer]; peer != nil {
+		peer.blocksInFlight--
+	}
+	n.receivedBlocks[key] = &receivedBlock{block: block, from: from}
+
+	n.connectReceivedBlocks()
+	n.requestBlocks()
+}
+
+/*
+connectReceivedBlocks приєднує отримані блоки з початку черги завантаження, поки не трапиться блок,
+якого ще немає. Якщо блок недійсний, вузол, який його надіслав, відключається.
+*/
+func (n *Node) connectReceivedBlocks() {
+	for len(n.downloadQueue) > 0 {
+		next := n.downloadQueue[0]
+		key := hex.EncodeToString(next.Hash)
+
+		// блок міг прийти іншим шляхом, наприклад його добув сам вузол
+		if n.bc.HasBlock(next.Hash) {
+			n.downloadQueue = n.downloadQueue[1:]
+			continue
+		}
+
+		received := n.receivedBlocks[key]
+		if received == nil {
+			return
+		}
+		delete(n.receivedBlocks, key)
+		n.downloadQueue = n.downloadQueue[1:]
+
+		err := n.processBlock(received.block, received.from)
+		if err != nil {
+			var ruleErr blockchain.RuleError
+			if errors.As(err, &ruleErr) {
+				n.disconnectSyncPeer(received.from, fmt.Sprintf("invalid block %x", received.block.Hash))
+			}
+			n.resetDownloadQueue()
+			return
+		}
+	}
+}
+
+/*
+processBlock додає блок, отриманий від вузла from, до ланцюга, а потім блоки з пулу, які на нього чекали.
+Блок, попередника якого ще немає, зберігається в пулі блоків без попередника ( див. addOrphan ),
+якщо має доказ роботи і правильну структуру ( див. blockchain.CheckOrphanBlock ).
+*/
+func (n *Node) processBlock(block *bloks.Block, from string) error {
+	err := n.addBlock(block)
+	if errors.Is(err, blockchain.ErrOrphanBlock) {
+		err = blockchain.CheckOrphanBlock(block)
+		if err != nil {
+			fmt.Printf("Orphan block %x is rejected: %s\n", block.Hash, err)
+			return err
+		}
+		n.addOrphan(block, from)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	n.connectOrphans(block.Hash)
+
+	return nil
+}
+
+/*
+addBlock додає блок до ланцюга і оновлює пул транзакцій, якщо основний ланцюг змінився.
+*/
+func (n *Node) addBlock(block *bloks.Block) error {
+	reorg, err := n.bc.AddBlock(block)
+	if err != nil {
+		if !errors.Is(err, blockchain.ErrOrphanBlock) {
+			fmt.Printf("Block %x is rejected: %s\n", block.Hash, err)
+		}
+		return err
+	}
+	fmt.Printf("Added block %x\n", block.Hash)
+
+	if reorg != nil {
+		if len(reorg.Disconnected) > 0 {
+			fmt.Printf("Chain reorganization: %d blocks disconnected, %d blocks connected\n",
+				len(reorg.Disconnected), len(reorg.Connected))
+		}
+		n.updateMemoryPool(reorg)
+	}
+
+	return nil
+}
+
+/*
+checkSyncTimeouts відключає вузли, які не відповіли на getheaders або не надіслали запитаний блок вчасно,
+і вузол, який затримує перший блок заповненого вікна завантаження, якщо є інші вузли, що можуть його надіслати.
+Запитані у відключених вузлів блоки запитуються в інших.
+*/
+func (n *Node) checkSyncTimeouts(now time.Time) {
+	stalled := make(map[string]string)
+
+	for addr, peer := range n.syncPeers {
+		if !peer.headersRequested.IsZero() && now.Sub(peer.headersRequested) > headersTimeout {
+			stalled[addr] = "headers request timed out"
+		}
+	}
+
+	for key, request := range n.blocksInFlight {
+		if now.Sub(request.requested) > blockRequestTimeout {
+			stalled[request.peer] = fmt.Sprintf("block %s request timed out", key)
+		}
+	}
+
+	if len(n.downloadQueue) > 0 && len(n.syncPeers) > 1 && n.downloadWindowFull() {
+		key := hex.EncodeToString(n.downloadQueue[0].Hash)
+		if request := n.blocksInFlight[key]; request != nil && now.Sub(request.requested) > blockStallingTimeout {
+			stalled[request.peer] = fmt.Sprintf("block %s stalls download", key)
+		}
+	}
+
+	for addr, reason := range stalled {
+		n.disconnectSyncPeer(addr, reason)
+	}
+}
+
+/*
+downloadWindowFull повідомляє, чи всі блоки вікна завантаження вже запитано або отримано.
+*/
+func (n *Node) downloadWindowFull() bool {
+	for i := 0; i < len(n.downloadQueue) && i < downloadWindow; i++ {
+		if !n.blockIsPending(n.downloadQueue[i].Hash) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+blockIsPending повідомляє, чи блок вже запитано, отримано або він чекає на свого попередника
+в пулі блоків без попередника.
+*/
+func (n *Node) blockIsPending(hash []byte) bool {
+	key := hex.EncodeToString(hash)
+
+	return n.blocksInFlight[key] != nil || n.receivedBlocks[key] != nil || n.orphans.has(hash)
+}
+
+/*
+runSyncMonitor періодично перевіряє тайм-аути завантаження, поки вузол не зупинено.
+*/
+func (n *Node) runSyncMonitor() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(syncCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.quit:
+			return
+		case now := <-ticker.C:
+			n.mu.Lock()
+			n.checkSyncTimeouts(now)
+			n.mu.Unlock()
+		}
+	}
+}
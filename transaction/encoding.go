@@ -0,0 +1,316 @@
+package transaction
+
+import (
+	"blockchain1/lib/serialize"
+	"fmt"
+	"log"
+)
+
+/*
+LegacyTxVersion - версія транзакцій, створених до канонічного кодування. Їх ідентифікатор і корінь дерева Меркла
+обчислюються з gob кодування ( див. gobEncoding.go ), а підписи - з текстового представлення структури,
+як і раніше, тому вони залишаються дійсними після перекодування бази даних.
+CanonicalTxVersion - версія, в якій ідентифікатор, корінь дерева Меркла та підписи
+обчислюються з канонічного бінарного кодування.
+ScriptTxVersion - версія, в якій виходи блокуються скриптом Script, а входи розблоковуються скриптом ScriptSig
+( див. пакет script ). Транзакції попередніх версій блокують виходи на PubKeyHash, а входи мають Signature і PubKey.
+LockTimeTxVersion - версія, в якій транзакція має LockTime, а входи - Sequence ( див. lockTime.go ).
+У транзакціях попередніх версій ці поля не кодуються і дорівнюють нулю.
+TxVersion - версія нових транзакцій.
+*/
+const (
+	LegacyTxVersion    = 0
+	CanonicalTxVersion = 1
+	ScriptTxVersion    = 2
+	LockTimeTxVersion  = 3
+	TxVersion          = LockTimeTxVersion
+)
+
+/*
+outputsEncodingVersion - версія кодування TXOutputs у UTXO set. У версії 2 не було блоку, який створив виходи,
+у версії 1 виходи також не мали Script.
+*/
+const (
+	outputsEncodingVersion        = 3
+	noBlockOutputsEncodingVersion = 2
+	legacyOutputsEncodingVersion  = 1
+)
+
+/*
+legacyTransaction, legacyInput та legacyOutput мають ті самі поля, що й Transaction, TXInput та TXOutput
+до появи версії, тому текстове представлення legacy транзакцій не змінилося.
+*/
+type legacyTransaction struct {
+	ID   []byte
+	VIn  []legacyInput
+	VOut []legacyOutput
+}
+
+type legacyInput struct {
+	TxId      []byte
+	VOut      int
+	Signature []byte
+	PubKey    []byte
+}
+
+type legacyOutput struct {
+	Value      int
+	PubKeyHash []byte
+}
+
+func newLegacyTransaction(tx *Transaction) legacyTransaction {
+	legacy := legacyTransaction{ID: tx.ID}
+
+	for _, vin := range tx.VIn {
+		legacy.VIn = append(legacy.VIn, legacyInput{
+			TxId:      vin.TxId,
+			VOut:      vin.VOut,
+			Signature: vin.Signature,
+			PubKey:    vin.PubKey,
+		})
+	}
+	for _, vout := range tx.VOut {
+		legacy.VOut = append(legacy.VOut, legacyOutput{
+			Value:      vout.Value,
+			PubKeyHash: vout.PubKeyHash,
+		})
+	}
+
+	return legacy
+}
+
+/*
+encodeInput записує вхід транзакції версії version: TxId, VOut, а далі ScriptSig
+або, у версіях до ScriptTxVersion, Signature і PubKey. Починаючи з LockTimeTxVersion, в кінці записується Sequence.
+*/
+func encodeInput(w *serialize.Writer, in *TXInput, version int) {
+	w.WriteBytes(in.TxId)
+	w.WriteVarint(int64(in.VOut))
+	if version >= ScriptTxVersion {
+		w.WriteBytes(in.ScriptSig)
+	} else {
+		w.WriteBytes(in.Signature)
+		w.WriteBytes(in.PubKey)
+	}
+	if version >= LockTimeTxVersion {
+		w.WriteUint32(in.Sequence)
+	}
+}
+
+func decodeInput(r *serialize.Reader, version int) TXInput {
+	var in TXInput
+
+	in.TxId = r.ReadBytes()
+	in.VOut = r.ReadInt()
+	if version >= ScriptTxVersion {
+		in.ScriptSig = r.ReadBytes()
+	} else {
+		in.Signature = r.ReadBytes()
+		in.PubKey = r.ReadBytes()
+	}
+	if version >= LockTimeTxVersion {
+		in.Sequence = r.ReadUint32()
+	}
+
+	return in
+}
+
+/*
+encodeOutput записує вихід транзакції версії version: Value, а далі Script
+або, у версіях до ScriptTxVersion, PubKeyHash.
+*/
+func encodeOutput(w *serialize.Writer, out *TXOutput, version int) {
+	w.WriteVarint(int64(out.Value))
+	if version >= ScriptTxVersion {
+		w.WriteBytes(out.Script)
+		return
+	}
+	w.WriteBytes(out.PubKeyHash)
+}
+
+func decodeOutput(r *serialize.Reader, version int) TXOutput {
+	var out TXOutput
+
+	out.Value = r.ReadInt()
+	if version >= ScriptTxVersion {
+		out.Script = r.ReadBytes()
+	} else {
+		out.PubKeyHash = r.ReadBytes()
+	}
+
+	return out
+}
+
+/*
+Encode записує вихід у UTXO set чи дані відключення блоку: Value, PubKeyHash, Script.
+Там зберігаються виходи транзакцій усіх версій, тому записуються обидва способи блокування.
+*/
+func (out *TXOutput) Encode(w *serialize.Writer) {
+	w.WriteVarint(int64(out.Value))
+	w.WriteBytes(out.PubKeyHash)
+	w.WriteBytes(out.Script)
+}
+
+func (out *TXOutput) Decode(r *serialize.Reader) {
+	out.Value = r.ReadInt()
+	out.PubKeyHash = r.ReadBytes()
+	out.Script = r.ReadBytes()
+}
+
+/*
+DecodeLegacy розбирає вихід, закодований до появи Script: Value, PubKeyHash.
+*/
+func (out *TXOutput) DecodeLegacy(r *serialize.Reader) {
+	out.Value = r.ReadInt()
+	out.PubKeyHash = r.ReadBytes()
+	out.Script = nil
+}
+
+/*
+Encode записує транзакцію: Version, ID, кількість входів і входи, кількість виходів і виходи,
+а починаючи з LockTimeTxVersion - LockTime.
+*/
+func (tx *Transaction) Encode(w *serialize.Writer) {
+	w.WriteVarint(int64(tx.Version))
+	w.WriteBytes(tx.ID)
+
+	w.WriteUvarint(uint64(len(tx.VIn)))
+	for i := range tx.VIn {
+		encodeInput(w, &tx.VIn[i], tx.Version)
+	}
+
+	w.WriteUvarint(uint64(len(tx.VOut)))
+	for i := range tx.VOut {
+		encodeOutput(w, &tx.VOut[i], tx.Version)
+	}
+
+	if tx.Version >= LockTimeTxVersion {
+		w.WriteUint32(tx.LockTime)
+	}
+}
+
+func (tx *Transaction) Decode(r *serialize.Reader) {
+	tx.Version = r.ReadInt()
+	tx.ID = r.ReadBytes()
+
+	tx.VIn = nil
+	for i, count := 0, r.ReadCount(); i < count; i++ {
+		tx.VIn = append(tx.VIn, decodeInput(r, tx.Version))
+	}
+
+	tx.VOut = nil
+	for i, count := 0, r.ReadCount(); i < count; i++ {
+		tx.VOut = append(tx.VOut, decodeOutput(r, tx.Version))
+	}
+
+	tx.LockTime = 0
+	if tx.Version >= LockTimeTxVersion {
+		tx.LockTime = r.ReadUint32()
+	}
+}
+
+/*
+Serialize повертає канонічне бінарне кодування транзакції,
+в якому вона зберігається, передається між вузлами та через RPC.
+*/
+func (tx *Transaction) Serialize() []byte {
+	var w serialize.Writer
+	tx.Encode(&w)
+
+	return w.Bytes()
+}
+
+/*
+DecodeTransaction розбирає транзакцію, закодовану Serialize.
+Повертає помилку, якщо дані пошкоджені або містять зайві байти.
+*/
+func DecodeTransaction(data []byte) (Transaction, error) {
+	var tx Transaction
+
+	r := serialize.NewReader(data)
+	tx.Decode(r)
+	if err := r.Finish(); err != nil {
+		return Transaction{}, fmt.Errorf("can't decode transaction: %w", err)
+	}
+
+	return tx, nil
+}
+
+/*
+DeserializeTransaction розбирає транзакцію з бази даних, пошкоджені дані є фатальною помилкою.
+*/
+func DeserializeTransaction(data []byte) Transaction {
+	tx, err := DecodeTransaction(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return tx
+}
+
+/*
+Serialize повертає канонічне кодування виходів для UTXO set:
+версію кодування, виходи, їх індекси та блок, який їх створив.
+*/
+func (outs TXOutputs) Serialize() []byte {
+	var w serialize.Writer
+
+	w.WriteUvarint(outputsEncodingVersion)
+	w.WriteUvarint(uint64(len(outs.Outputs)))
+	for i := range outs.Outputs {
+		outs.Outputs[i].Encode(&w)
+	}
+	for _, index := range outs.Indexes {
+		w.WriteVarint(int64(index))
+	}
+	outs.Block.Encode(&w)
+
+	return w.Bytes()
+}
+
+func DeserializeOutputs(data []byte) TXOutputs {
+	var outputs TXOutputs
+
+	r := serialize.NewReader(data)
+	version := r.ReadUvarint()
+	if version != outputsEncodingVersion && version != noBlockOutputsEncodingVersion && version != legacyOutputsEncodingVersion {
+		r.Fail(fmt.Errorf("unsupported outputs encoding version %d", version))
+	}
+
+	count := r.ReadCount()
+	for i := 0; i < count; i++ {
+		var out TXOutput
+		if version == legacyOutputsEncodingVersion {
+			out.DecodeLegacy(r)
+		} else {
+			out.Decode(r)
+		}
+		outputs.Outputs = append(outputs.Outputs, out)
+	}
+	for i := 0; i < count; i++ {
+		outputs.Indexes = append(outputs.Indexes, r.ReadInt())
+	}
+	// записи старих версій отримують блок під час міграції бази даних
+	if version == outputsEncodingVersion {
+		outputs.Block.Decode(r)
+	}
+
+	if err := r.Finish(); err != nil {
+		log.Panic(fmt.Errorf("can't decode outputs: %w", err))
+	}
+
+	return outputs
+}
+
+/*
+Encode записує висоту і медіанний час блоку.
+*/
+func (ctx *SpendContext) Encode(w *serialize.Writer) {
+	w.WriteVarint(int64(ctx.Height))
+	w.WriteVarint(ctx.MedianTime)
+}
+
+func (ctx *SpendContext) Decode(r *serialize.Reader) {
+	ctx.Height = r.ReadInt()
+	ctx.MedianTime = r.ReadVarint()
+}
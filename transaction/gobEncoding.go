@@ -0,0 +1,290 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+)
+
+/*
+Legacy транзакції ( LegacyTxVersion ) створювалися, коли транзакції кодувалися gob: ідентифікатор транзакції -
+sha256 від gob кодування транзакції без ID і підписів, а корінь дерева Меркла блоку обчислювався
+з gob кодування цілих транзакцій.
+gob записує перед значенням визначення його типів з ідентифікаторами, які процес видає типам у порядку
+їх першого використання, тому ті самі транзакції в різних процесах старого вузла кодувалися по-різному.
+Тут це кодування відтворюється для кожного розміщення ідентифікаторів, яке міг отримати старий вузол
+( див. legacyGobLayouts ), і з них вибирається те, з яким збігається збережений хеш.
+
+gobFirstUserID - перший ідентифікатор, який gob видає типам програми.
+gobMaxUserID - межа ідентифікаторів, які перебираються: старий вузол кодував gob кілька десятків типів.
+gobIntID і gobBytesID - ідентифікатори вбудованих типів int і []byte.
+*/
+const (
+	gobFirstUserID = 64
+	gobMaxUserID   = 128
+	gobIntID       = 2
+	gobBytesID     = 5
+)
+
+/*
+gobLayout - ідентифікатори gob типів Transaction, TXInput, []TXInput, TXOutput і []TXOutput в процесі старого вузла.
+*/
+type gobLayout struct {
+	tx      int
+	input   int
+	inputs  int
+	output  int
+	outputs int
+}
+
+/*
+legacyGobLayouts - розміщення ідентифікаторів, які могли отримати типи транзакції.
+gob видає ідентифікатор структурі, а потім типам її полів, тому Transaction і типи її входів і виходів
+отримують ідентифікатори підряд. Якщо ж процес раніше кодував TXOutputs з UTXO set, TXOutput і []TXOutput
+отримали ідентифікатори одразу після TXOutputs, а Transaction і типи входів - пізніше.
+Першим іде розміщення процесу, який ще нічого не кодував gob: так кодувалася coinbase транзакція genesis блоку.
+*/
+var legacyGobLayouts = func() []gobLayout {
+	var layouts []gobLayout
+
+	for tx := gobFirstUserID; tx+4 < gobMaxUserID; tx++ {
+		layouts = append(layouts, gobLayout{tx: tx, input: tx + 1, inputs: tx + 2, output: tx + 3, outputs: tx + 4})
+	}
+	for outputs := gobFirstUserID; outputs+2 < gobMaxUserID; outputs++ {
+		for tx := outputs + 3; tx+2 < gobMaxUserID; tx++ {
+			layouts = append(layouts, gobLayout{tx: tx, input: tx + 1, inputs: tx + 2, output: outputs + 1, outputs: outputs + 2})
+		}
+	}
+
+	return layouts
+}()
+
+/*
+legacyHash повертає хеш legacy транзакції, підготовленої в Hash, з тим розміщенням ідентифікаторів gob типів,
+з яким він збігається з id. Якщо такого розміщення немає, хеш обчислюється з розміщенням нового процесу.
+*/
+func (tx *Transaction) legacyHash(id []byte) []byte {
+	for _, layout := range legacyGobLayouts {
+		hash := sha256.Sum256(layout.encode(tx))
+		if bytes.Equal(hash[:], id) {
+			return hash[:]
+		}
+	}
+
+	hash := sha256.Sum256(legacyGobLayouts[0].encode(tx))
+
+	return hash[:]
+}
+
+/*
+LegacyFingerprints повертає представлення транзакцій блоку txs для дерева Меркла ( див. Fingerprint ),
+в якому legacy транзакції закодовано gob з першим розміщенням ідентифікаторів типів, для якого match повертає true,
+і true. Якщо такого розміщення немає, повертає представлення з розміщенням нового процесу і false.
+Усі транзакції блоку старий вузол кодував в одному процесі, тому розміщення в них спільне.
+*/
+func LegacyFingerprints(txs []*Transaction, match func(fingerprints [][]byte) bool) ([][]byte, bool) {
+	legacy := false
+	for _, tx := range txs {
+		legacy = legacy || tx.Version == LegacyTxVersion
+	}
+	if !legacy {
+		fingerprints := make([][]byte, len(txs))
+		for i, tx := range txs {
+			fingerprints[i] = tx.Fingerprint()
+		}
+
+		return fingerprints, match(fingerprints)
+	}
+
+	for _, layout := range legacyGobLayouts {
+		fingerprints := layout.fingerprints(txs)
+		if match(fingerprints) {
+			return fingerprints, true
+		}
+	}
+
+	return legacyGobLayouts[0].fingerprints(txs), false
+}
+
+func (layout gobLayout) fingerprints(txs []*Transaction) [][]byte {
+	fingerprints := make([][]byte, len(txs))
+	for i, tx := range txs {
+		if tx.Version == LegacyTxVersion {
+			fingerprints[i] = layout.encode(tx)
+		} else {
+			fingerprints[i] = tx.Fingerprint()
+		}
+	}
+
+	return fingerprints
+}
+
+/*
+encode повертає gob кодування транзакції так, як його записував новий gob.Encoder старого вузла:
+визначення типів Transaction, []TXInput, TXInput, []TXOutput і TXOutput, а потім саму транзакцію.
+Поля з нульовими значеннями gob пропускає.
+*/
+func (layout gobLayout) encode(tx *Transaction) []byte {
+	var w gobWriter
+
+	w.message(-layout.tx, func(m *gobWriter) {
+		m.structType("Transaction", layout.tx, []gobField{{"ID", gobBytesID}, {"VIn", layout.inputs}, {"VOut", layout.outputs}})
+	})
+	w.message(-layout.inputs, func(m *gobWriter) {
+		m.sliceType("[]transaction.TXInput", layout.inputs, layout.input)
+	})
+	w.message(-layout.input, func(m *gobWriter) {
+		m.structType("TXInput", layout.input, []gobField{{"TxId", gobBytesID}, {"VOut", gobIntID}, {"Signature", gobBytesID}, {"PubKey", gobBytesID}})
+	})
+	w.message(-layout.outputs, func(m *gobWriter) {
+		m.sliceType("[]transaction.TXOutput", layout.outputs, layout.output)
+	})
+	w.message(-layout.output, func(m *gobWriter) {
+		m.structType("TXOutput", layout.output, []gobField{{"Value", gobIntID}, {"PubKeyHash", gobBytesID}})
+	})
+
+	w.message(layout.tx, func(m *gobWriter) {
+		var s gobStruct
+		s.bytes(m, 0, tx.ID)
+		if len(tx.VIn) > 0 {
+			s.field(m, 1)
+			m.uint(uint64(len(tx.VIn)))
+			for _, vin := range tx.VIn {
+				var in gobStruct
+				in.bytes(m, 0, vin.TxId)
+				in.int(m, 1, int64(vin.VOut))
+				in.bytes(m, 2, vin.Signature)
+				in.bytes(m, 3, vin.PubKey)
+				m.uint(0)
+			}
+		}
+		if len(tx.VOut) > 0 {
+			s.field(m, 2)
+			m.uint(uint64(len(tx.VOut)))
+			for _, vout := range tx.VOut {
+				var out gobStruct
+				out.int(m, 0, int64(vout.Value))
+				out.bytes(m, 1, vout.PubKeyHash)
+				m.uint(0)
+			}
+		}
+		m.uint(0)
+	})
+
+	return w.buf
+}
+
+/*
+gobWriter записує значення у форматі gob: беззнакові числа до 128 - одним байтом, більші - байтом
+з мінус кількістю байтів і самими байтами big-endian, знакові - зі знаком у молодшому біті.
+*/
+type gobWriter struct {
+	buf []byte
+}
+
+type gobField struct {
+	name string
+	id   int
+}
+
+func (w *gobWriter) uint(x uint64) {
+	if x < 0x80 {
+		w.buf = append(w.buf, byte(x))
+		return
+	}
+
+	data := new(big.Int).SetUint64(x).Bytes()
+	w.buf = append(w.buf, byte(-len(data)))
+	w.buf = append(w.buf, data...)
+}
+
+func (w *gobWriter) int(x int64) {
+	if x < 0 {
+		w.uint(uint64(^x)<<1 | 1)
+		return
+	}
+
+	w.uint(uint64(x) << 1)
+}
+
+func (w *gobWriter) bytes(data []byte) {
+	w.uint(uint64(len(data)))
+	w.buf = append(w.buf, data...)
+}
+
+/*
+message записує повідомлення gob: довжину, ідентифікатор типу ( від'ємний для визначення типу ) і тіло.
+*/
+func (w *gobWriter) message(id int, body func(m *gobWriter)) {
+	var m gobWriter
+	m.int(int64(id))
+	body(&m)
+
+	w.bytes(m.buf)
+}
+
+/*
+commonType записує поля Name і Id спільної частини визначення типу.
+*/
+func (w *gobWriter) commonType(name string, id int) {
+	w.uint(1)
+	w.uint(1)
+	w.bytes([]byte(name))
+	w.uint(1)
+	w.int(int64(id))
+	w.uint(0)
+}
+
+func (w *gobWriter) structType(name string, id int, fields []gobField) {
+	// поле StructT визначення типу
+	w.uint(3)
+	w.commonType(name, id)
+	w.uint(1)
+	w.uint(uint64(len(fields)))
+	for _, field := range fields {
+		w.uint(1)
+		w.bytes([]byte(field.name))
+		w.uint(1)
+		w.int(int64(field.id))
+		w.uint(0)
+	}
+	w.uint(0)
+	w.uint(0)
+}
+
+func (w *gobWriter) sliceType(name string, id int, elem int) {
+	// поле SliceT визначення типу
+	w.uint(2)
+	w.commonType(name, id)
+	w.uint(1)
+	w.int(int64(elem))
+	w.uint(0)
+	w.uint(0)
+}
+
+/*
+gobStruct записує поля структури: кожне поле з ненульовим значенням починається з різниці між його номером
+і номером попереднього записаного поля.
+*/
+type gobStruct struct {
+	last int
+}
+
+func (s *gobStruct) field(w *gobWriter, index int) {
+	w.uint(uint64(index - s.last + 1))
+	s.last = index + 1
+}
+
+func (s *gobStruct) bytes(w *gobWriter, index int, data []byte) {
+	if len(data) > 0 {
+		s.field(w, index)
+		w.bytes(data)
+	}
+}
+
+func (s *gobStruct) int(w *gobWriter, index int, x int64) {
+	if x != 0 {
+		s.field(w, index)
+		w.int(x)
+	}
+}
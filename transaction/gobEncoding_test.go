@@ -0,0 +1,153 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"testing"
+)
+
+/*
+gobEncode кодує транзакцію справжнім gob у типи з тими самими назвами і полями, що й у старого вузла.
+Ідентифікатори типів залежать від того, що тест кодував gob раніше, тому порівнювати слід з усіма розміщеннями.
+*/
+func gobEncode(t *testing.T, tx *Transaction) []byte {
+	t.Helper()
+
+	type TXInput struct {
+		TxId      []byte
+		VOut      int
+		Signature []byte
+		PubKey    []byte
+	}
+	type TXOutput struct {
+		Value      int
+		PubKeyHash []byte
+	}
+	type Transaction struct {
+		ID   []byte
+		VIn  []TXInput
+		VOut []TXOutput
+	}
+
+	legacy := Transaction{ID: tx.ID}
+	for _, vin := range tx.VIn {
+		legacy.VIn = append(legacy.VIn, TXInput{TxId: vin.TxId, VOut: vin.VOut, Signature: vin.Signature, PubKey: vin.PubKey})
+	}
+	for _, vout := range tx.VOut {
+		legacy.VOut = append(legacy.VOut, TXOutput{Value: vout.Value, PubKeyHash: vout.PubKeyHash})
+	}
+
+	var encoded bytes.Buffer
+	err := gob.NewEncoder(&encoded).Encode(&legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return encoded.Bytes()
+}
+
+func legacyTestTransactions() []*Transaction {
+	return []*Transaction{
+		{
+			VIn:  []TXInput{{TxId: []byte{}, VOut: -1, PubKey: []byte("The Times 03/Jan/2009 Chancellor on brink of second bailout for banks")}},
+			VOut: []TXOutput{{Value: 100, PubKeyHash: bytes.Repeat([]byte{0xab}, 20)}},
+		},
+		{
+			VIn: []TXInput{
+				{TxId: bytes.Repeat([]byte{1}, 32), VOut: 0, Signature: bytes.Repeat([]byte{2}, 64), PubKey: bytes.Repeat([]byte{3}, 64)},
+				{TxId: bytes.Repeat([]byte{4}, 32), VOut: 1000, Signature: bytes.Repeat([]byte{5}, 64), PubKey: bytes.Repeat([]byte{6}, 64)},
+			},
+			VOut: []TXOutput{
+				{Value: 30, PubKeyHash: bytes.Repeat([]byte{7}, 20)},
+				{Value: 0, PubKeyHash: bytes.Repeat([]byte{8}, 20)},
+				{Value: 1 << 40, PubKeyHash: bytes.Repeat([]byte{9}, 20)},
+			},
+		},
+		{
+			ID:   bytes.Repeat([]byte{10}, 32),
+			VIn:  []TXInput{{TxId: bytes.Repeat([]byte{11}, 32), VOut: 2, PubKey: bytes.Repeat([]byte{12}, 200)}},
+			VOut: []TXOutput{{Value: -5}},
+		},
+	}
+}
+
+func TestLegacyGobEncoding(t *testing.T) {
+	for i, tx := range legacyTestTransactions() {
+		expected := gobEncode(t, tx)
+
+		found := false
+		for _, layout := range legacyGobLayouts {
+			if bytes.Equal(layout.encode(tx), expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("transaction %d: gob encoding %x is not reproduced by any layout", i, expected)
+		}
+	}
+}
+
+func TestLegacyHash(t *testing.T) {
+	for i, tx := range legacyTestTransactions() {
+		// як і старий вузол, ідентифікатор обчислюється до підписування
+		unsigned := *tx
+		unsigned.ID = nil
+		unsigned.VIn = make([]TXInput, len(tx.VIn))
+		for j, vin := range tx.VIn {
+			vin.Signature = nil
+			unsigned.VIn[j] = vin
+		}
+		id := sha256.Sum256(gobEncode(t, &unsigned))
+
+		signed := *tx
+		signed.ID = id[:]
+		if !bytes.Equal(signed.Hash(), id[:]) {
+			t.Errorf("transaction %d: hash %x, want %x", i, signed.Hash(), id)
+		}
+
+		signed.VOut = append(signed.VOut, TXOutput{Value: 1})
+		if bytes.Equal(signed.Hash(), id[:]) {
+			t.Errorf("transaction %d: hash doesn't depend on outputs", i)
+		}
+	}
+}
+
+func TestLegacyFingerprints(t *testing.T) {
+	txs := legacyTestTransactions()
+	canonical := &Transaction{
+		Version: TxVersion,
+		VIn:     []TXInput{{TxId: bytes.Repeat([]byte{1}, 32), ScriptSig: []byte{1}, Sequence: MaxSequence}},
+		VOut:    []TXOutput{{Value: 1, Script: []byte{1}}},
+	}
+	txs = append(txs, canonical)
+
+	var expected [][]byte
+	for _, tx := range txs[:len(txs)-1] {
+		expected = append(expected, gobEncode(t, tx))
+	}
+	expected = append(expected, canonical.Serialize())
+
+	fingerprints, ok := LegacyFingerprints(txs, func(fingerprints [][]byte) bool {
+		for i := range fingerprints {
+			if !bytes.Equal(fingerprints[i], expected[i]) {
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		t.Fatal("gob encoding of block transactions is not reproduced by any layout")
+	}
+	for i := range fingerprints {
+		if !bytes.Equal(fingerprints[i], expected[i]) {
+			t.Errorf("fingerprint %d is %x, want %x", i, fingerprints[i], expected[i])
+		}
+	}
+
+	_, ok = LegacyFingerprints(txs, func(fingerprints [][]byte) bool { return false })
+	if ok {
+		t.Error("fingerprints match without matching layout")
+	}
+}
@@ -0,0 +1,202 @@
+package transaction
+
+import (
+	"blockchain1/lib/serialize"
+	"blockchain1/script"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"fmt"
+	"log"
+)
+
+/*
+swapEncodingVersion - версія кодування SwapContract.
+*/
+const swapEncodingVersion = 1
+
+/*
+SwapContract - одна сторона атомарного обміну: hash time-locked contract ( script.HTLCScript )
+і транзакція, яка блокує монети на хеш цього скрипта. Учасники обмінюються ним, щоб перевірити умови
+контракту в іншій мережі, а потім забрати монети секретом ( Redeem ) або повернути їх після LockTime ( Refund ).
+- Contract - скрипт HTLC.
+- Tx - транзакція з виходом script.PayToScriptHash на хеш Contract.
+*/
+type SwapContract struct {
+	Contract []byte
+	Tx       Transaction
+}
+
+/*
+NewSwapContract створює SwapContract для контракту contract, монети на який заблокувала транзакція tx.
+*/
+func NewSwapContract(contract []byte, tx Transaction) (*SwapContract, error) {
+	s := &SwapContract{Contract: contract, Tx: tx}
+
+	err := s.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+/*
+check перевіряє, що Contract є скриптом HTLC, а Tx має вихід на його хеш.
+*/
+func (s *SwapContract) check() error {
+	_, err := script.ExtractHTLC(s.Contract)
+	if err != nil {
+		return err
+	}
+
+	if vout, _ := s.Output(); vout < 0 {
+		return fmt.Errorf("transaction %x has no output to the contract", s.Tx.ID)
+	}
+
+	return nil
+}
+
+/*
+HTLC повертає умови контракту.
+*/
+func (s *SwapContract) HTLC() *script.HTLC {
+	htlc, err := script.ExtractHTLC(s.Contract)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return htlc
+}
+
+/*
+Output повертає індекс і вихід Tx, заблокований на хеш контракту, або -1, якщо такого виходу немає.
+*/
+func (s *SwapContract) Output() (int, *TXOutput) {
+	lockingScript := script.PayToScriptHash(script.Hash160(s.Contract))
+
+	for vout := range s.Tx.VOut {
+		if bytes.Equal(s.Tx.VOut[vout].LockingScript(), lockingScript) {
+			return vout, &s.Tx.VOut[vout]
+		}
+	}
+
+	return -1, nil
+}
+
+/*
+Redeem повертає підписану транзакцію, яка забирає вихід контракту на адресу отримувача, розкриваючи secret.
+Комісія fee віднімається від суми виходу. privetKey має бути ключем отримувача.
+*/
+func (s *SwapContract) Redeem(secret []byte, fee int, privetKey ecdsa.PrivateKey) (*Transaction, error) {
+	htlc := s.HTLC()
+
+	secretHash := sha256.Sum256(secret)
+	if !bytes.Equal(secretHash[:], htlc.SecretHash) {
+		return nil, fmt.Errorf("secret %x doesn't match secret hash %x", secret, htlc.SecretHash)
+	}
+
+	tx, pubKey, err := s.spend(htlc.RecipientHash, 0, fee, privetKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := tx.SignInput(privetKey, 0, s.Contract)
+	tx.VIn[0].ScriptSig = script.RedeemHTLCScript(signature, pubKey, secret, s.Contract)
+
+	return tx, nil
+}
+
+/*
+Refund повертає підписану транзакцію, яка повертає вихід контракту відправнику.
+LockTime транзакції дорівнює LockTime контракту, тому вузли приймуть її лише після нього ( див. IsFinal ).
+privetKey має бути ключем відправника.
+*/
+func (s *SwapContract) Refund(fee int, privetKey ecdsa.PrivateKey) (*Transaction, error) {
+	htlc := s.HTLC()
+
+	tx, pubKey, err := s.spend(htlc.RefundHash, uint32(htlc.LockTime), fee, privetKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := tx.SignInput(privetKey, 0, s.Contract)
+	tx.VIn[0].ScriptSig = script.RefundHTLCScript(signature, pubKey, s.Contract)
+
+	return tx, nil
+}
+
+/*
+spend створює непідписану транзакцію, яка витрачає вихід контракту на хеш публічного ключа pubKeyHash,
+і повертає її разом з публічним ключем privetKey, який має відповідати pubKeyHash.
+*/
+func (s *SwapContract) spend(pubKeyHash []byte, lockTime uint32, fee int, privetKey ecdsa.PrivateKey) (*Transaction, []byte, error) {
+	pubKey := append(privetKey.PublicKey.X.Bytes(), privetKey.PublicKey.Y.Bytes()...)
+	if !bytes.Equal(script.Hash160(pubKey), pubKeyHash) {
+		return nil, nil, fmt.Errorf("key %x can't spend the contract output", pubKey)
+	}
+
+	vout, out := s.Output()
+	if out.Value <= fee {
+		return nil, nil, fmt.Errorf("contract output value %d doesn't cover fee %d", out.Value, fee)
+	}
+
+	// CHECKLOCKTIMEVERIFY вимагає, щоб LockTime діяв для входу
+	sequence := uint32(MaxSequence)
+	if lockTime != 0 {
+		sequence = MaxSequence - 1
+	}
+
+	tx := Transaction{
+		Version: TxVersion,
+		VIn: []TXInput{{
+			TxId:     s.Tx.ID,
+			VOut:     vout,
+			Sequence: sequence,
+		}},
+		VOut:     []TXOutput{{Value: out.Value - fee, Script: script.PayToPubKeyHash(pubKeyHash)}},
+		LockTime: lockTime,
+	}
+	tx.ID = tx.Hash()
+
+	return &tx, pubKey, nil
+}
+
+/*
+Serialize кодує SwapContract для передачі іншому учаснику обміну: версію кодування, контракт і транзакцію.
+*/
+func (s *SwapContract) Serialize() []byte {
+	var w serialize.Writer
+
+	w.WriteUvarint(swapEncodingVersion)
+	w.WriteBytes(s.Contract)
+	s.Tx.Encode(&w)
+
+	return w.Bytes()
+}
+
+/*
+DecodeSwapContract розбирає SwapContract, закодований Serialize.
+*/
+func DecodeSwapContract(data []byte) (*SwapContract, error) {
+	var s SwapContract
+
+	r := serialize.NewReader(data)
+	version := r.ReadUvarint()
+	if version != swapEncodingVersion {
+		r.Fail(fmt.Errorf("unsupported swap contract encoding version %d", version))
+	}
+	s.Contract = r.ReadBytes()
+	s.Tx.Decode(r)
+
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("can't decode swap contract: %w", err)
+	}
+
+	err := s.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
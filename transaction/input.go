@@ -1,29 +1,57 @@
 package transaction
 
 import (
-	wal "blockchain1/wallet"
-	"bytes"
+	"blockchain1/script"
+	"encoding/hex"
 )
 
 /*
 TXInput представляє входи транзакції
 - TxId - ідентифікатор транзакції, яка містить вихід, який використовується для входу.
 - VOut - індекс вихідних даних в транзакції, яка містить вихід.
-- Signature - підпис, який вказує, що власник виходу погоджується з витратою.
-- PubKey - публічний ключ власника виходу.
+- Signature - підпис, який вказує, що власник виходу погоджується з витратою ( до ScriptTxVersion ).
+- PubKey - публічний ключ власника виходу ( до ScriptTxVersion ).
+- ScriptSig - скрипт розблокування, який відкриває скрипт блокування виходу ( див. UnlockingScript ).
+- Sequence - відносне блокування входу та ознака, чи діє LockTime транзакції ( див. lockTime.go ).
+У coinbase транзакції PubKey або ScriptSig містить довільні дані майнера.
 */
 type TXInput struct {
 	TxId      []byte
 	VOut      int
 	Signature []byte
 	PubKey    []byte
+	ScriptSig []byte
+	Sequence  uint32
 }
 
 /*
-UsesKey перевіряє, чи вхід використовує певний ключ для розблокування
+OutPoint посилання на вихід транзакції
+- TxId - ідентифікатор транзакції у вигляді hex рядка.
+- VOut - індекс виходу в цій транзакції.
 */
-func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
-	lockingHash := wal.HashPubKey(in.PubKey)
+type OutPoint struct {
+	TxId string
+	VOut int
+}
+
+/*
+OutPoint повертає посилання на вихід, який витрачає вхід
+*/
+func (in *TXInput) OutPoint() OutPoint {
+	return OutPoint{
+		TxId: hex.EncodeToString(in.TxId),
+		VOut: in.VOut,
+	}
+}
+
+/*
+UnlockingScript повертає скрипт розблокування входу. Вхід з Signature і PubKey
+відповідає стандартному скрипту script.SignatureScript.
+*/
+func (in *TXInput) UnlockingScript() ([]byte, error) {
+	if len(in.ScriptSig) > 0 || (len(in.Signature) == 0 && len(in.PubKey) == 0) {
+		return in.ScriptSig, nil
+	}
 
-	return bytes.Compare(lockingHash, pubKeyHash) == 0
+	return script.NewBuilder().AddData(in.Signature).AddData(in.PubKey).Script()
 }
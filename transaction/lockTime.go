@@ -0,0 +1,101 @@
+package transaction
+
+import "fmt"
+
+/*
+Поля часових блокувань, як у Bitcoin ( BIP 65, BIP 68 ):
+- LockTime транзакції - висота блоку або, якщо не менший за LockTimeThreshold, unix час,
+починаючи з якого транзакцію можна включити в блок. Він діє, лише якщо хоча б один вхід має Sequence, меншу за MaxSequence.
+- Sequence входу без SequenceLockTimeDisableFlag задає відносне блокування: вихід, який витрачає вхід,
+можна витратити лише через SequenceLockTimeMask блоків після блоку, що його створив, або, з SequenceLockTimeTypeFlag,
+через стільки ж проміжків по 2^SequenceLockTimeGranularity секунд медіанного часу.
+Відносні блокування діють у транзакціях, починаючи з LockTimeTxVersion.
+*/
+const (
+	MaxSequence                 = 0xffffffff
+	SequenceLockTimeDisableFlag = 1 << 31
+	SequenceLockTimeTypeFlag    = 1 << 22
+	SequenceLockTimeMask        = 0x0000ffff
+	SequenceLockTimeGranularity = 9
+)
+
+/*
+IsFinal перевіряє, що транзакцію можна включити в блок ctx: її LockTime вже минув
+або жоден вхід не вмикає LockTime.
+*/
+func (tx *Transaction) IsFinal(ctx SpendContext) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	if tx.LockTime < LockTimeThreshold {
+		if int64(tx.LockTime) < int64(ctx.Height) {
+			return true
+		}
+	} else if int64(tx.LockTime) < ctx.MedianTime {
+		return true
+	}
+
+	for _, vin := range tx.VIn {
+		if vin.Sequence != MaxSequence {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+HasRelativeLocks перевіряє, чи має транзакція входи з відносним блокуванням.
+*/
+func (tx *Transaction) HasRelativeLocks() bool {
+	if tx.Version < LockTimeTxVersion || tx.IsCoinbase() {
+		return false
+	}
+
+	for _, vin := range tx.VIn {
+		if vin.Sequence&SequenceLockTimeDisableFlag == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+CheckSequenceLocks перевіряє, що відносні блокування всіх входів минули в блоці ctx.
+prevBlocks - блоки, які створили виходи, що витрачають входи: від їх висоти та медіанного часу
+відраховується блокування.
+*/
+func (tx *Transaction) CheckSequenceLocks(prevBlocks map[OutPoint]SpendContext, ctx SpendContext) error {
+	if !tx.HasRelativeLocks() {
+		return nil
+	}
+
+	for inID, vin := range tx.VIn {
+		if vin.Sequence&SequenceLockTimeDisableFlag != 0 {
+			continue
+		}
+
+		prevBlock, ok := prevBlocks[vin.OutPoint()]
+		if !ok {
+			return fmt.Errorf("input %d spends unknown output", inID)
+		}
+
+		value := int64(vin.Sequence & SequenceLockTimeMask)
+		if vin.Sequence&SequenceLockTimeTypeFlag != 0 {
+			minTime := prevBlock.MedianTime + value<<SequenceLockTimeGranularity - 1
+			if minTime >= ctx.MedianTime {
+				return fmt.Errorf("input %d is locked until median time %d", inID, minTime+1)
+			}
+			continue
+		}
+
+		minHeight := int64(prevBlock.Height) + value - 1
+		if minHeight >= int64(ctx.Height) {
+			return fmt.Errorf("input %d is locked until height %d", inID, minHeight+1)
+		}
+	}
+
+	return nil
+}
@@ -1,25 +1,35 @@
 package transaction
 
 import (
-	"blockchain1/lib/base58"
+	"blockchain1/script"
+	wal "blockchain1/wallet"
 	"bytes"
-	"encoding/gob"
 	"log"
 )
 
 /*
 TXOutput описує вихід транзакції
 - Value - кількість монет, яку видає вихід
-- PubKeyHash - хеш публічного ключа отримувача
+- PubKeyHash - хеш публічного ключа отримувача у транзакціях версій до ScriptTxVersion
+- Script - скрипт блокування: умова, яку має виконати вхід, щоб витратити вихід ( див. LockingScript )
 */
 type TXOutput struct {
 	Value      int
 	PubKeyHash []byte
+	Script     []byte
 }
 
-// TXOutputs масив виходів
+/*
+TXOutputs масив виходів
+- Outputs - виходи транзакції.
+- Indexes - індекси виходів у вихідній транзакції, Indexes[i] відповідає Outputs[i].
+Після витрати частини виходів їх позиції в Outputs зсуваються, тому індекси зберігаються окремо.
+- Block - висота і медіанний час блоку, який створив виходи, від них відраховуються відносні блокування.
+*/
 type TXOutputs struct {
 	Outputs []TXOutput
+	Indexes []int
+	Block   SpendContext
 }
 
 /*
@@ -28,8 +38,7 @@ NewTXOutput створює новий вихід
 */
 func NewTXOutput(value int, address string) *TXOutput {
 	txo := &TXOutput{
-		value,
-		nil,
+		Value: value,
 	}
 	txo.Lock([]byte(address))
 
@@ -37,41 +46,37 @@ func NewTXOutput(value int, address string) *TXOutput {
 }
 
 /*
-Lock підписує ( блокує ) вивід
+Lock підписує ( блокує ) вивід скриптом блокування адреси ( див. wallet.PayToAddrScript )
 */
 func (out *TXOutput) Lock(address []byte) {
-	pubKeyHash := base58.Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	out.PubKeyHash = pubKeyHash
+	lockingScript, err := wal.PayToAddrScript(string(address))
+	if err != nil {
+		log.Panic(err)
+	}
+	out.PubKeyHash = nil
+	out.Script = lockingScript
 }
 
 /*
-IsLockedWithKey  перевіряє, чи може вивід бути використаний власником публічного ключа
+LockingScript повертає скрипт блокування виходу. Вихід, заблокований на PubKeyHash, відповідає
+стандартному скрипту script.PayToPubKeyHash. Якщо PubKeyHash має інший розмір, ніж хеш публічного ключа,
+такий вихід не може витратити жоден ключ, тому його скрипт - OP_RETURN.
 */
-func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
-}
-
-func (outs TXOutputs) Serialize() []byte {
-	var buff bytes.Buffer
-
-	enc := gob.NewEncoder(&buff)
-	err := enc.Encode(outs)
-	if err != nil {
-		log.Panic(err)
+func (out *TXOutput) LockingScript() []byte {
+	if len(out.Script) > 0 {
+		return out.Script
+	}
+	if len(out.PubKeyHash) != script.PubKeyHashSize {
+		return []byte{script.OpReturn}
 	}
 
-	return buff.Bytes()
+	return script.PayToPubKeyHash(out.PubKeyHash)
 }
 
-func DeserializeOutputs(data []byte) TXOutputs {
-	var outputs TXOutputs
-
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	err := dec.Decode(&outputs)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return outputs
+/*
+IsLockedWithScript перевіряє, чи заблоковано вивід скриптом lockingScript, наприклад скриптом адреси
+( див. wallet.PayToAddrScript ). Вихід, заблокований на PubKeyHash, відповідає скрипту script.PayToPubKeyHash.
+*/
+func (out *TXOutput) IsLockedWithScript(lockingScript []byte) bool {
+	return bytes.Equal(out.LockingScript(), lockingScript)
 }
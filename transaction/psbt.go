@@ -0,0 +1,394 @@
+package transaction
+
+import (
+	"blockchain1/lib/serialize"
+	"blockchain1/script"
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+)
+
+/*
+psbtEncodingVersion - версія кодування PSBT.
+*/
+const psbtEncodingVersion = 1
+
+/*
+PSBT ( partially signed transaction ) - непідписана транзакція разом з усім, що потрібно для її підпису
+без бази даних: виходами, які вона витрачає, redeem script виходів на хеш скрипта і зібраними підписами.
+Її можна передати на машину з ключами ( Sign ), об'єднати підписи кількох учасників ( Combine )
+і, коли підписів достатньо, скласти готову транзакцію ( Finalize ).
+- Tx - транзакція без скриптів розблокування.
+- Inputs - дані для підпису кожного входу Tx, в порядку входів.
+*/
+type PSBT struct {
+	Tx     Transaction
+	Inputs []PSBTInput
+}
+
+/*
+PSBTInput - дані для підпису входу PSBT:
+- PrevOutput - вихід, який витрачає вхід.
+- RedeemScript - скрипт, хеш якого блокує PrevOutput ( script.PayToScriptHash ), або nil.
+- Signatures - підписи входу, зібрані учасниками.
+*/
+type PSBTInput struct {
+	PrevOutput   TXOutput
+	RedeemScript []byte
+	Signatures   []PartialSig
+}
+
+/*
+PartialSig - підпис входу ключем PubKey.
+*/
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+/*
+NewPSBT створює PSBT без підписів для транзакції tx, входи якої витрачають виходи prevOutputs.
+*/
+func NewPSBT(tx Transaction, prevOutputs []TXOutput) (*PSBT, error) {
+	p := &PSBT{Tx: tx}
+	for _, out := range prevOutputs {
+		p.Inputs = append(p.Inputs, PSBTInput{PrevOutput: out})
+	}
+
+	err := p.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+/*
+check перевіряє, що PSBT узгоджений: транзакція ще не підписана і для кожного входу є вихід,
+а redeem script відповідає хешу, на який заблоковано вихід.
+*/
+func (p *PSBT) check() error {
+	if p.Tx.IsCoinbase() {
+		return fmt.Errorf("coinbase transaction can't be signed")
+	}
+	if p.Tx.Version < ScriptTxVersion {
+		return fmt.Errorf("transaction version %d doesn't support scripts", p.Tx.Version)
+	}
+	if len(p.Inputs) != len(p.Tx.VIn) {
+		return fmt.Errorf("transaction has %d inputs, but %d previous outputs", len(p.Tx.VIn), len(p.Inputs))
+	}
+
+	for inID, vin := range p.Tx.VIn {
+		if len(vin.ScriptSig) > 0 || len(vin.Signature) > 0 || len(vin.PubKey) > 0 {
+			return fmt.Errorf("input %d is already signed", inID)
+		}
+
+		in := p.Inputs[inID]
+		if in.RedeemScript != nil && !bytes.Equal(script.ExtractScriptHash(in.PrevOutput.LockingScript()), script.Hash160(in.RedeemScript)) {
+			return fmt.Errorf("input %d: redeem script doesn't match the previous output", inID)
+		}
+	}
+
+	return nil
+}
+
+/*
+AddRedeemScript додає redeem script до входів, які витрачають виходи на його хеш,
+і повертає кількість таких входів.
+*/
+func (p *PSBT) AddRedeemScript(redeemScript []byte) int {
+	added := 0
+	for inID := range p.Inputs {
+		in := &p.Inputs[inID]
+		if bytes.Equal(script.ExtractScriptHash(in.PrevOutput.LockingScript()), script.Hash160(redeemScript)) {
+			in.RedeemScript = redeemScript
+			added++
+		}
+	}
+
+	return added
+}
+
+/*
+scriptCode повертає скрипт, який перевіряє підписи входу inID: redeem script для виходу на хеш скрипта
+або скрипт блокування виходу. Для виходу на хеш скрипта без redeem script повертає nil.
+*/
+func (p *PSBT) scriptCode(inID int) []byte {
+	in := p.Inputs[inID]
+	lockingScript := in.PrevOutput.LockingScript()
+	if script.ExtractScriptHash(lockingScript) != nil {
+		return in.RedeemScript
+	}
+
+	return lockingScript
+}
+
+/*
+signers повертає публічні ключі, підписи яких відкривають скрипт scriptCode:
+ключ, хеш якого блокує script.PayToPubKeyHash, або ключі script.MultiSigScript.
+Для інших скриптів повертає nil, бо PSBT не знає, як їх підписувати.
+*/
+func signers(scriptCode []byte) ([]byte, [][]byte) {
+	if pubKeyHash := script.ExtractPubKeyHash(scriptCode); pubKeyHash != nil {
+		return pubKeyHash, nil
+	}
+
+	_, pubKeys, err := script.ExtractMultiSig(scriptCode)
+	if err != nil {
+		return nil, nil
+	}
+
+	return nil, pubKeys
+}
+
+/*
+canSign перевіряє, чи підпис ключем pubKey потрібен для скрипта scriptCode.
+*/
+func canSign(scriptCode []byte, pubKey []byte) bool {
+	pubKeyHash, pubKeys := signers(scriptCode)
+	if pubKeyHash != nil {
+		return bytes.Equal(script.Hash160(pubKey), pubKeyHash)
+	}
+
+	for _, key := range pubKeys {
+		if bytes.Equal(key, pubKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Sign підписує ключем privetKey всі входи, які він може відкрити, і повертає їх кількість.
+Підписи, зроблені цим ключем раніше, замінюються.
+*/
+func (p *PSBT) Sign(privetKey ecdsa.PrivateKey) int {
+	pubKey := append(privetKey.PublicKey.X.Bytes(), privetKey.PublicKey.Y.Bytes()...)
+
+	signed := 0
+	for inID := range p.Inputs {
+		scriptCode := p.scriptCode(inID)
+		if scriptCode == nil || !canSign(scriptCode, pubKey) {
+			continue
+		}
+
+		signature := p.Tx.SignInput(privetKey, inID, scriptCode)
+		p.Inputs[inID].addSignature(PartialSig{PubKey: pubKey, Signature: signature})
+		signed++
+	}
+
+	return signed
+}
+
+/*
+addSignature додає підпис входу або замінює підпис тим самим ключем.
+*/
+func (in *PSBTInput) addSignature(sig PartialSig) {
+	for i := range in.Signatures {
+		if bytes.Equal(in.Signatures[i].PubKey, sig.PubKey) {
+			in.Signatures[i] = sig
+			return
+		}
+	}
+
+	in.Signatures = append(in.Signatures, sig)
+}
+
+/*
+Combine додає до PSBT підписи і redeem script з other - копії тієї самої транзакції,
+підписаної іншими учасниками.
+*/
+func (p *PSBT) Combine(other *PSBT) error {
+	if !bytes.Equal(p.Tx.Hash(), other.Tx.Hash()) {
+		return fmt.Errorf("can't combine PSBT of different transactions %x and %x", p.Tx.ID, other.Tx.ID)
+	}
+
+	for inID := range p.Inputs {
+		in := &p.Inputs[inID]
+		if !bytes.Equal(in.PrevOutput.LockingScript(), other.Inputs[inID].PrevOutput.LockingScript()) ||
+			in.PrevOutput.Value != other.Inputs[inID].PrevOutput.Value {
+			return fmt.Errorf("input %d spends different outputs in combined PSBT", inID)
+		}
+
+		if in.RedeemScript == nil {
+			in.RedeemScript = other.Inputs[inID].RedeemScript
+		}
+		for _, sig := range other.Inputs[inID].Signatures {
+			in.addSignature(sig)
+		}
+	}
+
+	return nil
+}
+
+/*
+validSignatures повертає підписи входу inID, які проходять перевірку, у порядку ключів scriptCode.
+*/
+func (p *PSBT) validSignatures(inID int, scriptCode []byte) []PartialSig {
+	checker := &sigChecker{tx: &p.Tx, inID: inID}
+
+	var valid []PartialSig
+	pubKeyHash, pubKeys := signers(scriptCode)
+	if pubKeyHash != nil {
+		for _, sig := range p.Inputs[inID].Signatures {
+			if canSign(scriptCode, sig.PubKey) && checker.CheckSig(sig.Signature, sig.PubKey, scriptCode) {
+				return []PartialSig{sig}
+			}
+		}
+		return nil
+	}
+
+	for _, key := range pubKeys {
+		for _, sig := range p.Inputs[inID].Signatures {
+			if bytes.Equal(sig.PubKey, key) && checker.CheckSig(sig.Signature, sig.PubKey, scriptCode) {
+				valid = append(valid, sig)
+				break
+			}
+		}
+	}
+
+	return valid
+}
+
+/*
+required повертає кількість підписів, потрібних скрипту scriptCode, або 0, якщо PSBT не вміє його підписувати.
+*/
+func required(scriptCode []byte) int {
+	if script.ExtractPubKeyHash(scriptCode) != nil {
+		return 1
+	}
+
+	m, _, err := script.ExtractMultiSig(scriptCode)
+	if err != nil {
+		return 0
+	}
+
+	return m
+}
+
+/*
+Signed повертає кількість правильних підписів входу inID і кількість потрібних підписів.
+Якщо вхід не можна підписати ( невідомий скрипт або немає redeem script ), потрібних підписів 0.
+*/
+func (p *PSBT) Signed(inID int) (int, int) {
+	scriptCode := p.scriptCode(inID)
+	if scriptCode == nil {
+		return 0, 0
+	}
+
+	return len(p.validSignatures(inID, scriptCode)), required(scriptCode)
+}
+
+/*
+Finalize повертає готову транзакцію зі скриптами розблокування, складеними з правильних підписів:
+script.SignatureScript для виходів на хеш ключа, перші M підписів у порядку ключів для multisig,
+і redeem script останнім елементом для виходів на хеш скрипта.
+Повертає помилку, якщо якомусь входу бракує підписів.
+*/
+func (p *PSBT) Finalize() (*Transaction, error) {
+	tx := p.Tx
+	tx.VIn = append([]TXInput{}, p.Tx.VIn...)
+
+	for inID, in := range p.Inputs {
+		scriptCode := p.scriptCode(inID)
+		if scriptCode == nil {
+			return nil, fmt.Errorf("input %d: redeem script is unknown", inID)
+		}
+		m := required(scriptCode)
+		if m == 0 {
+			return nil, fmt.Errorf("input %d: can't sign script %s", inID, script.Disassemble(scriptCode))
+		}
+
+		sigs := p.validSignatures(inID, scriptCode)
+		if len(sigs) < m {
+			return nil, fmt.Errorf("input %d has %d of %d signatures", inID, len(sigs), m)
+		}
+
+		b := script.NewBuilder()
+		if pubKeyHash, _ := signers(scriptCode); pubKeyHash != nil {
+			b.AddData(sigs[0].Signature).AddData(sigs[0].PubKey)
+		} else {
+			for _, sig := range sigs[:m] {
+				b.AddData(sig.Signature)
+			}
+		}
+		if in.RedeemScript != nil {
+			b.AddData(in.RedeemScript)
+		}
+
+		var err error
+		tx.VIn[inID].ScriptSig, err = b.Script()
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", inID, err)
+		}
+	}
+
+	return &tx, nil
+}
+
+/*
+Serialize кодує PSBT для передачі між учасниками: версію кодування, транзакцію і дані входів.
+*/
+func (p *PSBT) Serialize() []byte {
+	var w serialize.Writer
+
+	w.WriteUvarint(psbtEncodingVersion)
+	p.Tx.Encode(&w)
+
+	w.WriteUvarint(uint64(len(p.Inputs)))
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		in.PrevOutput.Encode(&w)
+		w.WriteBytes(in.RedeemScript)
+
+		w.WriteUvarint(uint64(len(in.Signatures)))
+		for _, sig := range in.Signatures {
+			w.WriteBytes(sig.PubKey)
+			w.WriteBytes(sig.Signature)
+		}
+	}
+
+	return w.Bytes()
+}
+
+/*
+DecodePSBT розбирає PSBT, закодований Serialize.
+*/
+func DecodePSBT(data []byte) (*PSBT, error) {
+	var p PSBT
+
+	r := serialize.NewReader(data)
+	version := r.ReadUvarint()
+	if version != psbtEncodingVersion {
+		r.Fail(fmt.Errorf("unsupported PSBT encoding version %d", version))
+	}
+	p.Tx.Decode(r)
+
+	for i, count := 0, r.ReadCount(); i < count; i++ {
+		var in PSBTInput
+		in.PrevOutput.Decode(r)
+		in.RedeemScript = r.ReadBytes()
+		if len(in.RedeemScript) == 0 {
+			in.RedeemScript = nil
+		}
+
+		for j, sigs := 0, r.ReadCount(); j < sigs; j++ {
+			pubKey := r.ReadBytes()
+			signature := r.ReadBytes()
+			in.Signatures = append(in.Signatures, PartialSig{PubKey: pubKey, Signature: signature})
+		}
+		p.Inputs = append(p.Inputs, in)
+	}
+
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("can't decode PSBT: %w", err)
+	}
+
+	err := p.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
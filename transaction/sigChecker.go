@@ -0,0 +1,103 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"math/big"
+)
+
+/*
+LockTimeThreshold - часи блокування, менші за це значення, є висотою блоку, а більші або рівні - unix часом.
+*/
+const LockTimeThreshold = 500000000
+
+/*
+SpendContext описує блок, до якого увійде транзакція, що витрачає виходи:
+- Height - висота блоку.
+- MedianTime - медіанний час блоків перед ним, з яким порівнюються часові блокування в unix часі.
+Так само описується блок, який створив виходи: від нього відраховуються відносні блокування.
+*/
+type SpendContext struct {
+	Height     int
+	MedianTime int64
+}
+
+/*
+sigChecker перевіряє підписи і часові блокування скриптів входу inID транзакції tx ( див. script.Checker ).
+*/
+type sigChecker struct {
+	tx   *Transaction
+	inID int
+	ctx  SpendContext
+}
+
+/*
+CheckSig перевіряє підпис r||s входу публічним ключем X||Y на кривій P256.
+*/
+func (c *sigChecker) CheckSig(sig []byte, pubKey []byte, scriptCode []byte) bool {
+	if len(sig) == 0 || len(pubKey) == 0 {
+		return false
+	}
+
+	r := big.Int{}
+	s := big.Int{}
+	sigLen := len(sig)
+	r.SetBytes(sig[:(sigLen / 2)])
+	s.SetBytes(sig[(sigLen / 2):])
+
+	x := big.Int{}
+	y := big.Int{}
+	keyLen := len(pubKey)
+	x.SetBytes(pubKey[:(keyLen / 2)])
+	y.SetBytes(pubKey[(keyLen / 2):])
+
+	dataToVerify := c.tx.SignatureHash(c.inID, scriptCode)
+
+	rawPubKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
+
+	return ecdsa.Verify(&rawPubKey, dataToVerify, &r, &s)
+}
+
+/*
+CheckLockTime перевіряє, що LockTime транзакції того ж типу ( висота або час ), не менший за lockTime
+і діє для входу, тобто Sequence входу менша за MaxSequence. Сам LockTime перевіряється під час включення
+транзакції в блок ( див. IsFinal ).
+Транзакції версій до LockTimeTxVersion не мають LockTime, для них lockTime порівнюється з блоком,
+до якого увійде транзакція: його висотою або медіанним часом перед ним.
+*/
+func (c *sigChecker) CheckLockTime(lockTime int64) bool {
+	if c.tx.Version < LockTimeTxVersion {
+		if lockTime < LockTimeThreshold {
+			return int64(c.ctx.Height) >= lockTime
+		}
+
+		return c.ctx.MedianTime >= lockTime
+	}
+
+	txLockTime := int64(c.tx.LockTime)
+	if (lockTime < LockTimeThreshold) != (txLockTime < LockTimeThreshold) {
+		return false
+	}
+
+	return lockTime <= txLockTime && c.tx.VIn[c.inID].Sequence != MaxSequence
+}
+
+/*
+CheckSequence перевіряє, що відносне блокування входу того ж типу ( блоки або час ) і не коротше за sequence.
+Саме блокування перевіряється під час включення транзакції в блок ( див. CheckSequenceLocks ).
+*/
+func (c *sigChecker) CheckSequence(sequence int64) bool {
+	txSequence := int64(c.tx.VIn[c.inID].Sequence)
+	if c.tx.Version < LockTimeTxVersion || txSequence&SequenceLockTimeDisableFlag != 0 {
+		return false
+	}
+
+	mask := int64(SequenceLockTimeTypeFlag | SequenceLockTimeMask)
+	sequence &= mask
+	txSequence &= mask
+	if (sequence < SequenceLockTimeTypeFlag) != (txSequence < SequenceLockTimeTypeFlag) {
+		return false
+	}
+
+	return sequence <= txSequence
+}
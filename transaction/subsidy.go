@@ -0,0 +1,42 @@
+package transaction
+
+import (
+	"blockchain1/chaincfg"
+)
+
+/*
+CalcBlockSubsidy повертає винагороду за блок на висоті height без урахування комісій.
+Винагорода зменшується вдвічі кожні SubsidyHalvingInterval блоків активної мережі, поки не стане нульовою.
+*/
+func CalcBlockSubsidy(height int) int {
+	params := chaincfg.ActiveNetParams
+	if params.SubsidyHalvingInterval <= 0 {
+		return params.InitialSubsidy
+	}
+
+	halvings := height / params.SubsidyHalvingInterval
+	if halvings >= 63 {
+		return 0
+	}
+
+	return params.InitialSubsidy >> uint(halvings)
+}
+
+/*
+MaxSupply повертає кількість монет, яка буде створена за весь час існування ланцюга,
+якщо кожен майнер забирає повну винагороду за блок.
+Якщо винагорода не зменшується ( SubsidyHalvingInterval <= 0 ), кількість необмежена і повертається -1.
+*/
+func MaxSupply() int {
+	interval := chaincfg.ActiveNetParams.SubsidyHalvingInterval
+	if interval <= 0 {
+		return -1
+	}
+
+	supply := 0
+	for height := 0; CalcBlockSubsidy(height) > 0; height += interval {
+		supply += CalcBlockSubsidy(height) * interval
+	}
+
+	return supply
+}
@@ -1,42 +1,36 @@
 package transaction
 
 import (
-	"bytes"
+	"blockchain1/script"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
-	"encoding/gob"
-	"encoding/hex"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 )
 
-/*
-subsidy - кількість монет, яку майнер отримує за добування genesis блоку
-*/
-const (
-	subsidy = 100
-)
-
 /*
 Transaction структура, що представляє транзакцію в блокчейні.
+- Version - версія транзакції, від якої залежить, як обчислюються її ідентифікатор і підписи ( див. TxVersion ).
 - ID - ідентифікатор транзакції.
 - VIn - вхідні дані транзакції, які вказують на виходи попередніх транзакцій.
 - VOut - вихідні дані транзакції, які вказують на суми та адреси отримувачів.
+- LockTime - висота блоку або unix час, до якого транзакцію не можна включити в блок ( див. IsFinal ).
 */
 type Transaction struct {
-	ID   []byte
-	VIn  []TXInput
-	VOut []TXOutput
+	Version  int
+	ID       []byte
+	VIn      []TXInput
+	VOut     []TXOutput
+	LockTime uint32
 }
 
 /*
-NewCoinbaseTX створює нову транзакцію Coinbase.
+NewCoinbaseTX створює нову транзакцію Coinbase для блоку на висоті height.
+Майнер отримує винагороду за блок ( див. CalcBlockSubsidy ) разом з комісіями fees транзакцій, включених у блок.
 */
-func NewCoinbaseTX(to string, data string) *Transaction {
+func NewCoinbaseTX(to string, data string, height int, fees int) *Transaction {
 	if data == "" {
 		randData := make([]byte, 20)
 		_, err := rand.Read(randData)
@@ -50,59 +44,120 @@ func NewCoinbaseTX(to string, data string) *Transaction {
 	txIn := TXInput{
 		TxId:      []byte{},
 		VOut:      -1,
-		Signature: nil,
-		PubKey:    []byte(data),
+		ScriptSig: []byte(data),
+		Sequence:  MaxSequence,
 	}
-	txOut := NewTXOutput(subsidy, to)
+	txOut := NewTXOutput(CalcBlockSubsidy(height)+fees, to)
 
 	tx := Transaction{
-		ID:   nil,
-		VIn:  []TXInput{txIn},
-		VOut: []TXOutput{*txOut},
+		Version: TxVersion,
+		ID:      nil,
+		VIn:     []TXInput{txIn},
+		VOut:    []TXOutput{*txOut},
 	}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
+/*
+CoinbaseData повертає довільні дані coinbase транзакції: ScriptSig її входу,
+а в транзакціях версій до ScriptTxVersion - PubKey.
+*/
+func (tx *Transaction) CoinbaseData() []byte {
+	if !tx.IsCoinbase() {
+		log.Panic("ERROR: Transaction is not coinbase")
+	}
+
+	if tx.Version < ScriptTxVersion {
+		return tx.VIn[0].PubKey
+	}
+
+	return tx.VIn[0].ScriptSig
+}
+
+/*
+SetCoinbaseData замінює довільні дані coinbase транзакції і перераховує її ідентифікатор.
+Майнер дописує до даних extra nonce, коли вичерпано всі значення nonce блоку.
+*/
+func (tx *Transaction) SetCoinbaseData(data []byte) {
+	if !tx.IsCoinbase() {
+		log.Panic("ERROR: Transaction is not coinbase")
+	}
+
+	if tx.Version < ScriptTxVersion {
+		tx.VIn[0].PubKey = data
+	} else {
+		tx.VIn[0].ScriptSig = data
+	}
+	tx.ID = tx.Hash()
+}
+
 /*
 Sing підписує всі входи транзакції
-Метод приймає закритий ключ і масив попередніх транзакцій
+Метод приймає закритий ключ і виходи, які витрачають входи транзакції.
+Виходи мають бути заблоковані на хеш публічного ключа privetKey ( script.PayToPubKeyHash ),
+входи з іншими скриптами блокування підписуються через SignInput.
 */
-func (tx *Transaction) Sing(privetKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+func (tx *Transaction) Sing(privetKey ecdsa.PrivateKey, prevOutputs map[OutPoint]TXOutput) {
 	if tx.IsCoinbase() {
 		return
 	}
 
 	for _, vin := range tx.VIn {
-		if prevTXs[hex.EncodeToString(vin.TxId)].ID == nil {
+		if _, ok := prevOutputs[vin.OutPoint()]; !ok {
 			log.Panic("ERROR: Previous transaction is not correct")
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-
-	for inID, vin := range txCopy.VIn {
-		prevTx := prevTXs[hex.EncodeToString(vin.TxId)]
-		txCopy.VIn[inID].Signature = nil
-		txCopy.VIn[inID].PubKey = prevTx.VOut[vin.VOut].PubKeyHash
+	pubKey := append(privetKey.PublicKey.X.Bytes(), privetKey.PublicKey.Y.Bytes()...)
 
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
+	for inID, vin := range tx.VIn {
+		prevOut := prevOutputs[vin.OutPoint()]
+		signature := tx.SignInput(privetKey, inID, prevOut.LockingScript())
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privetKey, []byte(dataToSign))
-		if err != nil {
-			log.Panic(err)
+		if tx.Version < ScriptTxVersion {
+			tx.VIn[inID].Signature = signature
+		} else {
+			tx.VIn[inID].ScriptSig = script.SignatureScript(signature, pubKey)
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
+	}
+
+}
+
+/*
+SignInput повертає підпис входу inID, який витрачає вихід зі скриптом блокування scriptCode.
+*/
+func (tx *Transaction) SignInput(privetKey ecdsa.PrivateKey, inID int, scriptCode []byte) []byte {
+	dataToSign := tx.SignatureHash(inID, scriptCode)
+
+	r, s, err := ecdsa.Sign(rand.Reader, &privetKey, dataToSign)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return append(r.Bytes(), s.Bytes()...)
+}
 
-		tx.VIn[inID].Signature = signature
-		txCopy.VIn[inID].PubKey = nil
+/*
+SignatureHash повертає дані, які підписує вхід inID, що витрачає вихід зі скриптом блокування scriptCode:
+копію транзакції без підписів, в якій вхід inID містить scriptCode. У транзакціях версій до ScriptTxVersion
+замість скрипта вхід містить хеш публічного ключа в полі PubKey, як і до появи скриптів.
+*/
+func (tx *Transaction) SignatureHash(inID int, scriptCode []byte) []byte {
+	txCopy := tx.TrimmedCopy()
+
+	if tx.Version < ScriptTxVersion {
+		txCopy.VIn[inID].PubKey = script.ExtractPubKeyHash(scriptCode)
+	} else {
+		txCopy.VIn[inID].ScriptSig = scriptCode
 	}
 
+	return txCopy.sigHash()
 }
 
 /*
-TrimmedCopy формуємо копію транзакції без підписів та публічних ключів
+TrimmedCopy формуємо копію транзакції без підписів, публічних ключів та скриптів розблокування
 */
 func (tx *Transaction) TrimmedCopy() Transaction {
 	var inputs []TXInput
@@ -114,6 +169,7 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 			VOut:      vin.VOut,
 			Signature: nil,
 			PubKey:    nil,
+			Sequence:  vin.Sequence,
 		})
 	}
 
@@ -121,32 +177,76 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 		outputs = append(outputs, TXOutput{
 			Value:      vOut.Value,
 			PubKeyHash: vOut.PubKeyHash,
+			Script:     vOut.Script,
 		})
 	}
 
 	txCopy := Transaction{
-		ID:   tx.ID,
-		VIn:  inputs,
-		VOut: outputs,
+		Version:  tx.Version,
+		ID:       tx.ID,
+		VIn:      inputs,
+		VOut:     outputs,
+		LockTime: tx.LockTime,
 	}
 
 	return txCopy
 }
 
 /*
-Hash повертає хеш транзакції
+Hash повертає хеш транзакції, який використовується як її ідентифікатор.
+Ідентифікатор обчислюється до підписування, тому підписи і скрипти розблокування входів не враховуються.
+Дані coinbase транзакції в ScriptSig входять до ідентифікатора.
+Ідентифікатор legacy транзакції обчислюється з її gob кодування ( див. legacyHash ).
 */
 func (tx *Transaction) Hash() []byte {
 	var hash [32]byte
 
 	txCopy := *tx
 	txCopy.ID = []byte{}
+	txCopy.VIn = make([]TXInput, len(tx.VIn))
+	for i, vin := range tx.VIn {
+		vin.Signature = nil
+		if !tx.IsCoinbase() {
+			vin.ScriptSig = nil
+		}
+		txCopy.VIn[i] = vin
+	}
+
+	if tx.Version == LegacyTxVersion {
+		return txCopy.legacyHash(tx.ID)
+	}
 
 	hash = sha256.Sum256(txCopy.Serialize())
 
 	return hash[:]
 }
 
+/*
+Fingerprint повертає детерміноване представлення всієї транзакції разом з підписами для хешування:
+канонічне кодування, а для legacy транзакцій - gob кодування процесу, який ще нічого не кодував gob.
+Legacy блоки могли кодувати legacy транзакції інакше ( див. LegacyFingerprints ).
+*/
+func (tx *Transaction) Fingerprint() []byte {
+	if tx.Version == LegacyTxVersion {
+		return legacyGobLayouts[0].encode(tx)
+	}
+
+	return tx.Serialize()
+}
+
+/*
+sigHash повертає дані, які підписує вхід, для копії транзакції, підготовленої в SignatureHash.
+*/
+func (tx *Transaction) sigHash() []byte {
+	if tx.Version == LegacyTxVersion {
+		return []byte(fmt.Sprintf("%x\n", newLegacyTransaction(tx)))
+	}
+
+	hash := sha256.Sum256(tx.Serialize())
+
+	return hash[:]
+}
+
 // String returns a human-readable representation of a transaction
 func (tx *Transaction) toString() string {
 	var lines []string
@@ -155,89 +255,65 @@ func (tx *Transaction) toString() string {
 		lines = append(lines, fmt.Sprintf("     Input %d:", i))
 		lines = append(lines, fmt.Sprintf("       TXID:      %x", input.TxId))
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.VOut))
-		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
-		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		if tx.IsCoinbase() {
+			lines = append(lines, fmt.Sprintf("       Coinbase:  %x", tx.CoinbaseData()))
+			continue
+		}
+		unlocking, _ := input.UnlockingScript()
+		lines = append(lines, fmt.Sprintf("       ScriptSig: %s", script.Disassemble(unlocking)))
+		if tx.Version >= LockTimeTxVersion {
+			lines = append(lines, fmt.Sprintf("       Sequence:  %x", input.Sequence))
+		}
 	}
 	for i, output := range tx.VOut {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
-		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+		lines = append(lines, fmt.Sprintf("       Script: %s", script.Disassemble(output.LockingScript())))
 	}
-	return strings.Join(lines, "\n")
-}
-
-/*
-Serialize повертає копію сереалізованої транзакції
-*/
-func (tx *Transaction) Serialize() []byte {
-	var encoded bytes.Buffer
-
-	enc := gob.NewEncoder(&encoded)
-	err := enc.Encode(tx)
-	if err != nil {
-		log.Panic(err)
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("     LockTime: %d", tx.LockTime))
 	}
-
-	return encoded.Bytes()
+	return strings.Join(lines, "\n")
 }
 
 /*
-Verify перевіряє підписи транзакції
+Verify перевіряє, що кожен вхід транзакції відкриває скрипт блокування виходу, який він витрачає:
+скрипт розблокування входу виконується разом зі скриптом блокування ( див. script.Verify ),
+підписи і часові блокування перевіряються відносно ctx - блоку, до якого увійде транзакція.
+Метод приймає виходи, які витрачають входи транзакції.
+Транзакції версій до ScriptTxVersion можуть витрачати лише виходи, заблоковані на хеш публічного ключа.
+Повертає помилку з номером входу, який не пройшов перевірку.
 */
-func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+func (tx *Transaction) Verify(prevOutputs map[OutPoint]TXOutput, ctx SpendContext) error {
 
 	if tx.IsCoinbase() {
-		return true
+		return nil
 	}
 
-	for _, vin := range tx.VIn {
-		if prevTXs[hex.EncodeToString(vin.TxId)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
-		}
-	}
-
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
 	for inID, vin := range tx.VIn {
-		prevTx := prevTXs[hex.EncodeToString(vin.TxId)]
-		txCopy.VIn[inID].Signature = nil
-		txCopy.VIn[inID].PubKey = prevTx.VOut[vin.VOut].PubKeyHash
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
-
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
-
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) == false {
-			return false
+		prevOut, ok := prevOutputs[vin.OutPoint()]
+		if !ok {
+			return fmt.Errorf("input %d spends unknown output", inID)
 		}
-		txCopy.VIn[inID].PubKey = nil
-	}
 
-	return true
-}
+		lockingScript := prevOut.LockingScript()
+		if tx.Version < ScriptTxVersion && script.ExtractPubKeyHash(lockingScript) == nil {
+			return fmt.Errorf("input %d: transaction version %d can spend only pay-to-pubkey-hash outputs", inID, tx.Version)
+		}
 
-func DeserializeTransaction(data []byte) Transaction {
-	var transaction Transaction
+		unlockingScript, err := vin.UnlockingScript()
+		if err != nil {
+			return fmt.Errorf("input %d: %w", inID, err)
+		}
 
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	err := decoder.Decode(&transaction)
-	if err != nil {
-		log.Panic(err)
+		checker := &sigChecker{tx: tx, inID: inID, ctx: ctx}
+		err = script.Verify(unlockingScript, lockingScript, checker)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", inID, err)
+		}
 	}
 
-	return transaction
+	return nil
 }
 
 // IsCoinbase визначає, чи є транзакція транзакцією Coinbase
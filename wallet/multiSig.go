@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"blockchain1/script"
+	"bytes"
+	"fmt"
+	"log"
+)
+
+/*
+MultiSig - M-of-N multisig адреса: вихід на неї може витратити транзакція, підписана
+M ключами з PubKeys. Адреса - хеш redeem script script.MultiSigScript, тому виходи на неї
+блокуються скриптом script.PayToScriptHash, а ключі розкриваються лише під час витрати.
+*/
+type MultiSig struct {
+	M       int
+	PubKeys [][]byte
+}
+
+/*
+NewMultiSig створює multisig адресу, для витрати з якої потрібно m підписів ключами pubKeys.
+Порядок ключів впливає на адресу, тому всі учасники мають використовувати однаковий порядок.
+Redeem script розкривається під час витрати одним елементом стеку, тому адреса, скрипт якої
+довший за script.MaxElementSize ( для ключів P256 - більше 7 ключів ), не створюється.
+*/
+func NewMultiSig(m int, pubKeys [][]byte) (*MultiSig, error) {
+	for i, pubKey := range pubKeys {
+		for _, other := range pubKeys[:i] {
+			if bytes.Equal(pubKey, other) {
+				return nil, fmt.Errorf("public key %x is repeated", pubKey)
+			}
+		}
+	}
+
+	ms := &MultiSig{M: m, PubKeys: pubKeys}
+	redeemScript, err := script.MultiSigScript(m, pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(redeemScript) > script.MaxElementSize {
+		return nil, fmt.Errorf("%w: redeem script of %d keys is %d bytes, the limit is %d",
+			script.ErrElementTooBig, len(pubKeys), len(redeemScript), script.MaxElementSize)
+	}
+
+	return ms, nil
+}
+
+/*
+RedeemScript повертає скрипт, хеш якого є адресою, а виконання перевіряє підписи.
+*/
+func (ms *MultiSig) RedeemScript() []byte {
+	redeemScript, err := script.MultiSigScript(ms.M, ms.PubKeys)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return redeemScript
+}
+
+/*
+GetAddress повертає адресу хешу redeem script з байтом версії ScriptHashAddressVersion активної мережі.
+*/
+func (ms *MultiSig) GetAddress() []byte {
+	return ScriptHashAddress(ms.RedeemScript())
+}
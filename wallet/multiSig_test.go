@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	"blockchain1/script"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func testPubKeys(t *testing.T, n int) [][]byte {
+	var pubKeys [][]byte
+	for i := 0; i < n; i++ {
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKey := make([]byte, 64)
+		private.PublicKey.X.FillBytes(pubKey[:32])
+		private.PublicKey.Y.FillBytes(pubKey[32:])
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	return pubKeys
+}
+
+func TestNewMultiSigLimitsRedeemScriptSize(t *testing.T) {
+	pubKeys := testPubKeys(t, 8)
+
+	ms, err := NewMultiSig(2, pubKeys[:7])
+	if err != nil {
+		t.Fatalf("2-of-7: %v", err)
+	}
+	if size := len(ms.RedeemScript()); size > script.MaxElementSize {
+		t.Fatalf("2-of-7 redeem script is %d bytes", size)
+	}
+
+	_, err = NewMultiSig(2, pubKeys)
+	if !errors.Is(err, script.ErrElementTooBig) {
+		t.Fatalf("2-of-8: got %v, want %v", err, script.ErrElementTooBig)
+	}
+}
+
+func TestValidatePubKey(t *testing.T) {
+	pubKey := testPubKeys(t, 1)[0]
+
+	offCurve := append([]byte(nil), pubKey...)
+	offCurve[63] ^= 1
+
+	tests := []struct {
+		name   string
+		pubKey []byte
+		valid  bool
+	}{
+		{"valid", pubKey, true},
+		{"empty", nil, false},
+		{"odd length", pubKey[1:], false},
+		{"off curve", offCurve, false},
+		{"not a key", []byte("0123456789abcdef"), false},
+	}
+
+	for _, test := range tests {
+		if got := ValidatePubKey(test.pubKey); got != test.valid {
+			t.Errorf("%s: ValidatePubKey = %v, want %v", test.name, got, test.valid)
+		}
+	}
+}
@@ -1,21 +1,22 @@
 package wallet
 
 import (
+	"blockchain1/chaincfg"
 	"blockchain1/lib/base58"
+	"blockchain1/script"
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"fmt"
 	"golang.org/x/crypto/ripemd160"
 	"log"
+	"math/big"
 	"os"
 )
 
-const (
-	version            = byte(0x00)
-	addressChecksumLen = 4
-)
+const addressChecksumLen = 4
 
 /*
 Wallet представляє гаманець, який зберігає приватний ключ та публічний ключ.
@@ -40,12 +41,33 @@ func NewWallet() *Wallet {
 }
 
 /*
-GetAddress повертає адресу гаманця у вигляді байтів (base58)
+GetAddress повертає адресу гаманця у вигляді байтів (base58).
+Адреса починається з байту версії активної мережі, тому в різних мережах вона різна.
 */
 func (w Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
+	return PubKeyHashAddress(HashPubKey(w.PublicKey))
+}
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
+/*
+PubKeyHashAddress повертає адресу гаманця активної мережі з хешем публічного ключа pubKeyHash.
+*/
+func PubKeyHashAddress(pubKeyHash []byte) []byte {
+	return encodeAddress(chaincfg.ActiveNetParams.AddressVersion, pubKeyHash)
+}
+
+/*
+ScriptHashAddress повертає адресу хешу скрипта redeemScript з байтом версії ScriptHashAddressVersion активної мережі.
+Виходи на неї блокуються скриптом script.PayToScriptHash.
+*/
+func ScriptHashAddress(redeemScript []byte) []byte {
+	return encodeAddress(chaincfg.ActiveNetParams.ScriptHashAddressVersion, script.Hash160(redeemScript))
+}
+
+/*
+encodeAddress кодує в base58 байт версії адреси, хеш і контрольну суму.
+*/
+func encodeAddress(version byte, hash []byte) []byte {
+	versionedPayload := append([]byte{version}, hash...)
 	checksum := checksum(versionedPayload)
 	fullPayload := append(versionedPayload, checksum...)
 	address := base58.Encode(fullPayload)
@@ -54,16 +76,64 @@ func (w Wallet) GetAddress() []byte {
 }
 
 /*
-ValidateAddress перевіряє, чи адреса валідна
+decodeAddress повертає байт версії і хеш адреси, якщо її контрольна сума правильна.
 */
-func ValidateAddress(address string) bool {
+func decodeAddress(address string) (byte, []byte, bool) {
 	pubKeyHash := base58.Decode([]byte(address))
+	if len(pubKeyHash) <= 1+addressChecksumLen {
+		return 0, nil, false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
 	version := pubKeyHash[0]
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
 	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
 
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
+	return version, pubKeyHash, bytes.Compare(actualChecksum, targetChecksum) == 0
+}
+
+/*
+ValidateAddress перевіряє, чи адреса валідна і належить активній мережі:
+це адреса гаманця або адреса хешу скрипта ( див. MultiSig )
+*/
+func ValidateAddress(address string) bool {
+	version, _, ok := decodeAddress(address)
+	params := chaincfg.ActiveNetParams
+
+	return ok && (version == params.AddressVersion || version == params.ScriptHashAddressVersion)
+}
+
+/*
+ValidatePubKey перевіряє, що pubKey - точка кривої P256, записана як X||Y з координатами однакової довжини.
+Так ключ розбирає перевірка підпису ( див. transaction.sigChecker ), тому інший запис неможливо використати.
+*/
+func ValidatePubKey(pubKey []byte) bool {
+	if len(pubKey) == 0 || len(pubKey)%2 != 0 {
+		return false
+	}
+	x := new(big.Int).SetBytes(pubKey[:len(pubKey)/2])
+	y := new(big.Int).SetBytes(pubKey[len(pubKey)/2:])
+
+	return elliptic.P256().IsOnCurve(x, y)
+}
+
+/*
+PayToAddrScript повертає скрипт блокування виходу на адресу активної мережі:
+script.PayToPubKeyHash для адреси гаманця і script.PayToScriptHash для адреси хешу скрипта.
+*/
+func PayToAddrScript(address string) ([]byte, error) {
+	version, hash, ok := decodeAddress(address)
+	if !ok {
+		return nil, fmt.Errorf("address %s is not valid", address)
+	}
+
+	switch version {
+	case chaincfg.ActiveNetParams.AddressVersion:
+		return script.PayToPubKeyHash(hash), nil
+	case chaincfg.ActiveNetParams.ScriptHashAddressVersion:
+		return script.PayToScriptHash(hash), nil
+	}
+
+	return nil, fmt.Errorf("address %s doesn't belong to network %s", address, chaincfg.ActiveNetParams.Name)
 }
 
 /*
@@ -1,6 +1,7 @@
 package wallets
 
 import (
+	"blockchain1/chaincfg"
 	wal "blockchain1/wallet"
 	"bytes"
 	"crypto/elliptic"
@@ -10,13 +11,12 @@ import (
 	"os"
 )
 
-const walletFile = "wallet_%s.dat"
-
 /*
-Wallets зберігає колекцію гаманців
+Wallets зберігає колекцію гаманців і multisig адрес, в яких беруть участь гаманці
 */
 type Wallets struct {
-	Wallets map[string]*wal.Wallet
+	Wallets   map[string]*wal.Wallet
+	MultiSigs map[string]*wal.MultiSig
 }
 
 /*
@@ -25,6 +25,7 @@ NewWallets створює гаманці та заповнює їх з файл
 func NewWallets(nodeID string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*wal.Wallet)
+	wallets.MultiSigs = make(map[string]*wal.MultiSig)
 
 	err := wallets.LoadFromFile(nodeID)
 
@@ -50,6 +51,37 @@ func (ws *Wallets) GetWallet(address string) wal.Wallet {
 	return *ws.Wallets[address]
 }
 
+/*
+AddMultiSig додає multisig адресу до колекції та повертає її
+*/
+func (ws *Wallets) AddMultiSig(ms *wal.MultiSig) string {
+	address := fmt.Sprintf("%s", ms.GetAddress())
+
+	ws.MultiSigs[address] = ms
+
+	return address
+}
+
+/*
+GetMultiSig повертає multisig адресу колекції або nil, якщо її немає
+*/
+func (ws *Wallets) GetMultiSig(address string) *wal.MultiSig {
+	return ws.MultiSigs[address]
+}
+
+/*
+GetMultiSigAddresses повертає масив multisig адрес
+*/
+func (ws *Wallets) GetMultiSigAddresses() []string {
+	var addresses []string
+
+	for address := range ws.MultiSigs {
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
 /*
 GetAddresses повертає масив адрес гаманців
 */
@@ -67,7 +99,7 @@ func (ws *Wallets) GetAddresses() []string {
 LoadFromFile завантажує гаманці з файлу у структуру Wallets
 */
 func (ws *Wallets) LoadFromFile(nodeID string) error {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	walletFile := fmt.Sprintf(chaincfg.ActiveNetParams.WalletFile, nodeID)
 
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		return err
@@ -87,6 +119,10 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	}
 
 	ws.Wallets = wallets.Wallets
+	// файли, збережені до появи multisig адрес, їх не містять
+	if wallets.MultiSigs != nil {
+		ws.MultiSigs = wallets.MultiSigs
+	}
 
 	return nil
 
@@ -97,7 +133,7 @@ SaveToFile зберігає гаманці у файл
 */
 func (ws *Wallets) SaveToFile(nodeID string) {
 	var content bytes.Buffer
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	walletFile := fmt.Sprintf(chaincfg.ActiveNetParams.WalletFile, nodeID)
 	gob.Register(elliptic.P256())
 
 	encoder := gob.NewEncoder(&content)